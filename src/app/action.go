@@ -18,7 +18,6 @@ import (
 	"sdmm/app/command"
 	"sdmm/dmapi/dm"
 	"sdmm/dmapi/dmenv"
-	"sdmm/dmapi/dmmap"
 	"sdmm/dmapi/dmmap/dmmdata/dmmprefab"
 	"sdmm/dmapi/dmmclip"
 
@@ -50,7 +49,10 @@ func (a *app) RemoveMouseChangeCallback(callbackId int) {
 // SelectedPrefab returns currently selected dmmdata.Prefab and bool value which shows if there is one.
 // Selected prefab is taken from the cpprefabs.Prefabs panel.
 func (a *app) SelectedPrefab() (*dmmprefab.Prefab, bool) {
-	return dmmap.PrefabStorage.GetById(a.layout.Prefabs.SelectedPrefabId())
+	if !a.HasLoadedEnvironment() {
+		return nil, false
+	}
+	return a.loadedEnvironment.Context().Prefabs.GetById(a.layout.Prefabs.SelectedPrefabId())
 }
 
 // HasSelectedPrefab returns true, if the application has a globally selected prefab.
@@ -115,7 +117,8 @@ func (a *app) AvailableMaps() (availableMaps []string) {
 	return availableMaps
 }
 
-// LoadedEnvironment returns currently loaded environment.
+// LoadedEnvironment returns the environment of the active workspace.
+// If the active workspace isn't a map, then the last used environment is returned.
 func (a *app) LoadedEnvironment() *dmenv.Dme {
 	return a.loadedEnvironment
 }
@@ -131,6 +134,14 @@ func (a *app) HasActiveMap() bool {
 	return ok
 }
 
+// HasSelectedArea returns true if there is a selected area on the active map.
+func (a *app) HasSelectedArea() bool {
+	if ws, ok := a.activeWsMap(); ok {
+		return ws.Map().Editor().HasSelectedArea()
+	}
+	return false
+}
+
 // UpdateTitle updates title in the application system window.
 // The title depends on current open environment and workspace.
 func (a *app) UpdateTitle() {
@@ -161,6 +172,11 @@ func (a *app) UpdateScale() {
 
 // OnWorkspaceSwitched called when the app workspace is switched.
 func (a *app) OnWorkspaceSwitched() {
+	// Panels follow the environment of the active map.
+	if ws, ok := a.activeWsMap(); ok {
+		a.switchEnvironment(ws.Map().Dmm().Context().Env())
+	}
+
 	a.UpdateTitle()
 
 	// Update search results for the current map.
@@ -2,6 +2,7 @@ package app
 
 import (
 	"log"
+	"path/filepath"
 
 	"sdmm/app/prefs"
 	"sdmm/app/render"
@@ -10,9 +11,11 @@ import (
 	"sdmm/app/ui/layout/lnode"
 	"sdmm/app/window"
 	"sdmm/dmapi/dmmap"
+	"sdmm/dmapi/dmmap/dmmdata"
 	"sdmm/dmapi/dmmap/dmmdata/dmmprefab"
 	"sdmm/dmapi/dmmap/dmminstance"
 	"sdmm/env"
+	"sdmm/util"
 	"sdmm/util/slice"
 
 	"github.com/skratchdot/open-golang/open"
@@ -51,12 +54,7 @@ func (a *app) DoOpenV(ws *workspace.Workspace) {
 		SetStartDir(startDir).
 		Load(); err == nil {
 		log.Println("[app] resource to load selected:", file)
-
-		if a.HasLoadedEnvironment() {
-			a.loadMap(file, ws)
-		} else {
-			a.DoLoadResourceV(file, ws)
-		}
+		a.DoLoadResourceV(file, ws)
 	}
 }
 
@@ -78,12 +76,29 @@ func (a *app) DoClearRecentEnvironments() {
 	a.projectConfig().ClearProjects()
 }
 
-// DoCloseEnvironment closes currently opened environment.
+// DoInvalidateEnvironmentCache deletes the cache of the current environment and parses it again.
+func (a *app) DoInvalidateEnvironmentCache() {
+	if !a.HasLoadedEnvironment() {
+		return
+	}
+
+	log.Println("[app] do invalidate environment cache")
+	a.envCache.Invalidate(a.loadedEnvironment.RootFile)
+	a.reloadEnvironment(a.loadedEnvironment)
+}
+
+// DoCloseEnvironment closes currently opened environment with its maps.
+// Other loaded environments stay opened.
 func (a *app) DoCloseEnvironment() {
-	log.Println("[app] closing environment")
-	a.closeEnvironment(func(closed bool) {
+	if !a.HasLoadedEnvironment() {
+		return
+	}
+
+	env := a.loadedEnvironment
+	log.Println("[app] closing environment:", env.RootFile)
+	a.closeEnvironment(env, func(closed bool) {
 		if closed {
-			a.freeEnvironmentResources()
+			a.freeEnvironmentResources(env)
 			a.layout.WsArea.AddEmptyWorkspaceIfNone()
 		}
 	})
@@ -95,6 +110,62 @@ func (a *app) DoCreateMap() {
 	a.layout.WsArea.OpenCreateMap()
 }
 
+// DoExportSelectionAsMap saves the selected area of the active map as a standalone map file.
+func (a *app) DoExportSelectionAsMap() {
+	log.Println("[app] do export selection as map")
+
+	ws, ok := a.activeWsMap()
+	if !ok || !ws.Map().Editor().HasSelectedArea() {
+		return
+	}
+
+	if file, err := dialog.
+		File().
+		Title("Export Selection as Map").
+		Filter("Map", "dmm").
+		SetStartDir(a.loadedEnvironment.RootDir).
+		Save(); err == nil {
+		a.FocusApplicationWindow() // After a system dialog has been opened we need to return the focus.
+
+		if filepath.Ext(file) != ".dmm" {
+			file = file + ".dmm"
+		}
+
+		log.Println("[app] exporting selection to:", file)
+		ws.ExportSelection(file)
+	}
+}
+
+// DoInsertMapFile opens a map file, which user need to select in file dialog, to place it on the active map.
+func (a *app) DoInsertMapFile() {
+	log.Println("[app] do insert map file")
+
+	ws, ok := a.activeWsMap()
+	if !ok {
+		return
+	}
+
+	if file, err := dialog.
+		File().
+		Title("Insert Map File").
+		Filter("Map", "dmm").
+		SetStartDir(a.loadedEnvironment.RootDir).
+		Load(); err == nil {
+		a.FocusApplicationWindow()
+
+		log.Println("[app] map file to insert selected:", file)
+
+		data, err := dmmdata.New(file)
+		if err != nil {
+			log.Printf("[app] unable to read map file [%s]: %v", file, err)
+			util.ShowErrorDialog("Unable to read the map: " + err.Error())
+			return
+		}
+
+		ws.Map().StartMapInsert(data)
+	}
+}
+
 // DoClearRecentMaps clears recently opened maps.
 func (a *app) DoClearRecentMaps() {
 	log.Println("[app] clear recent maps")
@@ -164,7 +235,7 @@ func (a *app) DoSelectPrefab(prefab *dmmprefab.Prefab) {
 // DoSelectPrefabByPath globally selects a prefab with provided type path.
 func (a *app) DoSelectPrefabByPath(path string) {
 	log.Println("[app] select prefab by path:", path)
-	a.DoSelectPrefab(dmmap.PrefabStorage.Initial(path))
+	a.DoSelectPrefab(a.loadedEnvironment.Context().Prefabs.Initial(path))
 }
 
 // DoEditInstance enables an editing for the provided instance.
@@ -182,7 +253,7 @@ func (a *app) DoEditPrefab(prefab *dmmprefab.Prefab) {
 // DoEditPrefabByPath enables an editing for the provided prefab by its path.
 func (a *app) DoEditPrefabByPath(path string) {
 	log.Println("[app] edit prefab by path:", path)
-	a.DoEditPrefab(dmmap.PrefabStorage.Initial(path))
+	a.DoEditPrefab(a.loadedEnvironment.Context().Prefabs.Initial(path))
 }
 
 // DoSearchPrefab does a search of the provided prefab ID.
@@ -257,6 +328,21 @@ func (a *app) DoOpenSupport() {
 	}
 }
 
+// DoOpenInExternalEditor opens the file on the line with the editor from preferences.
+func (a *app) DoOpenInExternalEditor(path string, line int) {
+	log.Printf("[app] do open in external editor [%s] at [%d]", path, line)
+	if err := a.openInExternalEditor(path, line); err != nil {
+		log.Println("[app] unable to open in external editor:", err)
+		showExternalEditorError(path, err)
+	}
+}
+
+// DoDiagnostics shows diagnostics of the environment.
+func (a *app) DoDiagnostics() {
+	log.Println("[app] do diagnostics")
+	a.ShowLayout(lnode.NameDiagnostics, true)
+}
+
 // DoCopy copies currently selected (hovered) tiles to the global clipboard.
 func (a *app) DoCopy() {
 	log.Println("[app] do copy")
@@ -274,6 +360,17 @@ func (a *app) DoPaste() {
 	}
 }
 
+// DoTransform rotates or flips the selected area of the active map.
+// When there is no selected area, tiles of the global clipboard are transformed, so they are pasted transformed.
+func (a *app) DoTransform(transform dmmap.Transform) {
+	log.Println("[app] do transform:", transform)
+	if ws, ok := a.activeWsMap(); ok && ws.Map().Editor().HasSelectedArea() {
+		ws.Map().Editor().TransformSelected(transform)
+	} else {
+		a.clipboard.Transform(transform)
+	}
+}
+
 // DoCut cuts currently selected (hovered) tiles to the global clipboard.
 func (a *app) DoCut() {
 	log.Println("[app] do cut")
@@ -309,6 +406,13 @@ func (a *app) DoSearch() {
 	a.ShowLayout(lnode.NameSearch, true)
 }
 
+// DoStatistics collects statistics of the active map.
+func (a *app) DoStatistics() {
+	log.Println("[app] do statistics")
+	a.layout.Stats.Collect()
+	a.ShowLayout(lnode.NameStatistics, true)
+}
+
 // DoAreaBorders toggles area borders rendering.
 func (a *app) DoAreaBorders() {
 	pmap.AreaBordersRendering = !pmap.AreaBordersRendering
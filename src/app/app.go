@@ -31,6 +31,7 @@ import (
 const (
 	ttlDaysLogs    = 14
 	ttlDaysBackups = 3
+	ttlDaysHistory = 14
 )
 
 func Start() {
@@ -56,7 +57,9 @@ func Start() {
 		internalDir: internalDir,
 		logDir:      logDir,
 		backupDir:   filepath.FromSlash(internalDir + "/backup"),
+		historyDir:  filepath.FromSlash(internalDir + "/history"),
 		configDir:   filepath.FromSlash(internalDir + "/config"),
+		envCache:    dmenv.NewCache(filepath.FromSlash(internalDir + "/cache/environment")),
 	}
 
 	a.masterWindow = window.New(&a)
@@ -80,6 +83,7 @@ type app struct {
 	internalDir string
 	logDir      string
 	backupDir   string
+	historyDir  string
 	configDir   string
 
 	tmpShouldClose bool
@@ -92,8 +96,17 @@ type app struct {
 
 	shortcutsEnabled bool
 
+	// Several environments could be loaded at the same time.
+	// Every map workspace is bound to the environment it was opened with.
+	environments []*dmenv.Dme
+
+	// The environment of the active workspace (or the last used one). Panels of the application show its content.
 	loadedEnvironment *dmenv.Dme
 	pathsFilter       *dm.PathsFilter
+	pathsFilters      map[*dmenv.Dme]*dm.PathsFilter
+	envWatchers       map[*dmenv.Dme]*envWatcher
+	// Parsed environments are cached, so they are loaded faster when their code isn't modified.
+	envCache *dmenv.Cache
 
 	configs map[string]config.Config
 
@@ -109,6 +122,7 @@ func (a *app) initialize() {
 
 	a.deleteOldLogs()
 	a.deleteOldBackups()
+	a.deleteOldHistory()
 
 	a.loadConfig()
 	a.loadProjectConfig()
@@ -120,6 +134,8 @@ func (a *app) initialize() {
 
 	a.commandStorage = command.NewStorage()
 	a.pathsFilter = dm.NewPathsFilterEmpty()
+	a.pathsFilters = make(map[*dmenv.Dme]*dm.PathsFilter)
+	a.envWatchers = make(map[*dmenv.Dme]*envWatcher)
 	a.clipboard = dmmclip.New()
 
 	a.menu = menu.New(a)
@@ -289,3 +305,27 @@ func (a *app) deleteOldBackups() {
 		log.Println("[app] no old backups to delete")
 	}
 }
+
+func (a *app) deleteOldHistory() {
+	historyCount := 0
+	var dirs []string
+	_ = filepath.Walk(a.historyDir, func(path string, info os.FileInfo, _ error) error {
+		if info != nil && info.IsDir() && path != a.historyDir {
+			dirs = append(dirs, path)
+		} else if info != nil && !info.IsDir() && time.Since(info.ModTime()).Hours()/24 > ttlDaysHistory {
+			_ = os.Remove(path)
+			historyCount++
+		}
+		return nil
+	})
+	// Directories of maps and environments without history are deleted too.
+	// Nested directories go after their parents, so they are deleted first. Non-empty directories are not deleted.
+	for idx := len(dirs) - 1; idx >= 0; idx-- {
+		_ = os.Remove(dirs[idx])
+	}
+	if historyCount > 0 {
+		log.Println("[app] old map history deleted:", historyCount)
+	} else {
+		log.Println("[app] no old map history to delete")
+	}
+}
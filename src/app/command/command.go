@@ -1,5 +1,11 @@
 package command
 
+import (
+	"time"
+
+	"sdmm/util"
+)
+
 // Used provide a unique id for every command.
 var commandCounter uint64 = 0
 
@@ -7,6 +13,9 @@ type Command struct {
 	id   uint64
 	name string
 
+	time   time.Time
+	coords []util.Point
+
 	undo, redo func()
 }
 
@@ -15,6 +24,7 @@ func Make(name string, undo, redo func()) Command {
 	return Command{
 		id:   commandCounter,
 		name: name,
+		time: time.Now(),
 		undo: undo,
 		redo: redo,
 	}
@@ -24,12 +34,35 @@ func (c Command) ReadableName() string {
 	return c.name
 }
 
+// Time returns the moment when the command was made.
+func (c Command) Time() time.Time {
+	return c.time
+}
+
+// WithTime returns a copy of the command with the provided time.
+// Useful for commands, which were made in the past, like commands restored from the previous session.
+func (c Command) WithTime(time time.Time) Command {
+	c.time = time
+	return c
+}
+
+// Coords returns coords of tiles affected by the command.
+func (c Command) Coords() []util.Point {
+	return c.coords
+}
+
+// WithCoords returns a copy of the command with the provided coords of affected tiles.
+func (c Command) WithCoords(coords []util.Point) Command {
+	c.coords = coords
+	return c
+}
+
 func (c Command) Run() Command {
 	c.undo()
-	return Command{
-		id:   c.id,
-		name: c.name,
-		undo: c.redo,
-		redo: c.undo,
-	}
+	return c.reversed()
+}
+
+func (c Command) reversed() Command {
+	c.undo, c.redo = c.redo, c.undo
+	return c
 }
@@ -1,29 +1,49 @@
 package command
 
-import "log"
+import (
+	"fmt"
+	"log"
+	"sync"
+)
 
 // NullSpaceStackId is for a stack which won't hold any command and will be always empty.
 const NullSpaceStackId = "__NULL_SPACE__"
 
 // Storage is used to store application command and handle undo/redo stuff.
+//
+// Map changes are committed in background goroutines, so all storage operations are serialized.
+// Commands are run under the lock too, so they must not use the storage.
 type Storage struct {
+	mu sync.Mutex
+
 	currentStackId string
 	commandStacks  map[string]*commandStack
+
+	transaction *transaction
 }
 
 func NewStorage() *Storage {
 	s := &Storage{commandStacks: make(map[string]*commandStack)}
-	s.SetStack(NullSpaceStackId)
+	s.setStack(NullSpaceStackId)
 	return s
 }
 
 func (s *Storage) Free() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.commandStacks = make(map[string]*commandStack, len(s.commandStacks))
-	s.SetStack(NullSpaceStackId)
+	s.transaction = nil
+	s.setStack(NullSpaceStackId)
 	log.Println("[command] storage free")
 }
 
 func (s *Storage) SetStack(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setStack(id)
+}
+
+func (s *Storage) setStack(id string) {
 	if s.currentStackId == id {
 		return
 	}
@@ -38,6 +58,9 @@ func (s *Storage) SetStack(id string) {
 }
 
 func (s *Storage) DisposeStack(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	if id == NullSpaceStackId {
 		log.Println("[command] skip disposing for:", id)
 		return
@@ -45,32 +68,106 @@ func (s *Storage) DisposeStack(id string) {
 
 	log.Println("[command] disposing stack:", id)
 	delete(s.commandStacks, id)
+	if s.transaction != nil && s.transaction.stackId == id {
+		log.Println("[command] dropping transaction of the disposed stack:", s.transaction.name)
+		s.transaction = nil
+	}
 	if s.currentStackId == id {
-		s.SetStack(NullSpaceStackId)
+		s.setStack(NullSpaceStackId)
 	}
 }
 
 func (s *Storage) Push(command Command) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pushCurrent(command)
+}
+
+// PushFunc makes a command with the provided function and pushes it, if the function returns true.
+// Both are done under the storage lock, so commands made concurrently are pushed in the order they are made,
+// and no undo or redo could happen between making the command and pushing it.
+func (s *Storage) PushFunc(makeCommand func() (Command, bool)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if command, ok := makeCommand(); ok {
+		s.pushCurrent(command)
+	}
+}
+
+func (s *Storage) pushCurrent(command Command) {
 	if s.currentStackId == NullSpaceStackId {
 		log.Println("[command] skip pushing for:", s.currentStackId)
 		return
 	}
 
+	if s.transaction != nil && s.transaction.stackId == s.currentStackId {
+		log.Printf("[command] push command [%s] to transaction [%s]", command.name, s.transaction.name)
+		s.transaction.commands = append(s.transaction.commands, command)
+		return
+	}
+
 	if stack, ok := s.commandStacks[s.currentStackId]; ok {
-		logStackAction(stack, "push command: "+command.name)
-		stack.undo = append(stack.undo, command)
-		stack.redo = stack.redo[:0]
-		stack.balance++
+		s.push(stack, command)
 	} else {
 		logNoStackAvailable("push command")
 	}
 }
 
+func (s *Storage) push(stack *commandStack, command Command) {
+	logStackAction(stack, "push command: "+command.name)
+	stack.undo = append(stack.undo, command)
+	stack.redo = stack.redo[:0]
+	stack.balance++
+}
+
+// Restore fills the stack with commands restored from the previous session.
+// Undo commands are pushed as if they were executed, redo commands are pushed as if they were undone.
+// Both are expected in the order of their execution. The restored stack is considered as balanced.
+func (s *Storage) Restore(id string, undo, redo []Command) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id == NullSpaceStackId {
+		log.Println("[command] skip restoring for:", id)
+		return
+	}
+
+	stack, ok := s.commandStacks[id]
+	if !ok {
+		stack = &commandStack{id: id}
+		s.commandStacks[id] = stack
+		log.Println("[command] created stack:", id)
+	}
+
+	logStackAction(stack, "restore")
+
+	stack.undo = append(stack.undo[:0], undo...)
+	stack.redo = stack.redo[:0]
+	for idx := len(redo) - 1; idx >= 0; idx-- {
+		stack.redo = append(stack.redo, redo[idx].reversed())
+	}
+
+	stack.balance = 0
+	stack.balanceCommandId = stack.appliedCommandId()
+}
+
 func (s *Storage) Undo() {
-	s.UndoV(s.currentStackId)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.undoV(s.currentStackId)
 }
 
 func (s *Storage) UndoV(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.undoV(id)
+}
+
+func (s *Storage) undoV(id string) {
+	if s.isLockedByTransaction(id, "undo") {
+		return
+	}
+
 	if stack, ok := s.commandStacks[id]; ok {
 		logStackAction(stack, "undo")
 
@@ -93,10 +190,22 @@ func (s *Storage) undo(stack *commandStack) {
 }
 
 func (s *Storage) Redo() {
-	s.RedoV(s.currentStackId)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.redoV(s.currentStackId)
 }
 
 func (s *Storage) RedoV(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.redoV(id)
+}
+
+func (s *Storage) redoV(id string) {
+	if s.isLockedByTransaction(id, "redo") {
+		return
+	}
+
 	if stack, ok := s.commandStacks[id]; ok {
 		logStackAction(stack, "redo")
 
@@ -118,11 +227,63 @@ func (s *Storage) redo(stack *commandStack) {
 	stack.balance++
 }
 
+// Commands returns commands of the stack in the order of their execution.
+// The second value is the number of commands from the beginning, which are applied at the moment.
+func (s *Storage) Commands(id string) (commands []Command, applied int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stack, ok := s.commandStacks[id]; ok {
+		commands = make([]Command, 0, len(stack.undo)+len(stack.redo))
+		commands = append(commands, stack.undo...)
+		for idx := len(stack.redo) - 1; idx >= 0; idx-- {
+			commands = append(commands, stack.redo[idx].reversed())
+		}
+		return commands, len(stack.undo)
+	}
+	return nil, 0
+}
+
+// GoTo undoes or redoes commands of the stack, until the provided number of commands is applied.
+func (s *Storage) GoTo(id string, applied int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.isLockedByTransaction(id, "go to") {
+		return
+	}
+
+	if stack, ok := s.commandStacks[id]; ok {
+		logStackAction(stack, fmt.Sprint("go to: ", applied))
+
+		if applied < 0 || applied > len(stack.undo)+len(stack.redo) {
+			log.Println("[command] unable to go to invalid position:", applied)
+			return
+		}
+
+		for len(stack.undo) > applied {
+			s.undo(stack)
+		}
+		for len(stack.undo) < applied {
+			s.redo(stack)
+		}
+	} else {
+		logNoStackAvailable("go to")
+	}
+}
+
 func (s *Storage) HasUndo() bool {
-	return s.HasUndoV(s.currentStackId)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hasUndoV(s.currentStackId)
 }
 
 func (s *Storage) HasUndoV(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hasUndoV(id)
+}
+
+func (s *Storage) hasUndoV(id string) bool {
 	if stack, ok := s.commandStacks[id]; ok {
 		return len(stack.undo) > 0
 	}
@@ -130,10 +291,18 @@ func (s *Storage) HasUndoV(id string) bool {
 }
 
 func (s *Storage) HasRedo() bool {
-	return s.HasRedoV(s.currentStackId)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hasRedoV(s.currentStackId)
 }
 
 func (s *Storage) HasRedoV(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hasRedoV(id)
+}
+
+func (s *Storage) hasRedoV(id string) bool {
 	if stack, ok := s.commandStacks[id]; ok {
 		return len(stack.redo) > 0
 	}
@@ -141,6 +310,8 @@ func (s *Storage) HasRedoV(id string) bool {
 }
 
 func (s *Storage) IsModified(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if stack, ok := s.commandStacks[id]; ok {
 		return stack.balance != 0 || stack.appliedCommandId() != stack.balanceCommandId
 	}
@@ -148,6 +319,9 @@ func (s *Storage) IsModified(id string) bool {
 }
 
 func (s *Storage) ForceBalance(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	if id == NullSpaceStackId {
 		log.Println("[command] skipping force balance for:", id)
 		return
@@ -161,6 +335,9 @@ func (s *Storage) ForceBalance(id string) {
 }
 
 func (s *Storage) Balance(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	if id == NullSpaceStackId {
 		log.Println("[command] skipping balance for:", id)
 		return
@@ -181,6 +358,15 @@ func (s *Storage) Balance(id string) {
 	}
 }
 
+// Commands of the stack can't be changed during the transaction, since transaction commands are applied on top of them.
+func (s *Storage) isLockedByTransaction(id, action string) bool {
+	if s.transaction != nil && s.transaction.stackId == id {
+		log.Printf("[command] unable to %s during the transaction: %s", action, s.transaction.name)
+		return true
+	}
+	return false
+}
+
 func logNoStackAvailable(action string) {
 	log.Println("[command] invalid action, no stack available at the moment:", action)
 }
@@ -0,0 +1,157 @@
+package command
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testStackId = "test"
+
+// Makes a command, which appends its name to the log on redo and removes it on undo.
+func makeLogCommand(log *[]string, name string) Command {
+	*log = append(*log, name)
+	return Make(name, func() {
+		*log = (*log)[:len(*log)-1]
+	}, func() {
+		*log = append(*log, name)
+	})
+}
+
+func newTestStorage() *Storage {
+	s := NewStorage()
+	s.SetStack(testStackId)
+	return s
+}
+
+func TestTransaction(t *testing.T) {
+	var log []string
+	s := newTestStorage()
+
+	s.Push(makeLogCommand(&log, "before"))
+
+	s.BeginTransaction("Compound")
+	s.Push(makeLogCommand(&log, "first"))
+	s.Push(makeLogCommand(&log, "second"))
+	assert.True(t, s.InTransaction(testStackId))
+	assert.False(t, s.InTransaction("other"))
+	s.EndTransaction()
+	assert.False(t, s.InTransaction(testStackId))
+
+	commands, applied := s.Commands(testStackId)
+	assert.Equal(t, 2, applied)
+	assert.Equal(t, "Compound", commands[1].ReadableName())
+
+	s.Undo()
+	assert.Equal(t, []string{"before"}, log)
+
+	s.Redo()
+	assert.Equal(t, []string{"before", "first", "second"}, log)
+}
+
+func TestTransactionNested(t *testing.T) {
+	var log []string
+	s := newTestStorage()
+
+	s.BeginTransaction("Outer")
+	s.Push(makeLogCommand(&log, "outer"))
+	s.BeginTransaction("Inner")
+	s.Push(makeLogCommand(&log, "inner"))
+	s.EndTransaction()
+	assert.True(t, s.InTransaction(testStackId))
+	s.EndTransaction()
+
+	commands, _ := s.Commands(testStackId)
+	assert.Len(t, commands, 1)
+	assert.Equal(t, "Outer", commands[0].ReadableName())
+
+	s.Undo()
+	assert.Empty(t, log)
+}
+
+func TestTransactionAbort(t *testing.T) {
+	var log []string
+	s := newTestStorage()
+
+	s.Push(makeLogCommand(&log, "before"))
+
+	s.BeginTransaction("Outer")
+	s.Push(makeLogCommand(&log, "outer"))
+
+	// Aborting the nested transaction rolls back only its commands.
+	s.BeginTransaction("Inner")
+	s.Push(makeLogCommand(&log, "inner"))
+	s.AbortTransaction()
+	assert.Equal(t, []string{"before", "outer"}, log)
+	assert.True(t, s.InTransaction(testStackId))
+
+	s.AbortTransaction()
+	assert.Equal(t, []string{"before"}, log)
+	assert.False(t, s.InTransaction(testStackId))
+
+	commands, applied := s.Commands(testStackId)
+	assert.Len(t, commands, 1)
+	assert.Equal(t, 1, applied)
+}
+
+func TestTransactionLocksUndo(t *testing.T) {
+	var log []string
+	s := newTestStorage()
+
+	s.Push(makeLogCommand(&log, "before"))
+
+	s.BeginTransaction("Compound")
+	s.Push(makeLogCommand(&log, "first"))
+	s.Undo()
+	assert.Equal(t, []string{"before", "first"}, log)
+	s.EndTransaction()
+
+	// An empty transaction doesn't make a command.
+	s.BeginTransaction("Empty")
+	s.EndTransaction()
+
+	commands, _ := s.Commands(testStackId)
+	assert.Len(t, commands, 2)
+}
+
+// Map changes are committed in background goroutines, while the user does undo and redo.
+// Every command is pushed right after it's made, so commands of the stack are always in sync with the log.
+func TestConcurrentPush(t *testing.T) {
+	var log []string
+	s := newTestStorage()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s.PushFunc(func() (Command, bool) {
+				// Some commits have no changes, so they don't make commands.
+				if i%10 == 0 {
+					return Command{}, false
+				}
+				return makeLogCommand(&log, fmt.Sprint("commit ", i)), true
+			})
+		}(i)
+	}
+
+	for i := 0; i < 100; i++ {
+		s.Undo()
+		s.Redo()
+		if i%3 == 0 {
+			s.Undo()
+		}
+		s.Commands(testStackId)
+	}
+
+	wg.Wait()
+
+	commands, applied := s.Commands(testStackId)
+	names := make([]string, 0, applied)
+	for _, command := range commands[:applied] {
+		names = append(names, command.ReadableName())
+	}
+	assert.Equal(t, log, names)
+}
@@ -0,0 +1,132 @@
+package command
+
+import (
+	"log"
+
+	"sdmm/util"
+)
+
+// Transaction collects commands pushed to the stack, so they could be collapsed into a single command.
+type transaction struct {
+	name     string
+	stackId  string
+	commands []Command
+
+	// Positions in commands where nested transactions were started.
+	marks []int
+}
+
+// BeginTransaction starts a transaction on the current stack.
+// Commands pushed until the end of the transaction are collapsed into a single command with the provided name.
+// Transactions can be nested. Nested transactions are a part of the outermost one, so they don't make commands.
+func (s *Storage) BeginTransaction(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.transaction != nil {
+		log.Println("[command] begin nested transaction:", name)
+		s.transaction.marks = append(s.transaction.marks, len(s.transaction.commands))
+		return
+	}
+
+	log.Printf("[command] begin transaction [%s] on [%s]", name, s.currentStackId)
+	s.transaction = &transaction{
+		name:    name,
+		stackId: s.currentStackId,
+	}
+}
+
+// EndTransaction ends the latest started transaction.
+// When the outermost transaction is ended, its commands are pushed to the stack as a single command.
+func (s *Storage) EndTransaction() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.transaction == nil {
+		log.Println("[command] unable to end transaction, no transaction started")
+		return
+	}
+
+	if len(s.transaction.marks) > 0 {
+		log.Println("[command] end nested transaction")
+		s.transaction.marks = s.transaction.marks[:len(s.transaction.marks)-1]
+		return
+	}
+
+	tx := s.transaction
+	s.transaction = nil
+
+	log.Printf("[command] end transaction [%s] with [%d] commands", tx.name, len(tx.commands))
+
+	if len(tx.commands) == 0 {
+		return
+	}
+
+	if stack, ok := s.commandStacks[tx.stackId]; ok {
+		s.push(stack, compound(tx.name, tx.commands))
+	} else {
+		logNoStackAvailable("end transaction")
+	}
+}
+
+// AbortTransaction rolls back commands pushed since the start of the latest transaction.
+// Commands are undone in the reverse order and dropped.
+func (s *Storage) AbortTransaction() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.transaction == nil {
+		log.Println("[command] unable to abort transaction, no transaction started")
+		return
+	}
+
+	start := 0
+	nested := len(s.transaction.marks) > 0
+	if nested {
+		start = s.transaction.marks[len(s.transaction.marks)-1]
+		s.transaction.marks = s.transaction.marks[:len(s.transaction.marks)-1]
+	}
+
+	log.Printf("[command] abort transaction [%s], rolling back [%d] commands",
+		s.transaction.name, len(s.transaction.commands)-start)
+
+	for idx := len(s.transaction.commands) - 1; idx >= start; idx-- {
+		s.transaction.commands[idx].undo()
+	}
+	s.transaction.commands = s.transaction.commands[:start]
+
+	if !nested {
+		s.transaction = nil
+	}
+}
+
+// InTransaction returns true, if there is a started transaction on the stack with the provided id.
+func (s *Storage) InTransaction(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.transaction != nil && s.transaction.stackId == id
+}
+
+// Makes a single command, which does undo/redo for all provided commands in the correct order.
+func compound(name string, commands []Command) Command {
+	var coords []util.Point
+	visited := make(map[util.Point]bool)
+	for _, command := range commands {
+		for _, coord := range command.coords {
+			if !visited[coord] {
+				visited[coord] = true
+				coords = append(coords, coord)
+			}
+		}
+	}
+
+	return Make(name, func() {
+		for idx := len(commands) - 1; idx >= 0; idx-- {
+			commands[idx].undo()
+		}
+	}, func() {
+		for _, command := range commands {
+			command.redo()
+		}
+	}).WithTime(commands[0].time).WithCoords(coords)
+}
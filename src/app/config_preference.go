@@ -54,8 +54,9 @@ func (a *app) loadPreferencesConfig() {
 				QuickEditMapPane: true,
 			},
 			Editor: prefs.Editor{
-				SaveFormat: prefs.SaveFormatInitial,
-				NudgeMode:  prefs.SaveNudgeModePixel,
+				SaveFormat:     prefs.SaveFormatInitial,
+				NudgeMode:      prefs.SaveNudgeModePixel,
+				PreserveLayout: true,
 			},
 			Application: prefs.Application{
 				CheckForUpdates: true,
@@ -0,0 +1,53 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"sdmm/app/ui/dialog"
+
+	"github.com/skratchdot/open-golang/open"
+)
+
+// Opens the file with the editor set in preferences. Without the editor the file is opened with the default application.
+func (a *app) openInExternalEditor(path string, line int) error {
+	args := externalEditorArgs(a.preferencesConfig().Prefs.Application.ExternalEditor, path, line)
+	if len(args) == 0 {
+		return open.Run(path)
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("unable to run external editor [%s]: %w", args[0], err)
+	}
+
+	// The editor isn't waited, but its process is released when it exits.
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			log.Println("[app] external editor exited with error:", err)
+		}
+	}()
+
+	return nil
+}
+
+// Placeholders are replaced after splitting, so paths with spaces stay in the same argument.
+func externalEditorArgs(command, path string, line int) []string {
+	args := strings.Fields(command)
+	for idx, arg := range args {
+		arg = strings.ReplaceAll(arg, "%f", path)
+		arg = strings.ReplaceAll(arg, "%l", strconv.Itoa(line))
+		args[idx] = arg
+	}
+	return args
+}
+
+func showExternalEditorError(path string, err error) {
+	dialog.Open(dialog.TypeInformation{
+		Title:       "Error!",
+		Information: fmt.Sprintf("Unable to open the file: %s\n%v", path, err),
+	})
+}
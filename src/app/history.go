@@ -0,0 +1,96 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"sdmm/dmapi/dmmap"
+	"sdmm/dmapi/dmmsnap"
+	"sdmm/util"
+)
+
+// LoadMapHistory returns the history of the map changes from the previous sessions.
+// The history is returned only if the map content wasn't changed since the history was stored.
+func (a *app) LoadMapHistory(dmm *dmmap.Dmm) (dmmsnap.History, bool) {
+	path := dmm.Path.Absolute
+
+	hash, err := mapContentHash(path)
+	if err != nil {
+		log.Printf("[app] unable to hash map content [%s]: %v", path, err)
+		return dmmsnap.History{}, false
+	}
+
+	src := filepath.Join(a.mapHistoryDir(dmm), hash+".json")
+
+	data, err := os.ReadFile(src)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("[app] unable to read map history [%s]: %v", src, err)
+		}
+		return dmmsnap.History{}, false
+	}
+
+	var history dmmsnap.History
+	if err = json.Unmarshal(data, &history); err != nil {
+		log.Printf("[app] unable to parse map history [%s]: %v", src, err)
+		return dmmsnap.History{}, false
+	}
+
+	log.Println("[app] map history loaded:", src)
+	return history, true
+}
+
+// StoreMapHistory stores the history of the map changes, so it could be restored in the next session.
+// The history is bound to the current map content, so it should be stored right after the map is saved.
+func (a *app) StoreMapHistory(dmm *dmmap.Dmm, history dmmsnap.History) {
+	path := dmm.Path.Absolute
+
+	hash, err := mapContentHash(path)
+	if err != nil {
+		log.Printf("[app] unable to hash map content [%s]: %v", path, err)
+		return
+	}
+
+	data, err := json.Marshal(history)
+	if err != nil {
+		log.Printf("[app] unable to serialize map history [%s]: %v", path, err)
+		return
+	}
+
+	dir := a.mapHistoryDir(dmm)
+
+	// Histories for other map contents are useless, since the map on the disk is changed.
+	_ = os.RemoveAll(dir)
+	_ = os.MkdirAll(dir, os.ModePerm)
+
+	dst := filepath.Join(dir, hash+".json")
+	if err = os.WriteFile(dst, data, 0644); err != nil {
+		log.Printf("[app] unable to write map history [%s]: %v", dst, err)
+		return
+	}
+
+	log.Println("[app] map history stored:", dst)
+}
+
+// format: history/environment.dme/map.dmm-pathHash/contentHash.json
+func (a *app) mapHistoryDir(dmm *dmmap.Dmm) string {
+	path := dmm.Path.Absolute
+	return filepath.FromSlash(a.historyDir + "/" +
+		dmm.Context().Env().Name + "/" +
+		fmt.Sprintf("%s-%x", filepath.Base(path), util.Djb2(path)),
+	)
+}
+
+func mapContentHash(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), nil
+}
@@ -0,0 +1,9 @@
+package prefs
+
+const ExternalEditorHelp = `Arguments are separated by spaces. Placeholders:
+%f - the path of the file
+%l - the line in the file
+Examples:
+code -g %f:%l
+notepad++ -n%l %f
+`
@@ -30,6 +30,18 @@ func Make(app App, prefs *Prefs) wsprefs.Prefs {
 				label: "##sanitize_variables",
 				value: &prefs.Editor.SanitizeVariables,
 			},
+			boolPrefPrefab{
+				name:  "Deterministic Keys",
+				desc:  "When enabled, new keys are allocated in a stable order, so the same map is always saved the same way.",
+				label: "##deterministic_keys",
+				value: &prefs.Editor.DeterministicKeys,
+			},
+			boolPrefPrefab{
+				name:  "Preserve Layout",
+				desc:  "When enabled, the header and the grid blocks of the map are saved as they were in the file, if the map wasn't resized.",
+				label: "##preserve_layout",
+				value: &prefs.Editor.PreserveLayout,
+			},
 			optionPrefPrefab{
 				name:    "Nudge Mode",
 				desc:    "Controls which variables will be changed during the nudge.",
@@ -96,6 +108,14 @@ func Make(app App, prefs *Prefs) wsprefs.Prefs {
 				label: "##auto_update",
 				value: &prefs.Application.AutoUpdate,
 			},
+			stringPrefPrefab{
+				name:  "External Editor",
+				desc:  "A command to open code files, like from the Diagnostics panel. When empty, files are opened with the default application.",
+				label: "##external_editor",
+				hint:  "code -g %f:%l",
+				help:  ExternalEditorHelp,
+				value: &prefs.Application.ExternalEditor,
+			},
 		},
 	}
 
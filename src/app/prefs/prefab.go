@@ -75,6 +75,38 @@ func (p optionPrefPrefab) make() any {
 	return pref
 }
 
+type stringPrefPrefab struct {
+	name  string
+	desc  string
+	label string
+	hint  string
+	help  string
+	value *string
+	post  func(string)
+}
+
+func (p stringPrefPrefab) make() any {
+	pref := wsprefs.MakeStringPref()
+	pref.Name = p.name
+	pref.Desc = p.desc
+	pref.Label = p.label
+	pref.Hint = p.hint
+	pref.Help = p.help
+
+	pref.FGet = func() string {
+		return *p.value
+	}
+	pref.FSet = func(value string) {
+		log.Printf("[app] preferences changing, [%s] to: %s", p.label, value)
+		*p.value = value
+		if p.post != nil {
+			p.post(value)
+		}
+	}
+
+	return pref
+}
+
 type boolPrefPrefab struct {
 	name  string
 	desc  string
@@ -22,9 +22,12 @@ type Editor struct {
 	SaveFormat        string
 	NudgeMode         string
 	SanitizeVariables bool
+	DeterministicKeys bool
+	PreserveLayout    bool
 }
 
 type Application struct {
 	CheckForUpdates bool
 	AutoUpdate      bool
+	ExternalEditor  string
 }
@@ -7,10 +7,12 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"time"
 
 	"sdmm/app/ui/cpwsarea/workspace"
 	"sdmm/app/ui/dialog"
+	"sdmm/app/ui/layout/lnode"
 	"sdmm/app/window"
 	"sdmm/dmapi/dm"
 	"sdmm/imguiext/style"
@@ -32,6 +34,7 @@ func (a *app) loadResource(path string) {
 
 // Universal method to open any editor resource.
 // If it gets a map file, then the code will try to find an environment to open it.
+// Maps outside any environment are opened with the currently loaded one.
 func (a *app) loadResourceV(path string, ws *workspace.Workspace) {
 	path, err := filepath.Abs(path)
 	if err != nil {
@@ -51,15 +54,13 @@ func (a *app) loadResourceV(path string, ws *workspace.Workspace) {
 
 	environmentPath, err := findEnvironmentFileFromBase(path)
 
-	if a.HasLoadedEnvironment() {
-		a.loadMap(path, ws)
-		return
-	}
-
 	if err == nil {
-		a.loadEnvironmentV(environmentPath, func() {
-			a.loadMap(path, ws)
+		a.loadEnvironmentV(environmentPath, func(env *dmenv.Dme) {
+			a.loadMap(env, path, ws)
 		})
+	} else if a.HasLoadedEnvironment() {
+		log.Println("[app] unable to find environment from file, the loaded one is used:", path)
+		a.loadMap(a.loadedEnvironment, path, ws)
 	} else {
 		log.Println("[app] unable to find environment from file:", path)
 		dialog.Open(dialog.TypeInformation{
@@ -100,36 +101,46 @@ func (a *app) loadEnvironment(path string) {
 	a.loadEnvironmentV(path, nil)
 }
 
-func (a *app) loadEnvironmentV(path string, callback func()) {
-	a.closeEnvironment(func(closed bool) {
-		if closed {
-			a.forceLoadEnvironment(path, callback)
+// Loads the environment, if it's not loaded yet, and makes it the current one.
+func (a *app) loadEnvironmentV(path string, callback func(*dmenv.Dme)) {
+	if env, ok := a.findEnvironment(path); ok {
+		log.Println("[app] environment is already loaded:", path)
+		a.switchEnvironment(env)
+		if callback != nil {
+			callback(env)
 		}
-	})
+		return
+	}
+	a.forceLoadEnvironment(path, callback)
 }
 
-func (a *app) forceLoadEnvironment(path string, callback func()) {
+func (a *app) forceLoadEnvironment(path string, callback func(*dmenv.Dme)) {
 	log.Printf("[app] opening environment [%s]...", path)
 
-	afterLoad := func(env *dmenv.Dme) {
-		a.freeEnvironmentResources()
-
+	afterLoad := func(env *dmenv.Dme, stale bool) {
 		a.projectConfig().AddProject(path)
-		a.loadedEnvironment = env
-		a.pathsFilter = newPathsFilter(env)
 
-		dmicon.Cache.SetRootDirPath(env.RootDir)
-		dmmap.Init(env)
+		env.Context().Icons = dmicon.NewCache(env.RootDir)
+
+		a.environments = append(a.environments, env)
+		a.pathsFilters[env] = newPathsFilter(env)
+		a.watchEnvironment(env)
+		a.switchEnvironment(env)
+		a.showEnvironmentDiagnostics(env)
 
 		a.layout.WsArea.AddEmptyWorkspaceIfNone()
-		a.UpdateTitle()
 
 		runtime.GC()
 
 		log.Println("[app] environment opened:", path)
 
+		// The stale environment from the cache is used until the actual one is parsed.
+		if stale {
+			a.reloadEnvironment(env)
+		}
+
 		if callback != nil {
-			callback()
+			callback(env)
 		}
 	}
 
@@ -140,14 +151,13 @@ func (a *app) forceLoadEnvironment(path string, callback func()) {
 		start := time.Now()
 		log.Printf("[app] parsing environment: [%s]...", path)
 
-		env, err := dmenv.New(path)
+		env, stale, err := a.envCache.NewV(path)
 
 		if err != nil {
 			log.Println("[app] unable to open environment:", err)
 			dialog.Close(dlg)
-			dialog.Open(dialog.TypeInformation{
-				Title:       "Error!",
-				Information: "Unable to open environment: " + path,
+			window.RunLater(func() {
+				a.showEnvironmentError("Unable to open environment: " + path)
 			})
 			return
 		}
@@ -157,11 +167,27 @@ func (a *app) forceLoadEnvironment(path string, callback func()) {
 		dialog.Close(dlg)
 
 		window.RunLater(func() {
-			afterLoad(env)
+			afterLoad(env, stale)
 		})
 	}()
 }
 
+func (a *app) showEnvironmentError(information string) {
+	dialog.Open(dialog.TypeInformation{
+		Title:       "Error!",
+		Information: information,
+	})
+}
+
+// Errors of the environment code are shown in the Diagnostics panel, so they could be fixed.
+// The environment is usable anyway, since the parser recovers from errors.
+func (a *app) showEnvironmentDiagnostics(env *dmenv.Dme) {
+	if errors := env.Errors(); errors != 0 {
+		log.Printf("[app] environment [%s] has errors: [%d]", env.RootFile, errors)
+		a.ShowLayout(lnode.NameDiagnostics, true)
+	}
+}
+
 func makeLoadingDialog(path string) dialog.Type {
 	start := time.Now()
 	return dialog.TypeCustom{
@@ -181,6 +207,40 @@ func makeLoadingDialog(path string) dialog.Type {
 	}
 }
 
+func (a *app) findEnvironment(path string) (*dmenv.Dme, bool) {
+	for _, env := range a.environments {
+		if env.RootFile == path {
+			return env, true
+		}
+	}
+	return nil, false
+}
+
+// Makes the provided environment the current one, so panels of the application show its content.
+// Paths filter is kept for every environment and restored when the environment is switched back.
+func (a *app) switchEnvironment(env *dmenv.Dme) {
+	if a.loadedEnvironment == env {
+		return
+	}
+
+	a.loadedEnvironment = env
+	if pathsFilter, ok := a.pathsFilters[env]; ok {
+		a.pathsFilter = pathsFilter
+	} else {
+		a.pathsFilter = dm.NewPathsFilterEmpty()
+	}
+
+	// Panels show content of the previous environment, so they are reset.
+	a.layout.Prefabs.Free()
+	a.layout.Environment.Free()
+	a.layout.VarEditor.Free()
+	a.layout.Diagnostics.SyncEnvironment(env)
+
+	a.UpdateTitle()
+
+	log.Println("[app] environment switched:", a.environmentName())
+}
+
 // Configure paths filter to access a newly opened environment.
 func newPathsFilter(env *dmenv.Dme) *dm.PathsFilter {
 	return dm.NewPathsFilter(func(path string) []string {
@@ -188,7 +248,7 @@ func newPathsFilter(env *dmenv.Dme) *dm.PathsFilter {
 	})
 }
 
-func (a *app) loadMap(path string, workspace *workspace.Workspace) {
+func (a *app) loadMap(env *dmenv.Dme, path string, workspace *workspace.Workspace) {
 	log.Printf("[app] opening map [%s]...", path)
 
 	start := time.Now()
@@ -196,16 +256,40 @@ func (a *app) loadMap(path string, workspace *workspace.Workspace) {
 	data, err := dmmdata.New(path)
 	if err != nil {
 		log.Printf("[app] unable to open map by path [%s]: %v", path, err)
-		dialog.Open(dialog.TypeInformation{
-			Title:       "Error: Unable to open map",
-			Information: fmt.Sprintf("Error while parsing the map:\n - %s\n - %s", path, err),
-		})
+		if parseErr, ok := err.(*dmmdata.ParseError); ok {
+			dialog.Open(makeDamagedMapDialogType(path, parseErr.Diagnostics, func() {
+				a.loadDamagedMap(env, path, workspace)
+			}))
+		} else {
+			showUnableToOpenMapDialog(path, err)
+		}
 		return
 	}
 	elapsed := time.Since(start).Milliseconds()
 	log.Printf("[app] map [%s] parsed in [%d] ms", path, elapsed)
 
-	// Add map to the recent only if it is a part of the currently opened environment.
+	a.openMap(env, path, data, workspace)
+}
+
+// loadDamagedMap opens the map with problems in its content, so they could be repaired in the editor.
+func (a *app) loadDamagedMap(env *dmenv.Dme, path string, workspace *workspace.Workspace) {
+	log.Printf("[app] parsing damaged map: [%s]...", path)
+	data, diagnostics, err := dmmdata.NewTolerant(path)
+	if err != nil {
+		log.Printf("[app] unable to open damaged map by path [%s]: %v", path, err)
+		showUnableToOpenMapDialog(path, err)
+		return
+	}
+	log.Printf("[app] damaged map [%s] parsed with [%d] problems", path, len(diagnostics))
+
+	a.openMap(env, path, data, workspace)
+}
+
+func (a *app) openMap(env *dmenv.Dme, path string, data *dmmdata.DmmData, workspace *workspace.Workspace) {
+	// The map is shown with its environment.
+	a.switchEnvironment(env)
+
+	// Add map to the recent only if it is a part of its environment.
 	if slice.StrContains(a.AvailableMaps(), path) {
 		log.Println("[app] adding map path to the recent:", path)
 		cfg := a.projectConfig()
@@ -214,22 +298,27 @@ func (a *app) loadMap(path string, workspace *workspace.Workspace) {
 		log.Println("[app] ignoring map path add to the recent, since it's an outside resource")
 	}
 
-	dmm, unknownPrefabs := dmmap.New(a.loadedEnvironment, data, a.backupMap(path))
+	dmm, unknownPrefabs := dmmap.New(env.Context(), data, a.backupMap(env, path))
 	if a.layout.WsArea.OpenMap(dmm, workspace) {
 		a.layout.Prefabs.Sync()
 
-		// TODO: processing for unknown prefabs
 		if len(unknownPrefabs) != 0 {
-			var prefabsNames string
+			paths := make([]string, 0, len(unknownPrefabs))
 			for path := range unknownPrefabs {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+
+			var prefabsNames string
+			for _, path := range paths {
 				prefabsNames += " - " + path + "\n"
 			}
 
 			dialog.Open(dialog.TypeInformation{
-				Title: "Unknown Types [WIP]",
+				Title: "Unknown Types",
 				Information: fmt.Sprintf(
 					"There are unknown types on the map: %s\n"+
-						"Types below will be discarded on save:\n"+
+						"Types below are shown as placeholders and will be saved as is:\n"+
 						"%s", dmm.Name, prefabsNames,
 				),
 			})
@@ -242,38 +331,76 @@ func (a *app) loadMap(path string, workspace *workspace.Workspace) {
 	log.Println("[app] map opened:", path)
 }
 
-func (a *app) closeEnvironment(callback func(bool)) {
-	// NewMap workspaces depend on the opened environment, so we close them too.
-	a.layout.WsArea.CloseAllCreateMaps()
-	a.layout.WsArea.CloseAllMaps(func(closed bool) {
-		if callback != nil {
-			callback(closed)
-		}
+func showUnableToOpenMapDialog(path string, err error) {
+	dialog.Open(dialog.TypeInformation{
+		Title:       "Error: Unable to open map",
+		Information: fmt.Sprintf("Error while parsing the map:\n - %s\n - %s", path, err),
 	})
 }
 
-// Frees all resources connected with opened environment.
-func (a *app) freeEnvironmentResources() {
-	log.Println("[app] free environment resources...")
+// Only the first problems are shown, since a broken map could have thousands of them.
+const damagedMapDialogMaxProblems = 20
 
-	a.pathsFilter = dm.NewPathsFilterEmpty()
+func makeDamagedMapDialogType(path string, diagnostics []dmmdata.Diagnostic, actionYes func()) dialog.TypeConfirmation {
+	var problems string
+	for idx, diagnostic := range diagnostics {
+		if idx == damagedMapDialogMaxProblems {
+			problems += fmt.Sprintf(" ... and %d more\n", len(diagnostics)-idx)
+			break
+		}
+		problems += " - " + diagnostic.String() + "\n"
+	}
 
-	a.layout.Prefabs.Free()
-	a.layout.Search.Free()
-	a.layout.Environment.Free()
-	a.layout.WsArea.Free()
-	a.layout.VarEditor.Free()
+	return dialog.TypeConfirmation{
+		Title: "Damaged Map",
+		Question: fmt.Sprintf(
+			"There are problems in the map: %s\n"+
+				"%s"+
+				"Open the map anyway to repair it?\n"+
+				"Broken parts of the map will be skipped.", path, problems,
+		),
+		ActionYes: actionYes,
+	}
+}
+
+// Closes workspaces bound to the environment. Maps of other environments stay opened.
+func (a *app) closeEnvironment(env *dmenv.Dme, callback func(bool)) {
+	a.layout.WsArea.CloseEnvironmentWorkspaces(env, func(closed bool) {
+		if callback != nil {
+			callback(closed)
+		}
+	})
+}
 
-	a.commandStorage.Free()
-	a.clipboard.Free()
+// Frees resources connected with the environment. Its workspaces should be closed at this point.
+func (a *app) freeEnvironmentResources(env *dmenv.Dme) {
+	log.Println("[app] free environment resources:", env.RootFile)
 
-	dmicon.Cache.Free()
-	dmmap.PrefabStorage.Free()
-	dmmap.Free()
+	for idx, loadedEnv := range a.environments {
+		if loadedEnv == env {
+			a.environments = append(a.environments[:idx], a.environments[idx+1:]...)
+			break
+		}
+	}
+	delete(a.pathsFilters, env)
+	a.unwatchEnvironment(env)
+
+	// Switch to the last loaded environment, if there is any.
+	if a.loadedEnvironment == env {
+		var nextEnv *dmenv.Dme
+		if len(a.environments) != 0 {
+			nextEnv = a.environments[len(a.environments)-1]
+		}
+		a.switchEnvironment(nextEnv)
+		a.layout.Search.Free()
+		a.layout.Stats.Free()
+	}
 
-	a.loadedEnvironment = nil
+	if a.clipboard.Buffer().Context == env.Context() {
+		a.clipboard.Free()
+	}
 
-	a.UpdateTitle()
+	env.Context().Free()
 
 	log.Println("[app] environment resources free!")
 }
@@ -285,7 +412,7 @@ func (a *app) environmentName() string {
 	return ""
 }
 
-func (a *app) backupMap(path string) string {
+func (a *app) backupMap(env *dmenv.Dme, path string) string {
 	data, err := ioutil.ReadFile(path)
 	if err != nil {
 		log.Println("[app] unable to read map to backup:", path)
@@ -295,7 +422,7 @@ func (a *app) backupMap(path string) string {
 
 	// format: backup/environment.dme/map.dmm/time.dmm
 	dst := filepath.FromSlash(a.backupDir + "/" +
-		a.environmentName() + "/" +
+		env.Name + "/" +
 		filepath.Base(path) + "/" +
 		time.Now().Format("2006.01.02-15.04.05") + ".dmm",
 	)
@@ -0,0 +1,120 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"sdmm/app/ui/cpwsarea/wsmap"
+	"sdmm/app/ui/dialog"
+	"sdmm/app/window"
+	"sdmm/dmapi/dmenv"
+)
+
+// Environment code files are checked for modifications with the period.
+const environmentWatchPeriod = 2 * time.Second
+
+// envWatcher reloads the environment when its code is modified.
+// Its state is modified only by the main thread.
+type envWatcher struct {
+	watcher *dmenv.Watcher
+
+	reloading bool
+	// Code was modified during the reload, so the environment should be reloaded again.
+	pending bool
+}
+
+func (a *app) watchEnvironment(env *dmenv.Dme) {
+	watcher := dmenv.NewWatcher(env, func() {
+		window.RunLater(func() {
+			a.reloadEnvironment(env)
+		})
+	})
+	watcher.Start(environmentWatchPeriod)
+	a.envWatchers[env] = &envWatcher{watcher: watcher}
+}
+
+func (a *app) unwatchEnvironment(env *dmenv.Dme) {
+	if ew, ok := a.envWatchers[env]; ok {
+		ew.watcher.Stop()
+		delete(a.envWatchers, env)
+	}
+}
+
+// Parses the environment in the background and swaps its objects in place.
+// Opened maps of the environment are kept with their history.
+func (a *app) reloadEnvironment(env *dmenv.Dme) {
+	ew, ok := a.envWatchers[env]
+	if !ok {
+		return // The environment was closed.
+	}
+	if ew.reloading {
+		ew.pending = true
+		return
+	}
+
+	ew.reloading = true
+	log.Println("[app] reloading environment:", env.RootFile)
+
+	go func() {
+		start := time.Now()
+		parsed, err := a.envCache.New(env.RootFile)
+
+		window.RunLater(func() {
+			ew.reloading = false
+
+			if _, ok := a.envWatchers[env]; !ok {
+				return
+			}
+
+			if err != nil {
+				log.Println("[app] unable to reload environment:", err)
+				a.showEnvironmentError("Unable to reload environment: " + env.RootFile)
+			} else {
+				log.Printf("[app] environment [%s] parsed in [%d] ms", env.RootFile, time.Since(start).Milliseconds())
+				a.onEnvironmentReloaded(env, env.Reload(parsed))
+			}
+
+			if ew.pending {
+				ew.pending = false
+				a.reloadEnvironment(env)
+			}
+		})
+	}()
+}
+
+func (a *app) onEnvironmentReloaded(env *dmenv.Dme, removedPaths []string) {
+	if a.loadedEnvironment == env {
+		// Panels cache objects of the environment.
+		a.layout.Environment.Free()
+		a.layout.VarEditor.Free()
+		a.layout.Prefabs.Sync()
+		a.layout.Diagnostics.SyncEnvironment(env)
+		a.showEnvironmentDiagnostics(env)
+	}
+
+	// Variables of prefabs could be changed, so maps are rendered again.
+	for _, ws := range a.layout.WsArea.MapWorkspaces() {
+		if wsMap, ok := ws.Content().(*wsmap.WsMap); ok && wsMap.Map().Dmm().Context().Env() == env {
+			wsMap.Map().OnEnvironmentReload()
+		}
+	}
+
+	log.Println("[app] environment reloaded:", env.RootFile)
+
+	if len(removedPaths) != 0 {
+		var prefabsNames string
+		for _, path := range removedPaths {
+			prefabsNames += " - " + path + "\n"
+		}
+
+		dialog.Open(dialog.TypeInformation{
+			Title: "Removed Types",
+			Information: fmt.Sprintf(
+				"Types below were removed from the environment: %s\n"+
+					"They are used on opened maps, so they are shown as placeholders and will be saved as is:\n"+
+					"%s", env.Name, prefabsNames,
+			),
+		})
+	}
+}
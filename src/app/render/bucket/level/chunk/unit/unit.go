@@ -1,13 +1,19 @@
 package unit
 
 import (
+	"math"
+
 	"sdmm/dmapi/dm"
+	"sdmm/dmapi/dmenv"
 	"sdmm/dmapi/dmicon"
 	"sdmm/dmapi/dmmap/dmmdata/dmmprefab"
 	"sdmm/dmapi/dmmap/dmminstance"
 	"sdmm/util"
 )
 
+// Layer of instances with unknown types.
+const unknownLayer = math.MaxFloat32
+
 // Unit stores render information about specific object prefab on the map.
 type Unit struct {
 	sprite   *dmicon.Sprite
@@ -51,7 +57,10 @@ func (u Unit) A() float32 {
 	return u.a
 }
 
-func Make(x, y int, i *dmminstance.Instance, iconSize int) Unit {
+// Make creates a unit for the instance. Icons and the icon size are taken from the provided environment context.
+func Make(ctx *dmenv.Context, x, y int, i *dmminstance.Instance) Unit {
+	iconSize := ctx.WorldIconSize
+
 	// All vars below are built-in and expected to exist.
 	icon, _ := i.Prefab().Vars().Text("icon")
 	iconState, _ := i.Prefab().Vars().Text("icon_state")
@@ -61,7 +70,7 @@ func Make(x, y int, i *dmminstance.Instance, iconSize int) Unit {
 	stepX, _ := i.Prefab().Vars().Int("step_x")
 	stepY, _ := i.Prefab().Vars().Int("step_y")
 
-	sp := dmicon.Cache.GetSpriteOrPlaceholderV(icon, iconState, dir)
+	sp := dmicon.CacheOf(ctx).GetSpriteOrPlaceholderV(icon, iconState, dir)
 	x1 := float32((x-1)*iconSize + pixelX + stepX)
 	y1 := float32((y-1)*iconSize + pixelY + stepY)
 	x2 := x1 + float32(sp.IconWidth())
@@ -69,7 +78,7 @@ func Make(x, y int, i *dmminstance.Instance, iconSize int) Unit {
 	r, g, b, a := parseColor(i.Prefab())
 
 	return Unit{
-		sp, i, countLayer(i.Prefab()),
+		sp, i, countLayer(ctx, i.Prefab()),
 		util.Bounds{X1: x1, Y1: y1, X2: x2, Y2: y2},
 		r, g, b, a,
 	}
@@ -87,7 +96,12 @@ func parseColor(p *dmmprefab.Prefab) (r, g, b, a float32) {
 }
 
 // countLayer returns the value of combined prefab vars: plane + Layer.
-func countLayer(p *dmmprefab.Prefab) float32 {
+func countLayer(ctx *dmenv.Context, p *dmmprefab.Prefab) float32 {
+	// Unknown types are rendered as placeholders, which should never be hidden by other objects.
+	if !ctx.Env().IsKnownPath(p.Path()) {
+		return unknownLayer
+	}
+
 	plane, _ := p.Vars().Float("plane")
 	layer, _ := p.Vars().Float("layer")
 
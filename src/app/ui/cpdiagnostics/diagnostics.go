@@ -0,0 +1,62 @@
+package cpdiagnostics
+
+import (
+	"log"
+	"path/filepath"
+
+	"sdmm/dmapi/dmenv"
+)
+
+type App interface {
+	DoOpenInExternalEditor(path string, line int)
+}
+
+// Diagnostics is a panel with problems found by the parser in the code of the current environment.
+type Diagnostics struct {
+	app App
+
+	envPath     string
+	diagnostics []dmenv.Diagnostic
+
+	filter string
+
+	hideErrors   bool
+	hideWarnings bool
+	hideOther    bool
+}
+
+func (d *Diagnostics) Init(app App) {
+	d.app = app
+}
+
+func (d *Diagnostics) Free() {
+	d.envPath = ""
+	d.diagnostics = nil
+	d.filter = ""
+	log.Println("[cpdiagnostics] diagnostics free")
+}
+
+// SyncEnvironment shows diagnostics of the environment. A nil environment clears the panel.
+func (d *Diagnostics) SyncEnvironment(env *dmenv.Dme) {
+	if env == nil {
+		d.Free()
+		return
+	}
+	d.envPath = env.RootFile
+	d.diagnostics = env.Diagnostics
+	log.Printf("[cpdiagnostics] environment diagnostics [%s]: [%d]", env.RootFile, len(env.Diagnostics))
+}
+
+func (d *Diagnostics) openDiagnostic(diagnostic dmenv.Diagnostic) {
+	d.app.DoOpenInExternalEditor(filepath.Join(filepath.Dir(d.envPath), filepath.FromSlash(diagnostic.File)), diagnostic.Line)
+}
+
+func (d *Diagnostics) isHidden(severity dmenv.Severity) bool {
+	switch severity {
+	case dmenv.SeverityError:
+		return d.hideErrors
+	case dmenv.SeverityWarning:
+		return d.hideWarnings
+	}
+	return d.hideOther
+}
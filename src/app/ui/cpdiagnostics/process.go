@@ -0,0 +1,163 @@
+package cpdiagnostics
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"sdmm/dmapi/dmenv"
+	"sdmm/imguiext/icon"
+	"sdmm/imguiext/style"
+	w "sdmm/imguiext/widget"
+
+	"github.com/SpaiR/imgui-go"
+)
+
+func (d *Diagnostics) Process() {
+	if d.envPath == "" {
+		imgui.TextDisabled("No Environment Loaded")
+		return
+	}
+
+	d.showHeader()
+	d.showControls()
+	imgui.Separator()
+
+	if len(d.diagnostics) == 0 {
+		imgui.TextDisabled("No problems found")
+		return
+	}
+
+	if imgui.BeginChild("diagnostics") {
+		d.showDiagnostics()
+	}
+	imgui.EndChild()
+}
+
+func (d *Diagnostics) showHeader() {
+	imgui.Text(filepath.Base(d.envPath))
+
+	var errors, warnings, other int
+	for _, diagnostic := range d.diagnostics {
+		switch diagnostic.Severity {
+		case dmenv.SeverityError:
+			errors++
+		case dmenv.SeverityWarning:
+			warnings++
+		default:
+			other++
+		}
+	}
+
+	imgui.TextColored(style.ColorRed, fmt.Sprint("Errors: ", errors))
+	imgui.SameLine()
+	imgui.TextColored(style.ColorGold, fmt.Sprint("Warnings: ", warnings))
+	imgui.SameLine()
+	imgui.TextDisabled(fmt.Sprint("Other: ", other))
+
+	if errors != 0 {
+		imgui.PushTextWrapPos()
+		imgui.TextColored(style.ColorRed, "The environment has errors, so some of its objects could be missing or incomplete.")
+		imgui.PopTextWrapPos()
+	}
+}
+
+func (d *Diagnostics) showControls() {
+	w.Button(icon.FilterAlt, nil).
+		Round(true).
+		Tooltip("Severity").
+		Build()
+
+	if imgui.BeginPopupContextItemV("diagnostics_filter", imgui.PopupFlagsMouseButtonLeft) {
+		w.Layout{
+			w.MenuItem("Show errors", func() { d.hideErrors = !d.hideErrors }).
+				Selected(!d.hideErrors).
+				Enabled(true),
+			w.MenuItem("Show warnings", func() { d.hideWarnings = !d.hideWarnings }).
+				Selected(!d.hideWarnings).
+				Enabled(true),
+			w.MenuItem("Show other", func() { d.hideOther = !d.hideOther }).
+				Selected(!d.hideOther).
+				Enabled(true),
+		}.Build()
+
+		imgui.EndPopup()
+	}
+
+	imgui.SameLine()
+
+	w.InputTextWithHint("##filter", "Filter", &d.filter).
+		ButtonClear().
+		Width(-1).
+		Build()
+}
+
+const diagnosticsTableFlags = imgui.TableFlagsBordersInner | imgui.TableFlagsResizable | imgui.TableFlagsNoSavedSettings
+
+func (d *Diagnostics) showDiagnostics() {
+	if !imgui.BeginTableV("diagnostics", 3, diagnosticsTableFlags, imgui.Vec2{}, 0) {
+		return
+	}
+
+	imgui.TableSetupColumnV("Severity", imgui.TableColumnFlagsWidthFixed, 0, 0)
+	imgui.TableSetupColumnV("Location", imgui.TableColumnFlagsWidthFixed, 0, 0)
+	imgui.TableSetupColumnV("Message", imgui.TableColumnFlagsWidthStretch, 0, 0)
+	imgui.TableHeadersRow()
+
+	for idx, diagnostic := range d.diagnostics {
+		if d.isFiltered(diagnostic) {
+			continue
+		}
+
+		imgui.TableNextColumn()
+		d.showSeverity(idx, diagnostic)
+		imgui.TableNextColumn()
+		if diagnostic.File != "" {
+			imgui.Text(fmt.Sprintf("%s:%d", diagnostic.File, diagnostic.Line))
+		} else {
+			imgui.TextDisabled("builtins")
+		}
+		imgui.TableNextColumn()
+		imgui.PushTextWrapPos()
+		imgui.Text(diagnostic.Msg)
+		imgui.PopTextWrapPos()
+	}
+
+	imgui.EndTable()
+}
+
+// The whole row is selectable, so the problem place is opened on click.
+// Builtin locations have no files to open.
+func (d *Diagnostics) showSeverity(idx int, diagnostic dmenv.Diagnostic) {
+	switch diagnostic.Severity {
+	case dmenv.SeverityError:
+		imgui.PushStyleColor(imgui.StyleColorText, style.ColorRed)
+	case dmenv.SeverityWarning:
+		imgui.PushStyleColor(imgui.StyleColorText, style.ColorGold)
+	default:
+		imgui.PushStyleColor(imgui.StyleColorText, imgui.CurrentStyle().Color(imgui.StyleColorTextDisabled))
+	}
+
+	label := fmt.Sprint(diagnostic.Severity, "##diagnostic_", idx)
+	if diagnostic.File == "" {
+		imgui.Text(string(diagnostic.Severity))
+	} else if imgui.SelectableV(label, false, imgui.SelectableFlagsSpanAllColumns, imgui.Vec2{}) {
+		d.openDiagnostic(diagnostic)
+	}
+
+	imgui.PopStyleColor()
+
+	if diagnostic.File != "" && imgui.IsItemHovered() {
+		imgui.SetTooltip("Open in External Editor")
+	}
+}
+
+func (d *Diagnostics) isFiltered(diagnostic dmenv.Diagnostic) bool {
+	if d.isHidden(diagnostic.Severity) {
+		return true
+	}
+	if len(d.filter) == 0 {
+		return false
+	}
+	return !strings.Contains(diagnostic.Msg, d.filter) && !strings.Contains(diagnostic.File, d.filter)
+}
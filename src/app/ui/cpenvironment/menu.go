@@ -5,7 +5,6 @@ import (
 	"log"
 
 	"sdmm/app/ui/layout/lnode"
-	"sdmm/dmapi/dmmap"
 	"sdmm/imguiext/icon"
 	w "sdmm/imguiext/widget"
 
@@ -25,7 +24,7 @@ func (e *Environment) showNodeMenu(n *treeNode) {
 
 func (e *Environment) doFindOnMap(n *treeNode) func() {
 	return func() {
-		prefab := dmmap.PrefabStorage.Initial(n.orig.Path)
+		prefab := e.app.LoadedEnvironment().Context().Prefabs.Initial(n.orig.Path)
 		log.Println("[cpenvironment] do find object on map:", prefab.Path())
 		e.app.ShowLayout(lnode.NameSearch, true)
 		e.app.DoSearchPrefab(prefab.Id())
@@ -40,7 +40,7 @@ func (e *Environment) newTreeNode(object *dmenv.Object) (*treeNode, bool) {
 	node := &treeNode{
 		name:   object.Path[strings.LastIndex(object.Path, "/")+1:],
 		orig:   object,
-		sprite: dmicon.Cache.GetSpriteOrPlaceholder(icon, iconState),
+		sprite: dmicon.CacheOf(e.app.LoadedEnvironment().Context()).GetSpriteOrPlaceholder(icon, iconState),
 		color:  color,
 	}
 
@@ -7,7 +7,6 @@ import (
 
 	"sdmm/app/ui/layout/lnode"
 	"sdmm/dmapi/dm"
-	"sdmm/dmapi/dmmap"
 	"sdmm/dmapi/dmvars"
 	"sdmm/imguiext/icon"
 	w "sdmm/imguiext/widget"
@@ -87,15 +86,16 @@ func (p *Prefabs) doNew(node *prefabNode) func() {
 func (p *Prefabs) doDelete(node *prefabNode) func() {
 	return func() {
 		log.Println("[cpprefabs] do delete prefab:", node.orig.Id())
-		p.app.CurrentEditor().InstancesDeleteByPrefab(node.orig)
-		p.app.CurrentEditor().CommitChanges("Delete Instances")
-
-		// Delete the prefab from the prefabs list if it's not an initial one (which is always the first in the list).
-		if node.orig.Id() != p.nodes[0].orig.Id() {
-			dmmap.PrefabStorage.Delete(node.orig)
-			p.selectedId = p.nodes[0].orig.Id()
-			p.Sync()
-		}
+		p.app.CurrentEditor().Transaction("Delete Prefab", func() {
+			p.app.CurrentEditor().InstancesDeleteByPrefab(node.orig)
+			p.app.CurrentEditor().CommitChanges("Delete Instances")
+
+			// Delete the prefab from the prefabs list if it's not an initial one (which is always the first in the list).
+			if node.orig.Id() != p.nodes[0].orig.Id() {
+				p.selectedId = p.nodes[0].orig.Id()
+				p.app.CurrentEditor().DeletePrefab(node.orig)
+			}
+		})
 	}
 }
 
@@ -109,7 +109,7 @@ func (p *Prefabs) doGenerateIconStates(node *prefabNode) func() {
 				continue
 			}
 			vars := dmvars.Set(node.orig.Vars(), "icon_state", "\""+name+"\"")
-			dmmap.PrefabStorage.Get(node.orig.Path(), vars)
+			p.envContext().Prefabs.Get(node.orig.Path(), vars)
 		}
 
 		p.Sync()
@@ -143,7 +143,7 @@ func (p *Prefabs) doGenerateDirections(node *prefabNode) func() {
 				continue
 			}
 			vars := dmvars.Set(node.orig.Vars(), "dir", strconv.Itoa(dir))
-			dmmap.PrefabStorage.Get(node.orig.Path(), vars)
+			p.envContext().Prefabs.Get(node.orig.Path(), vars)
 		}
 
 		p.Sync()
@@ -5,8 +5,8 @@ import (
 	"strings"
 
 	"sdmm/dmapi/dm"
+	"sdmm/dmapi/dmenv"
 	"sdmm/dmapi/dmicon"
-	"sdmm/dmapi/dmmap"
 	"sdmm/dmapi/dmmap/dmmdata"
 	"sdmm/dmapi/dmmap/dmmdata/dmmprefab"
 	"sdmm/dmapi/dmvars"
@@ -23,10 +23,10 @@ type prefabNode struct {
 	visHeight float32
 }
 
-func newPrefabNodes(prefabs dmmdata.Prefabs) []*prefabNode {
+func newPrefabNodes(ctx *dmenv.Context, prefabs dmmdata.Prefabs) []*prefabNode {
 	nodes := make([]*prefabNode, 0, len(prefabs))
 	for _, prefab := range prefabs {
-		nodes = append(nodes, newPrefabNode(prefab))
+		nodes = append(nodes, newPrefabNode(ctx, prefab))
 	}
 
 	if nodes != nil {
@@ -52,8 +52,8 @@ func newPrefabNodes(prefabs dmmdata.Prefabs) []*prefabNode {
 
 		if idx == -1 {
 			// If the initial prefab index is still -1, then we don't have it.  We will add the one.
-			initialPrefab := dmmap.PrefabStorage.Initial(prefabs[0].Path())
-			nodes = append([]*prefabNode{newPrefabNode(initialPrefab)}, nodes...)
+			initialPrefab := ctx.Prefabs.Initial(prefabs[0].Path())
+			nodes = append([]*prefabNode{newPrefabNode(ctx, initialPrefab)}, nodes...)
 		} else {
 			// Move the initial prefab to the beginning of the slice
 			initial := nodes[idx]
@@ -65,11 +65,11 @@ func newPrefabNodes(prefabs dmmdata.Prefabs) []*prefabNode {
 	return nodes
 }
 
-func newPrefabNode(prefab *dmmprefab.Prefab) *prefabNode {
-	return newPrefabNodeV(prefab, prefab.Vars().TextV("name", dm.PathLast(prefab.Path())))
+func newPrefabNode(ctx *dmenv.Context, prefab *dmmprefab.Prefab) *prefabNode {
+	return newPrefabNodeV(ctx, prefab, prefab.Vars().TextV("name", dm.PathLast(prefab.Path())))
 }
 
-func newPrefabNodeV(prefab *dmmprefab.Prefab, name string) *prefabNode {
+func newPrefabNodeV(ctx *dmenv.Context, prefab *dmmprefab.Prefab, name string) *prefabNode {
 	icon, _ := prefab.Vars().Text("icon")
 	iconState, _ := prefab.Vars().Text("icon_state")
 	dir, _ := prefab.Vars().Int("dir")
@@ -77,7 +77,7 @@ func newPrefabNodeV(prefab *dmmprefab.Prefab, name string) *prefabNode {
 	return &prefabNode{
 		name:   name,
 		orig:   prefab,
-		sprite: dmicon.Cache.GetSpriteOrPlaceholderV(icon, iconState, dir),
+		sprite: dmicon.CacheOf(ctx).GetSpriteOrPlaceholderV(icon, iconState, dir),
 		color:  imgui.Vec4{X: r, Y: g, Z: b, W: 1},
 	}
 }
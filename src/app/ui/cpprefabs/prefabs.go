@@ -6,7 +6,7 @@ import (
 	"sdmm/app/ui/cpwsarea/wsmap/pmap/editor"
 	"sdmm/app/window"
 
-	"sdmm/dmapi/dmmap"
+	"sdmm/dmapi/dmenv"
 	"sdmm/dmapi/dmmap/dmmdata/dmmprefab"
 )
 
@@ -18,6 +18,7 @@ type App interface {
 	HasActiveMap() bool
 	ShowLayout(name string, focus bool)
 	CurrentEditor() *editor.Editor
+	LoadedEnvironment() *dmenv.Dme
 }
 
 type Prefabs struct {
@@ -39,11 +40,12 @@ func (p *Prefabs) Free() {
 }
 
 func (p *Prefabs) Select(prefab *dmmprefab.Prefab) {
-	p.nodes = newPrefabNodes(dmmap.PrefabStorage.GetAllByPath(prefab.Path()))
+	ctx := p.envContext()
+	p.nodes = newPrefabNodes(ctx, ctx.Prefabs.GetAllByPath(prefab.Path()))
 
 	// A special case for a "staged" prefab.
 	if prefab.Id() == dmmprefab.IdStage {
-		p.nodes = append(p.nodes, newPrefabNodeV(prefab, "[STAGED]"))
+		p.nodes = append(p.nodes, newPrefabNodeV(ctx, prefab, "[STAGED]"))
 	}
 
 	p.selectedId = prefab.Id()
@@ -53,12 +55,17 @@ func (p *Prefabs) Select(prefab *dmmprefab.Prefab) {
 
 func (p *Prefabs) Sync() {
 	if p.selectedId != dmmprefab.IdNone {
-		if prefab, ok := dmmap.PrefabStorage.GetById(p.selectedId); ok {
+		if prefab, ok := p.envContext().Prefabs.GetById(p.selectedId); ok {
 			p.Select(prefab)
 		}
 	}
 }
 
+// Prefabs are shown only for the loaded environment.
+func (p *Prefabs) envContext() *dmenv.Context {
+	return p.app.LoadedEnvironment().Context()
+}
+
 // SelectedPrefabId returns the id of the prefab currently selected in the Prefabs panel.
 func (p *Prefabs) SelectedPrefabId() uint64 {
 	return p.selectedId
@@ -8,7 +8,6 @@ import (
 
 	"sdmm/app/ui/layout/lnode"
 	"sdmm/app/window"
-	"sdmm/dmapi/dmmap"
 	"sdmm/imguiext/icon"
 	"sdmm/imguiext/style"
 	w "sdmm/imguiext/widget"
@@ -59,9 +58,7 @@ func (s *Search) doSearch() {
 	log.Println("[cpsearch] searching for:", s.prefabId)
 
 	if strings.HasPrefix(s.prefabId, "/") {
-		for _, prefab := range dmmap.PrefabStorage.GetAllByPath(s.prefabId) {
-			s.resultsAll = append(s.resultsAll, s.app.CurrentEditor().InstancesFindByPrefabId(prefab.Id())...)
-		}
+		s.resultsAll = s.app.CurrentEditor().InstancesFindByPath(s.prefabId)
 	} else {
 		prefabId, err := strconv.ParseUint(s.prefabId, 10, 64)
 		if err != nil {
@@ -0,0 +1,140 @@
+package cpstats
+
+import (
+	"fmt"
+	"strings"
+
+	"sdmm/app/ui/layout/lnode"
+	"sdmm/dmapi/dmmstats"
+	"sdmm/imguiext/icon"
+	"sdmm/imguiext/style"
+	w "sdmm/imguiext/widget"
+
+	"github.com/SpaiR/imgui-go"
+)
+
+func (s *Stats) Process() {
+	if s.app.CurrentEditor() == nil {
+		imgui.TextDisabled("No Map Opened")
+		return
+	}
+
+	s.showControls()
+	imgui.Separator()
+
+	if s.collecting {
+		imgui.TextDisabled("Collecting...")
+		return
+	}
+	if !s.isActual() {
+		imgui.TextDisabled("Press the button to collect statistics of the map")
+		return
+	}
+
+	if imgui.BeginChild("stats") {
+		s.showSummary()
+		s.showTypes()
+		s.showAreas()
+	}
+	imgui.EndChild()
+}
+
+func (s *Stats) showControls() {
+	w.Layout{
+		w.Button(icon.Repeat, s.Collect).
+			Round(true).
+			Tooltip("Collect"),
+		w.SameLine(),
+		w.InputTextWithHint("##filter", "Filter Types", &s.filter).
+			ButtonClear().
+			Width(-1),
+	}.Build()
+}
+
+const statsTableFlags = imgui.TableFlagsBordersInner | imgui.TableFlagsResizable | imgui.TableFlagsNoSavedSettings
+
+func (s *Stats) showSummary() {
+	if !imgui.CollapsingHeaderV("Summary", imgui.TreeNodeFlagsDefaultOpen) {
+		return
+	}
+
+	if imgui.BeginTableV("summary", 2, statsTableFlags, imgui.Vec2{}, 0) {
+		summaryRow("Size", fmt.Sprintf("%dx%dx%d", s.stats.MaxX, s.stats.MaxY, s.stats.MaxZ))
+		summaryRow("Tiles", fmt.Sprint(s.stats.Tiles))
+		summaryRow("Instances", fmt.Sprint(s.stats.Instances))
+		summaryRow("Unique Prefabs", fmt.Sprint(s.stats.UniquePrefabs))
+		summaryRow("Var-Edited Instances", fmt.Sprint(s.stats.VarEditedInstances))
+
+		keys := s.stats.Keys
+		imgui.TableNextColumn()
+		imgui.Text("Keys")
+		imgui.TableNextColumn()
+		if keys.Exceeded {
+			imgui.TextColored(style.ColorRed, fmt.Sprintf("%d/%d (limit exceeded)", keys.Count, keys.Capacity))
+		} else {
+			imgui.Text(fmt.Sprintf("%d/%d (key length: %d)", keys.Count, keys.Capacity, keys.KeyLength))
+		}
+
+		imgui.EndTable()
+	}
+}
+
+func summaryRow(name, value string) {
+	imgui.TableNextColumn()
+	imgui.Text(name)
+	imgui.TableNextColumn()
+	imgui.Text(value)
+}
+
+func (s *Stats) showTypes() {
+	if imgui.CollapsingHeaderV("Types", imgui.TreeNodeFlagsDefaultOpen) {
+		s.showPaths("types", s.stats.Paths)
+	}
+}
+
+func (s *Stats) showAreas() {
+	if !imgui.CollapsingHeader("Areas") {
+		return
+	}
+
+	for _, area := range s.stats.Areas {
+		label := fmt.Sprintf("%s (tiles: %d, instances: %d)", area.Path, area.Tiles, area.Instances)
+		if imgui.TreeNode(label) {
+			s.showPaths(area.Path, area.Paths)
+			imgui.TreePop()
+		}
+	}
+}
+
+func (s *Stats) showPaths(id string, paths []dmmstats.PathCount) {
+	if imgui.BeginTableV(id, 4, statsTableFlags, imgui.Vec2{}, 0) {
+		imgui.TableSetupColumnV("Type", imgui.TableColumnFlagsWidthStretch, 0, 0)
+		imgui.TableSetupColumnV("Count", imgui.TableColumnFlagsWidthFixed, 0, 0)
+		imgui.TableSetupColumnV("Total", imgui.TableColumnFlagsWidthFixed, 0, 0)
+		imgui.TableSetupColumnV("", imgui.TableColumnFlagsWidthFixed, 0, 0)
+		imgui.TableHeadersRow()
+
+		for _, path := range paths {
+			if len(s.filter) > 0 && !strings.Contains(path.Path, s.filter) {
+				continue
+			}
+
+			imgui.TableNextColumn()
+			imgui.AlignTextToFramePadding()
+			imgui.Text(path.Path)
+			imgui.TableNextColumn()
+			imgui.Text(fmt.Sprint(path.Count))
+			imgui.TableNextColumn()
+			imgui.Text(fmt.Sprint(path.Total))
+			imgui.TableNextColumn()
+
+			p := path.Path
+			w.Button(fmt.Sprint(icon.Search+"##search_", id, p), func() {
+				s.app.DoSearchPrefabByPath(p)
+				s.app.ShowLayout(lnode.NameSearch, true)
+			}).Round(true).Tooltip("Search").Build()
+		}
+
+		imgui.EndTable()
+	}
+}
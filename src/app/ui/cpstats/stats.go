@@ -0,0 +1,71 @@
+package cpstats
+
+import (
+	"log"
+
+	"sdmm/app/ui/cpwsarea/wsmap/pmap/editor"
+	"sdmm/app/window"
+	"sdmm/dmapi/dmmap"
+	"sdmm/dmapi/dmmstats"
+)
+
+type App interface {
+	CurrentEditor() *editor.Editor
+	DoSearchPrefabByPath(path string)
+	ShowLayout(name string, focus bool)
+}
+
+// Stats is a panel with statistics of the active map.
+// Statistics are collected on demand, since it's a full pass over the map.
+type Stats struct {
+	app App
+
+	dmm   *dmmap.Dmm
+	stats *dmmstats.Stats
+
+	collecting bool
+
+	filter string
+}
+
+func (s *Stats) Init(app App) {
+	s.app = app
+}
+
+func (s *Stats) Free() {
+	s.dmm = nil
+	s.stats = nil
+	s.filter = ""
+	log.Println("[cpstats] stats free")
+}
+
+// Collect collects statistics of the active map in the background.
+func (s *Stats) Collect() {
+	e := s.app.CurrentEditor()
+	if e == nil || s.collecting {
+		return
+	}
+
+	dmm := e.Dmm()
+	s.collecting = true
+
+	log.Println("[cpstats] collecting stats:", dmm.Name)
+
+	go func() {
+		stats := dmmstats.Collect(dmm)
+		window.RunLater(func() {
+			s.collecting = false
+			// The active map could be switched while stats were collecting.
+			if e := s.app.CurrentEditor(); e != nil && e.Dmm() == dmm {
+				s.dmm = dmm
+				s.stats = stats
+			}
+		})
+	}()
+}
+
+// Stats are shown only for the map they were collected for.
+func (s *Stats) isActual() bool {
+	e := s.app.CurrentEditor()
+	return s.stats != nil && e != nil && e.Dmm() == s.dmm
+}
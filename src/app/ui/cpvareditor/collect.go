@@ -46,36 +46,39 @@ func collectVariablesPaths(obj *dmenv.Object) (variablesPaths []string) {
 	return variablesPaths
 }
 
+// Vars are grouped by types which declare them.
 func collectVariablesNamesByPaths(dme *dmenv.Dme, variablesPaths []string) map[string][]string {
 	variablesNamesByPaths := make(map[string][]string)
 
 	for _, path := range variablesPaths {
 		obj := dme.Objects[path]
-		variablesNames := make([]string, 0, len(obj.Vars.Iterate()))
-
 		for _, varName := range obj.Vars.Iterate() {
-			if parent := obj.Parent(); parent != nil && isParentObjsHasVar0(parent, varName) {
-				continue
-			}
 			if slice.StrContains(unmodifiableVars, varName) {
 				continue
 			}
-			variablesNames = append(variablesNames, varName)
+			ownerPath := varOwnerPath(obj, varName, variablesPaths)
+			variablesNamesByPaths[ownerPath] = slice.StrPushUnique(variablesNamesByPaths[ownerPath], varName)
 		}
+	}
 
+	for _, variablesNames := range variablesNamesByPaths {
 		sort.Strings(variablesNames)
-		variablesNamesByPaths[path] = variablesNames
 	}
 
 	return variablesNamesByPaths
 }
 
-func isParentObjsHasVar0(obj *dmenv.Object, varName string) bool {
-	if slice.StrContains(obj.Vars.Iterate(), varName) {
-		return true
+// Returns a path of the type which declares the var.
+// Without a known declaration, it's the topmost type which has the var.
+func varOwnerPath(obj *dmenv.Object, varName string, variablesPaths []string) string {
+	if decl, ok := obj.VarDecl(varName); ok && slice.StrContains(variablesPaths, decl.Owner) {
+		return decl.Owner
 	}
-	if parent := obj.Parent(); parent != nil {
-		return isParentObjsHasVar0(parent, varName)
+	owner := obj
+	for parent := obj.Parent(); parent != nil; parent = parent.Parent() {
+		if slice.StrContains(parent.Vars.Iterate(), varName) {
+			owner = parent
+		}
 	}
-	return false
+	return owner.Path
 }
@@ -8,7 +8,9 @@ import (
 
 	"sdmm/platform"
 
+	"sdmm/dmapi/dmenv"
 	"sdmm/dmapi/dmvars"
+	"sdmm/dmapi/dmvars/dmvalue"
 	"sdmm/imguiext/icon"
 	"sdmm/imguiext/style"
 	w "sdmm/imguiext/widget"
@@ -118,6 +120,10 @@ func (v *VarEditor) showControls() {
 				Selected(cfg.ShowPins).
 				Enabled(true).
 				Shortcut(platform.KeyModName(), "3"),
+			w.MenuItem("Show tmp/const/static", v.doToggleShowHidden).
+				Selected(cfg.ShowHidden).
+				Enabled(true).
+				Shortcut(platform.KeyModName(), "4"),
 		}.Build()
 
 		imgui.EndPopup()
@@ -242,12 +248,47 @@ func (v *VarEditor) showVarName(varName string) {
 	} else {
 		imgui.Text(varName)
 	}
+	if imgui.IsItemHovered() {
+		if decl, ok := v.varDecl(varName); ok {
+			imgui.SetTooltip(declDescription(varName, decl))
+		}
+	}
+}
+
+// Returns the var declaration (like "var/tmp/list/cache") followed by the declaring type and the location.
+func declDescription(varName string, decl dmenv.VarDecl) string {
+	var sb strings.Builder
+	sb.WriteString("var")
+	if decl.Static {
+		sb.WriteString("/static")
+	}
+	if decl.Const {
+		sb.WriteString("/const")
+	}
+	if decl.Tmp {
+		sb.WriteString("/tmp")
+	}
+	sb.WriteString(decl.Type)
+	sb.WriteString("/" + varName)
+	sb.WriteString("\nDeclared by: " + decl.Owner)
+	if len(decl.File) != 0 {
+		sb.WriteString(fmt.Sprintf("\nLocation: %s:%d", decl.File, decl.Line))
+	} else {
+		sb.WriteString("\nLocation: builtin")
+	}
+	return sb.String()
 }
 
 func (v *VarEditor) showVarInput(varName string) {
 	varValue := v.currentVars().ValueV(varName, dmvars.NullValue)
 	initialValue := v.initialVarValue(varName)
-	isModified := initialValue != varValue
+	isModified := !dmvalue.Equal(initialValue, varValue)
+
+	// Malformed values are kept as they are, but highlighted to be noticed.
+	_, parseErr := dmvalue.Parse(varValue)
+	if parseErr != nil {
+		imgui.PushStyleColor(imgui.StyleColorText, style.ColorRed)
+	}
 
 	var resetBtn *w.ButtonWidget
 	if isModified {
@@ -264,6 +305,14 @@ func (v *VarEditor) showVarInput(varName string) {
 			v.setCurrentVariable(varName, varValue)
 		}).
 		Build()
+
+	if parseErr != nil {
+		imgui.PopStyleColor()
+		// With the reset button the last item is the button, which has its own tooltip.
+		if resetBtn == nil && imgui.IsItemHovered() {
+			imgui.SetTooltip(parseErr.Error())
+		}
+	}
 }
 
 func (v *VarEditor) setCurrentVariable(varName, varValue string) {
@@ -286,6 +335,12 @@ func (v *VarEditor) isFilteredVariable(varName string) bool {
 	if v.config().ShowModified && v.isCurrentVarInitial(varName) {
 		return true
 	}
+	// Show tmp/const/static vars only by request, unless they are modified
+	if !v.config().ShowHidden && v.isCurrentVarInitial(varName) {
+		if decl, ok := v.varDecl(varName); ok && decl.IsHidden() {
+			return true
+		}
+	}
 	// Show filtered by name only
 	if len(v.filterVarName) > 0 && !strings.Contains(varName, v.filterVarName) {
 		return true
@@ -8,10 +8,10 @@ import (
 	"sdmm/app/config"
 	"sdmm/app/ui/shortcut"
 	"sdmm/dmapi/dmenv"
-	"sdmm/dmapi/dmmap"
 	"sdmm/dmapi/dmmap/dmmdata/dmmprefab"
 	"sdmm/dmapi/dmmap/dmminstance"
 	"sdmm/dmapi/dmvars"
+	"sdmm/dmapi/dmvars/dmvalue"
 	"sdmm/util/slice"
 )
 
@@ -109,7 +109,9 @@ func (v *VarEditor) EditedInstance() (*dmminstance.Instance, bool) {
 func (v *VarEditor) setup(prefab *dmmprefab.Prefab) {
 	v.prefab = prefab
 	v.variablesNames = collectVariablesNames(prefab.Vars())
-	v.variablesPaths = collectVariablesPaths(v.app.LoadedEnvironment().Objects[v.prefab.Path()])
+	if obj, ok := v.app.LoadedEnvironment().Objects[v.prefab.Path()]; ok {
+		v.variablesPaths = collectVariablesPaths(obj)
+	}
 	v.variablesNamesByPaths = collectVariablesNamesByPaths(v.app.LoadedEnvironment(), v.variablesPaths)
 
 	// Clear pinned variables from the common list, since they are showed separately.
@@ -129,19 +131,19 @@ func (v *VarEditor) setInstanceVariable(varName, varValue string) {
 	origPrefab := v.instance.Prefab()
 
 	var newVars *dmvars.Variables
-	if v.initialVarValue(varName) == varValue {
+	if dmvalue.Equal(v.initialVarValue(varName), varValue) {
 		newVars = dmvars.Delete(origPrefab.Vars(), varName)
 	} else {
 		newVars = dmvars.Set(origPrefab.Vars(), varName, varValue)
 	}
 
-	newPrefab, isNew := dmmap.PrefabStorage.GetV(origPrefab.Path(), newVars)
+	newPrefab, isNew := v.app.LoadedEnvironment().Context().Prefabs.GetV(origPrefab.Path(), newVars)
 
 	// Newly created prefabs are sort of temporal objects, which need to exist only during the edit session.
 	// So if we modified a variable of the instance and that creates a new prefab, the previous one will be deleted.
 	if isNew {
 		if origPrefab.Id() == v.sessionPrefabId {
-			dmmap.PrefabStorage.Delete(origPrefab)
+			v.app.LoadedEnvironment().Context().Prefabs.Delete(origPrefab)
 		}
 		v.sessionPrefabId = newPrefab.Id()
 	}
@@ -161,13 +163,13 @@ func (v *VarEditor) setPrefabVariable(varName, varValue string) {
 	}
 
 	var newVars *dmvars.Variables
-	if v.initialVarValue(varName) == varValue {
+	if dmvalue.Equal(v.initialVarValue(varName), varValue) {
 		newVars = dmvars.Delete(v.prefab.Vars(), varName)
 	} else {
 		newVars = dmvars.Set(v.prefab.Vars(), varName, varValue)
 	}
 
-	newPrefab := dmmap.PrefabStorage.Get(v.prefab.Path(), newVars)
+	newPrefab := v.app.LoadedEnvironment().Context().Prefabs.Get(v.prefab.Path(), newVars)
 
 	v.app.CurrentEditor().ReplacePrefab(v.prefab, newPrefab)
 	v.app.CurrentEditor().CommitChanges("Replace Prefab")
@@ -191,11 +193,22 @@ func (v *VarEditor) resetSession() {
 }
 
 func (v *VarEditor) initialVarValue(varName string) string {
-	return v.app.LoadedEnvironment().Objects[v.prefab.Path()].Vars.ValueV(varName, dmvars.NullValue)
+	if obj, ok := v.app.LoadedEnvironment().Objects[v.prefab.Path()]; ok {
+		return obj.Vars.ValueV(varName, dmvars.NullValue)
+	}
+	return dmvars.NullValue // Unknown types have no initial values.
+}
+
+// The second value is false for unknown types and vars without a known declaration.
+func (v *VarEditor) varDecl(varName string) (dmenv.VarDecl, bool) {
+	if obj, ok := v.app.LoadedEnvironment().Objects[v.prefab.Path()]; ok {
+		return obj.VarDecl(varName)
+	}
+	return dmenv.VarDecl{}, false
 }
 
 func (v *VarEditor) isCurrentVarInitial(varName string) bool {
-	return v.currentVars().ValueV(varName, dmvars.NullValue) == v.initialVarValue(varName)
+	return dmvalue.Equal(v.currentVars().ValueV(varName, dmvars.NullValue), v.initialVarValue(varName))
 }
 
 func (v *VarEditor) doToggleShowModified() {
@@ -215,3 +228,9 @@ func (v *VarEditor) doToggleShowPins() {
 	cfg.ShowPins = !cfg.ShowPins
 	log.Println("[cpvareditor] toggle 'showPins':", cfg.ShowPins)
 }
+
+func (v *VarEditor) doToggleShowHidden() {
+	cfg := v.config()
+	cfg.ShowHidden = !cfg.ShowHidden
+	log.Println("[cpvareditor] toggle 'showHidden':", cfg.ShowHidden)
+}
@@ -16,6 +16,7 @@ import (
 	"sdmm/util"
 
 	"sdmm/app/command"
+	"sdmm/dmapi/dmenv"
 	"sdmm/dmapi/dmmap"
 )
 
@@ -54,12 +55,6 @@ func (w *WsArea) Init(app App) {
 	}
 }
 
-func (w *WsArea) Free() {
-	w.closeWorkspaces(w.findMapWorkspaces())
-	w.closeWorkspaces(w.findCreateMapWorkspaces()) // close new map creation as well
-	log.Println("[cpwsarea] workspace area free")
-}
-
 func (w *WsArea) OpenPreferences(prefsView wsprefs.Prefs) {
 	for _, ws := range w.workspaces {
 		if _, ok := ws.Content().(*wsprefs.WsPrefs); ok {
@@ -81,11 +76,9 @@ func (w *WsArea) OpenChangelog() {
 }
 
 func (w *WsArea) OpenCreateMap() {
-	for _, ws := range w.workspaces {
-		if _, ok := ws.Content().(*wscreatemap.WsCreateMap); ok {
-			ws.SetTriggerFocus(true)
-			return
-		}
+	if workspaces := w.findCreateMapWorkspaces(w.app.LoadedEnvironment()); len(workspaces) != 0 {
+		workspaces[0].SetTriggerFocus(true)
+		return
 	}
 
 	wsCnt := wscreatemap.New(w.app)
@@ -127,9 +120,12 @@ func (w *WsArea) CloseAllMaps(callback func(closed bool)) {
 	w.closeWorkspacesGentlyV(w.findMapWorkspaces(), callback)
 }
 
-func (w *WsArea) CloseAllCreateMaps() {
-	log.Println("[cpwsarea] closing all new maps...")
-	w.closeWorkspaces(w.findCreateMapWorkspaces())
+// CloseEnvironmentWorkspaces closes workspaces bound to the provided environment.
+func (w *WsArea) CloseEnvironmentWorkspaces(env *dmenv.Dme, callback func(closed bool)) {
+	log.Println("[cpwsarea] closing environment workspaces:", env.RootFile)
+	// NewMap workspaces depend on the environment, so we close them too.
+	w.closeWorkspaces(w.findCreateMapWorkspaces(env))
+	w.closeWorkspacesGentlyV(w.findEnvironmentMapWorkspaces(env), callback)
 }
 
 func (w *WsArea) WorkspaceTitle() string {
@@ -186,7 +182,13 @@ func (w *WsArea) closeWorkspacesGentlyV(wsToClose []*workspace.Workspace, callba
 
 	dType.ActionYes = func() {
 		for _, ws := range unsavedWorkspaces {
-			ws.Save()
+			// Keep workspaces opened if unable to save them, so the changes aren't lost.
+			if !ws.Save() {
+				if callback != nil {
+					callback(false)
+				}
+				return
+			}
 		}
 		w.closeWorkspaces(wsToClose)
 		if callback != nil {
@@ -231,7 +233,13 @@ func (w *WsArea) closeWorkspaceGentlyV(ws *workspace.Workspace, callback func(cl
 
 	dType := makeSaveSingleWorkspaceDialogType(ws)
 	dType.ActionYes = func() {
-		ws.Save()
+		// Keep the workspace opened if unable to save it, so the changes aren't lost.
+		if !ws.Save() {
+			if callback != nil {
+				callback(false)
+			}
+			return
+		}
 		w.closeWorkspace(ws)
 		if callback != nil {
 			callback(true)
@@ -327,10 +335,20 @@ func (w *WsArea) findMapWorkspaces() []*workspace.Workspace {
 	return workspaces
 }
 
-func (w *WsArea) findCreateMapWorkspaces() []*workspace.Workspace {
+func (w *WsArea) findEnvironmentMapWorkspaces(env *dmenv.Dme) []*workspace.Workspace {
+	var workspaces []*workspace.Workspace
+	for _, ws := range w.workspaces {
+		if wsCnt, ok := ws.Content().(*wsmap.WsMap); ok && wsCnt.Map().Dmm().Context().Env() == env {
+			workspaces = append(workspaces, ws)
+		}
+	}
+	return workspaces
+}
+
+func (w *WsArea) findCreateMapWorkspaces(env *dmenv.Dme) []*workspace.Workspace {
 	var workspaces []*workspace.Workspace
 	for _, ws := range w.workspaces {
-		if _, ok := ws.Content().(*wscreatemap.WsCreateMap); ok {
+		if wsCnt, ok := ws.Content().(*wscreatemap.WsCreateMap); ok && wsCnt.Environment() == env {
 			workspaces = append(workspaces, ws)
 		}
 	}
@@ -4,12 +4,11 @@ import (
 	"log"
 
 	"sdmm/app/prefs"
-	"sdmm/dmapi/dmmap"
 	"sdmm/dmapi/dmmap/dmmdata"
 	"sdmm/util"
 )
 
-func (ws *WsCreateMap) save(newPath string) {
+func (ws *WsCreateMap) save(newPath string) error {
 	log.Println("[wsnewmap] saving new map:", newPath)
 
 	// we assume the data is OK at this point
@@ -21,21 +20,22 @@ func (ws *WsCreateMap) save(newPath string) {
 		MaxY:       ws.mapHeight,
 		MaxZ:       ws.mapZDepth,
 		Dictionary: make(dmmdata.DataDictionary),
-		Grid:       make(dmmdata.DataGrid),
+		Grid:       dmmdata.NewDataGrid(ws.mapWidth, ws.mapHeight, ws.mapZDepth),
 	}
 
+	ctx := ws.env.Context()
 	data.Dictionary["a"] = dmmdata.Prefabs{
-		dmmap.BaseArea,
-		dmmap.BaseTurf,
+		ctx.BaseArea,
+		ctx.BaseTurf,
 	}
 
 	for z := 1; z <= data.MaxZ; z++ {
 		for y := 1; y <= data.MaxY; y++ {
 			for x := 1; x <= data.MaxX; x++ {
-				data.Grid[util.Point{X: x, Y: y, Z: z}] = "a"
+				data.Grid.Set(util.Point{X: x, Y: y, Z: z}, "a")
 			}
 		}
 	}
 
-	data.Save()
+	return data.Save()
 }
@@ -14,6 +14,7 @@ import (
 	"sdmm/imguiext/markdown"
 	"sdmm/imguiext/style"
 	w "sdmm/imguiext/widget"
+	"sdmm/util"
 
 	"github.com/SpaiR/imgui-go"
 	"github.com/sqweek/dialog"
@@ -31,6 +32,9 @@ type WsCreateMap struct {
 
 	app App
 
+	// The map is created for the environment which was loaded when the workspace was opened.
+	env *dmenv.Dme
+
 	mapWidth  int // x
 	mapHeight int // y
 	mapZDepth int // z
@@ -46,6 +50,7 @@ func New(app App) *WsCreateMap {
 
 	return &WsCreateMap{
 		app: app,
+		env: app.LoadedEnvironment(),
 
 		mapWidth:  1,
 		mapHeight: 1,
@@ -60,7 +65,12 @@ func (ws *WsCreateMap) Name() string {
 }
 
 func (ws *WsCreateMap) Title() string {
-	return ws.Name()
+	return ws.Name() + " - " + ws.env.Name
+}
+
+// Environment returns the environment the map is created for.
+func (ws *WsCreateMap) Environment() *dmenv.Dme {
+	return ws.env
 }
 
 func (ws *WsCreateMap) Process() {
@@ -123,7 +133,7 @@ func (ws *WsCreateMap) dmmSaveLocation() (string, error) {
 		File().
 		Title("New Map").
 		Filter(".dmm").
-		SetStartDir(ws.app.LoadedEnvironment().RootDir).
+		SetStartDir(ws.env.RootDir).
 		Save()
 }
 
@@ -139,7 +149,12 @@ func (ws *WsCreateMap) tryCreateMap() {
 
 		log.Println("[wsnewmap] saving new map to:", file)
 
-		ws.save(file)
+		if err = ws.save(file); err != nil {
+			log.Println("[wsnewmap] unable to save new map:", err)
+			util.ShowErrorDialog("Unable to save the map: " + err.Error())
+			return
+		}
+
 		ws.app.DoLoadResourceV(file, ws.Root())
 	} else {
 		log.Println("[wsnewmap] unable to get new map save location:", err)
@@ -0,0 +1,29 @@
+package wsmap
+
+import "log"
+
+// Restores the undo/redo stack of the map from the previous session.
+func (ws *WsMap) restoreHistory() {
+	history, ok := ws.app.LoadMapHistory(ws.paneMap.Dmm())
+	if !ok {
+		return
+	}
+
+	undo, redo, err := ws.paneMap.Editor().RestoreHistory(history)
+	if err != nil {
+		log.Printf("[wsmap] unable to restore history [%s]: %v", ws.CommandStackId(), err)
+		return
+	}
+
+	ws.app.CommandStorage().Restore(ws.CommandStackId(), undo, redo)
+	log.Println("[wsmap] history restored:", ws.CommandStackId())
+}
+
+// Stores the undo/redo stack of the map, so it could be restored in the next session.
+func (ws *WsMap) storeHistory() {
+	if history, ok := ws.paneMap.Snapshot().History(); ok {
+		ws.app.StoreMapHistory(ws.paneMap.Dmm(), history)
+	} else {
+		log.Println("[wsmap] history can't be stored:", ws.CommandStackId())
+	}
+}
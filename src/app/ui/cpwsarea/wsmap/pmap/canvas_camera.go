@@ -1,7 +1,6 @@
 package pmap
 
 import (
-	"sdmm/dmapi/dmmap"
 	"sdmm/imguiext"
 
 	"github.com/SpaiR/imgui-go"
@@ -65,7 +64,7 @@ func (p *PaneMap) calcManualCanvasTranslateShift() float32 {
 }
 
 func (p *PaneMap) calcManualCanvasTranslateShiftV(mod float32) float32 {
-	value := mod * float32(dmmap.WorldIconSize)
+	value := mod * float32(p.dmm.Context().WorldIconSize)
 	if imguiext.IsShiftDown() {
 		return value * 5
 	}
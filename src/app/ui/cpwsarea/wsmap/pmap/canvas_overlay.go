@@ -5,7 +5,6 @@ import (
 	"sdmm/app/ui/cpwsarea/wsmap/pmap/overlay"
 	"sdmm/app/ui/cpwsarea/wsmap/tools"
 	"sdmm/dmapi/dm"
-	"sdmm/dmapi/dmmap"
 	"sdmm/dmapi/dmmap/dmminstance"
 	"sdmm/util"
 
@@ -15,7 +14,11 @@ import (
 const flickDurationSec = .5
 
 func (p *PaneMap) processCanvasOverlay() {
-	p.processCanvasOverlayTools()
+	if p.mapInsert != nil {
+		p.processCanvasOverlayMapInsert()
+	} else {
+		p.processCanvasOverlayTools()
+	}
 	p.processCanvasOverlayFlick()
 	p.processCanvasOverlayAreasZones()
 }
@@ -106,7 +109,7 @@ func (p *PaneMap) processCanvasOverlayAreasZones() {
 
 			var borders []util.Bounds
 
-			iconSize := float32(dmmap.WorldIconSize)
+			iconSize := float32(p.dmm.Context().WorldIconSize)
 
 			x := float32(areaBorder.Coord.X-1) * iconSize
 			y := float32(areaBorder.Coord.Y-1) * iconSize
@@ -3,21 +3,26 @@ package editor
 import (
 	"sdmm/app/command"
 	"sdmm/app/window"
+	"sdmm/dmapi/dmmap/dmmdata/dmmprefab"
+	"sdmm/dmapi/dmmsnap"
 	"sdmm/util"
 )
 
-func (e *Editor) CommitMapSizeChange(oldMaxX, oldMaxY, oldMaxZ int) {
-	initialMapTiles := e.pMap.Snapshot().Initial().Copy().Tiles // Remember initial tiles to restore them on undo.
-	newMaxX, newMaxY, newMaxZ := e.dmm.MaxX, e.dmm.MaxY, e.dmm.MaxZ
+// Pushes a command to undo the map size change. The change is expected to be already done,
+// while the redo function repeats it on the map with the state before the change.
+func (e *Editor) commitMapSizeChange(commitMsg string, oldMaxX, oldMaxY, oldMaxZ int, redo func()) {
+	initialMap := e.pMap.Snapshot().Initial().Copy() // Remember initial tiles to restore them on undo.
+	newMaxZ := e.dmm.MaxZ
 
-	e.onMapSizeChange(e.dmm.MaxZ)
+	e.onMapSizeChange(newMaxZ)
 
-	e.app.CommandStorage().Push(command.Make("Set Map Size", func() {
+	e.app.CommandStorage().Push(command.Make(commitMsg, func() {
 		e.dmm.SetMapSize(oldMaxX, oldMaxY, oldMaxZ)
-		e.dmm.Tiles = initialMapTiles
+		// Restored tiles are modified by the following changes, so they are copied for every undo.
+		e.dmm.SetTiles(initialMap.Copy().Tiles)
 		e.onMapSizeChange(oldMaxZ)
 	}, func() {
-		e.dmm.SetMapSize(newMaxX, newMaxY, newMaxZ)
+		redo()
 		e.onMapSizeChange(newMaxZ)
 	}))
 }
@@ -34,26 +39,94 @@ func (e *Editor) onMapSizeChange(maxZ int) {
 
 // CommitChanges triggers a snapshot to commit changes and create a patch between two map states.
 func (e *Editor) CommitChanges(commitMsg string) {
+	// Commands of the map transaction should be pushed before its end, so the commit is done in place.
+	if e.app.CommandStorage().InTransaction(e.dmm.Path.Absolute) {
+		e.commitChanges(commitMsg)
+		return
+	}
 	go e.commitChanges(commitMsg)
 }
 
+// Transaction runs the action in a command transaction, so all changes committed by the action are a single command.
+// Changes are committed in place during the transaction, so the action could rely on the committed map state.
+// The transaction is started on the current stack, so the map is expected to be the active one.
+func (e *Editor) Transaction(name string, action func()) {
+	e.app.CommandStorage().BeginTransaction(name)
+	defer e.app.CommandStorage().EndTransaction()
+	action()
+}
+
+// DeletePrefab deletes the prefab from the environment storage with a command, so the prefab is restored on undo.
+// Instances of the prefab should be deleted from the map before that.
+func (e *Editor) DeletePrefab(prefab *dmmprefab.Prefab) {
+	prefabs := e.dmm.Context().Prefabs
+	prefabs.Delete(prefab)
+	e.app.SyncPrefabs()
+
+	e.app.CommandStorage().Push(command.Make("Delete Prefab", func() {
+		prefabs.Put(prefab)
+		e.app.SyncPrefabs()
+	}, func() {
+		prefabs.Delete(prefab)
+		e.app.SyncPrefabs()
+	}))
+}
+
 // Used as a wrapper to do a stuff inside the goroutine.
+// The snapshot is committed under the storage lock, so commands are pushed in the order of their states,
+// and undo/redo can't move the snapshot to another state before the command is pushed.
 func (e *Editor) commitChanges(commitMsg string) {
-	stateId, tilesToUpdate := e.pMap.Snapshot().Commit()
+	e.app.CommandStorage().PushFunc(func() (command.Command, bool) {
+		stateId, tilesToUpdate := e.pMap.Snapshot().Commit(commitMsg)
 
-	// Do not push command if there is no tiles to update.
-	if len(tilesToUpdate) == 0 {
-		return
+		// Do not push command if there is no tiles to update.
+		if len(tilesToUpdate) == 0 {
+			return command.Command{}, false
+		}
+
+		// Copy the value to pass it to the lambda.
+		activeLevel := e.pMap.ActiveLevel()
+
+		// Ensure that the user has updated visuals.
+		e.updateAreasZones()
+		e.updateBucket(activeLevel, tilesToUpdate)
+
+		return e.makePatchCommand(commitMsg, stateId, activeLevel, tilesToUpdate).
+			WithTime(e.pMap.Snapshot().PatchTime(stateId)), true
+	})
+}
+
+// RestoreHistory restores snapshot patches from the history of the previous session.
+// Returns commands for the restored patches, which should be placed to the undo and redo stacks.
+func (e *Editor) RestoreHistory(history dmmsnap.History) (undo, redo []command.Command, err error) {
+	snapshot := e.pMap.Snapshot()
+	if err = snapshot.RestoreHistory(history); err != nil {
+		return nil, nil, err
 	}
 
-	// Copy the value to pass it to the lambda.
-	activeLevel := e.pMap.ActiveLevel()
+	for stateId := 1; stateId <= len(history.Patches); stateId++ {
+		tilesToUpdate := snapshot.PatchCoords(stateId)
 
-	// Ensure that the user has updated visuals.
-	e.updateAreasZones()
-	e.updateBucket(activeLevel, tilesToUpdate)
+		// Commits are made on the active level, so every tile of the patch is on the same level.
+		activeLevel := e.pMap.ActiveLevel()
+		if len(tilesToUpdate) != 0 {
+			activeLevel = tilesToUpdate[0].Z
+		}
 
-	e.app.CommandStorage().Push(command.Make(commitMsg, func() {
+		cmd := e.makePatchCommand(snapshot.PatchName(stateId), stateId, activeLevel, tilesToUpdate).
+			WithTime(snapshot.PatchTime(stateId))
+		if stateId <= history.StateId {
+			undo = append(undo, cmd)
+		} else {
+			redo = append(redo, cmd)
+		}
+	}
+
+	return undo, redo, nil
+}
+
+func (e *Editor) makePatchCommand(commitMsg string, stateId, activeLevel int, tilesToUpdate []util.Point) command.Command {
+	return command.Make(commitMsg, func() {
 		e.pMap.Snapshot().GoTo(stateId - 1)
 		e.updateAreasZones()
 		e.updateBucket(activeLevel, tilesToUpdate)
@@ -67,7 +140,7 @@ func (e *Editor) commitChanges(commitMsg string) {
 		e.dmm.PersistPrefabs()
 		e.app.SyncPrefabs()
 		e.app.SyncVarEditor()
-	}))
+	}).WithCoords(tilesToUpdate)
 }
 
 // We need to update bucket in the main thread, since it can have OpenGL operations.
@@ -138,7 +138,8 @@ func (e *Editor) ReplacePrefab(oldPrefab, newPrefab *dmmprefab.Prefab) {
 // FocusCamera moves the camera in a way, so it will be centered on the instance.
 func (e *Editor) FocusCamera(i *dmminstance.Instance) {
 	relPos := i.Coord()
-	absPos := util.Point{X: (relPos.X - 1) * -dmmap.WorldIconSize, Y: (relPos.Y - 1) * -dmmap.WorldIconSize, Z: relPos.Z}
+	iconSize := e.dmm.Context().WorldIconSize
+	absPos := util.Point{X: (relPos.X - 1) * -iconSize, Y: (relPos.Y - 1) * -iconSize, Z: relPos.Z}
 
 	camera := e.pMap.Canvas().Render().Camera
 	camera.ShiftX = e.pMap.Size().X/2/camera.Scale + float32(absPos.X)
@@ -92,17 +92,15 @@ func (e *Editor) InstanceReplace(i *dmminstance.Instance, prefab *dmmprefab.Pref
 
 // InstanceReset resets the provided instance to the initial state (no custom variables).
 func (e *Editor) InstanceReset(i *dmminstance.Instance) {
-	i.SetPrefab(dmmap.PrefabStorage.Initial(i.Prefab().Path()))
+	i.SetPrefab(e.dmm.Context().Prefabs.Initial(i.Prefab().Path()))
 }
 
 // InstancesFindByPrefabId returns all instances from the current map with a corresponding prefab ID.
-func (e *Editor) InstancesFindByPrefabId(prefabId uint64) (result []*dmminstance.Instance) {
-	for _, tile := range e.dmm.Tiles {
-		for _, instance := range tile.Instances() {
-			if instance.Prefab().Id() == prefabId {
-				result = append(result, instance)
-			}
-		}
-	}
-	return result
+func (e *Editor) InstancesFindByPrefabId(prefabId uint64) []*dmminstance.Instance {
+	return e.dmm.InstancesByPrefabId(prefabId)
+}
+
+// InstancesFindByPath returns all instances from the current map with a corresponding type path.
+func (e *Editor) InstancesFindByPath(path string) []*dmminstance.Instance {
+	return e.dmm.InstancesByPath(path, false)
 }
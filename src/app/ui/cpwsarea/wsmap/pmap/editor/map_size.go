@@ -0,0 +1,41 @@
+package editor
+
+import (
+	"log"
+
+	"sdmm/dmapi/dmmap"
+)
+
+// SetMapSize changes the size of the map. The content of the map is placed with respect to the anchor.
+// Commits the change.
+func (e *Editor) SetMapSize(maxX, maxY, maxZ int, anchor dmmap.Anchor) {
+	log.Printf("[editor] set map size: [%dx%dx%d], anchor: [%d]", maxX, maxY, maxZ, anchor)
+	oldMaxX, oldMaxY, oldMaxZ := e.dmm.MaxX, e.dmm.MaxY, e.dmm.MaxZ
+	e.dmm.SetMapSizeV(maxX, maxY, maxZ, anchor)
+	e.commitMapSizeChange("Set Map Size", oldMaxX, oldMaxY, oldMaxZ, func() {
+		e.dmm.SetMapSizeV(maxX, maxY, maxZ, anchor)
+	})
+}
+
+// InsertLevel inserts a new z-level at the provided position. Commits the change.
+func (e *Editor) InsertLevel(z int) {
+	log.Println("[editor] insert level:", z)
+	oldMaxX, oldMaxY, oldMaxZ := e.dmm.MaxX, e.dmm.MaxY, e.dmm.MaxZ
+	e.dmm.InsertLevel(z)
+	e.commitMapSizeChange("Insert Level", oldMaxX, oldMaxY, oldMaxZ, func() {
+		e.dmm.InsertLevel(z)
+	})
+}
+
+// DeleteLevel removes the provided z-level. The last z-level of the map can't be removed. Commits the change.
+func (e *Editor) DeleteLevel(z int) {
+	if e.dmm.MaxZ == 1 {
+		return
+	}
+	log.Println("[editor] delete level:", z)
+	oldMaxX, oldMaxY, oldMaxZ := e.dmm.MaxX, e.dmm.MaxY, e.dmm.MaxZ
+	e.dmm.DeleteLevel(z)
+	e.commitMapSizeChange("Delete Level", oldMaxX, oldMaxY, oldMaxZ, func() {
+		e.dmm.DeleteLevel(z)
+	})
+}
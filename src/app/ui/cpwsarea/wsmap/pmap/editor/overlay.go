@@ -2,7 +2,6 @@ package editor
 
 import (
 	"sdmm/app/ui/cpwsarea/wsmap/pmap/overlay"
-	"sdmm/dmapi/dmmap"
 	"sdmm/dmapi/dmmap/dmminstance"
 	"sdmm/util"
 
@@ -21,11 +20,12 @@ func (e *Editor) OverlayPushTile(coord util.Point, colFill, colBorder util.Color
 
 // OverlayPushArea pushes area overlay for the next frame.
 func (e *Editor) OverlayPushArea(area util.Bounds, colFill, colBorder util.Color) {
+	iconSize := float32(e.dmm.Context().WorldIconSize)
 	e.pMap.PushAreaHover(util.Bounds{
-		X1: (area.X1 - 1) * float32(dmmap.WorldIconSize),
-		Y1: (area.Y1 - 1) * float32(dmmap.WorldIconSize),
-		X2: (area.X2-1)*float32(dmmap.WorldIconSize) + float32(dmmap.WorldIconSize),
-		Y2: (area.Y2-1)*float32(dmmap.WorldIconSize) + float32(dmmap.WorldIconSize),
+		X1: (area.X1 - 1) * iconSize,
+		Y1: (area.Y1 - 1) * iconSize,
+		X2: (area.X2-1)*iconSize + iconSize,
+		Y2: (area.Y2-1)*iconSize + iconSize,
 	}, colFill, colBorder)
 }
 
@@ -33,13 +33,14 @@ func (e *Editor) OverlayPushArea(area util.Bounds, colFill, colBorder util.Color
 // Unlike the PushOverlayTile or PushOverlayArea methods, flick overlay is set only once.
 // It will exist until it disappears.
 func (e *Editor) OverlaySetTileFlick(coord util.Point) {
+	iconSize := e.dmm.Context().WorldIconSize
 	e.flickAreas = append(e.flickAreas, overlay.FlickArea{
 		Time: imgui.Time(),
 		Area: util.Bounds{
-			X1: float32((coord.X - 1) * dmmap.WorldIconSize),
-			Y1: float32((coord.Y - 1) * dmmap.WorldIconSize),
-			X2: float32((coord.X-1)*dmmap.WorldIconSize + dmmap.WorldIconSize),
-			Y2: float32((coord.Y-1)*dmmap.WorldIconSize + dmmap.WorldIconSize),
+			X1: float32((coord.X - 1) * iconSize),
+			Y1: float32((coord.Y - 1) * iconSize),
+			X2: float32((coord.X-1)*iconSize + iconSize),
+			Y2: float32((coord.Y-1)*iconSize + iconSize),
 		},
 	})
 }
@@ -0,0 +1,37 @@
+package editor
+
+import (
+	"errors"
+	"log"
+
+	"sdmm/app/ui/cpwsarea/wsmap/tools"
+	"sdmm/dmapi/dmmap"
+	"sdmm/dmapi/dmmap/dmmdata"
+	"sdmm/util"
+)
+
+// HasSelectedArea returns true if there is an area selected with the tools.ToolGrab.
+func (e *Editor) HasSelectedArea() bool {
+	_, _, ok := tools.SelectedArea()
+	return ok
+}
+
+// ExtractSelected returns a standalone map data with the content of the area selected with the tools.ToolGrab.
+func (e *Editor) ExtractSelected() (*dmmdata.DmmData, error) {
+	area, zLevel, ok := tools.SelectedArea()
+	if !ok {
+		return nil, errors.New("no selected area")
+	}
+	return e.dmm.Extract(area, zLevel, zLevel)
+}
+
+// InsertMap places the content of the provided map data on the map.
+// The bottom-left tile of the data is placed on the provided coord. Commits map changes.
+func (e *Editor) InsertMap(data *dmmdata.DmmData, coord util.Point, opts dmmap.InsertOptions) {
+	log.Printf("[editor] insert map [%s] at: %v", data.Filepath, coord)
+
+	if coords := e.dmm.Insert(data, coord, opts); len(coords) != 0 {
+		e.app.SyncPrefabs() // Inserted map could have prefabs unknown for the current one.
+		e.CommitChanges("Insert Map")
+	}
+}
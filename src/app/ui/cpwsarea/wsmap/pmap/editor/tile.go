@@ -2,8 +2,10 @@ package editor
 
 import (
 	"log"
+	"strings"
 
 	"sdmm/app/ui/cpwsarea/wsmap/tools"
+	"sdmm/app/ui/dialog"
 
 	"sdmm/dmapi/dmmap"
 	"sdmm/dmapi/dmmap/dmmdata"
@@ -19,6 +21,7 @@ func (e *Editor) TileCopySelected() {
 // TilePasteSelected does a paste to the currently hovered tile.
 // Pasted tiles will be automatically selected by the tools.ToolGrab.
 // Respects a dm.PathsFilter state.
+// Tiles copied from a map of another environment are remapped by their types paths.
 func (e *Editor) TilePasteSelected() {
 	pasteCoord := e.pMap.CanvasState().LastHoveredTile()
 	pastedData := e.app.Clipboard().Buffer()
@@ -29,8 +32,18 @@ func (e *Editor) TilePasteSelected() {
 
 	log.Printf("[pmap] paste tiles from the clipboard buffer on the map: %v", pasteCoord)
 
+	buffer, missingPaths := pastedData.Remap(e.dmm.Context())
+	if len(missingPaths) != 0 {
+		log.Printf("[pmap] types missing in the map environment were skipped: %v", missingPaths)
+		dialog.Open(dialog.TypeInformation{
+			Title: "Missing Types",
+			Information: "Types below are missing in the map environment and weren't pasted:\n" +
+				" - " + strings.Join(missingPaths, "\n - "),
+		})
+	}
+
 	// Fill copied tiles from the bottom-left tile.
-	anchor := pastedData.Buffer[0].Coord
+	anchor := buffer[0].Coord
 
 	// Select a "select" tool and reset its selection.
 	toolSelect := tools.SetSelected(tools.TNGrab).(*tools.ToolGrab)
@@ -41,7 +54,7 @@ func (e *Editor) TilePasteSelected() {
 
 	// Calculate tiles positions.
 	var tilesToSelect []util.Point
-	for _, tileCopy := range pastedData.Buffer {
+	for _, tileCopy := range buffer {
 		pos := util.Point{
 			X: pasteCoord.X + tileCopy.Coord.X - anchor.X,
 			Y: pasteCoord.Y + tileCopy.Coord.Y - anchor.Y,
@@ -72,8 +85,10 @@ func (e *Editor) TilePasteSelected() {
 			}
 		}
 
-		// And append copied instances.
-		newTilePrefabs = append(newTilePrefabs, tileCopy.Instances().Prefabs()...)
+		// And append copied instances. The clipboard could be transformed, so its prefabs are stored.
+		for _, prefab := range tileCopy.Instances().Prefabs() {
+			newTilePrefabs = append(newTilePrefabs, e.dmm.Context().Prefabs.Put(prefab))
+		}
 
 		tile.InstancesSet(newTilePrefabs.Sorted())
 		tile.InstancesRegenerate()
@@ -0,0 +1,23 @@
+package editor
+
+import (
+	"log"
+
+	"sdmm/app/ui/cpwsarea/wsmap/tools"
+	"sdmm/dmapi/dmmap"
+)
+
+// TransformSelected rotates or flips the area selected with the tools.ToolGrab. Commits map changes.
+// Returns false, if there is no selected area or the transformed area doesn't fit the map.
+func (e *Editor) TransformSelected(transform dmmap.Transform) bool {
+	toolGrab, ok := tools.Selected().(*tools.ToolGrab)
+	if !ok || !toolGrab.Transform(transform) {
+		return false
+	}
+
+	log.Println("[editor] selected area transformed:", transform)
+
+	e.app.SyncPrefabs() // Transformed instances could have new prefabs.
+	e.CommitChanges(transform.String())
+	return true
+}
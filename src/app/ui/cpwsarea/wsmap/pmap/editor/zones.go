@@ -27,14 +27,10 @@ func (e *Editor) updateAreasZones() {
 
 	areas := make(map[string]coords)
 
-	for _, tile := range e.dmm.Tiles {
-		for _, instance := range tile.Instances() {
-			if path := instance.Prefab().Path(); dm.IsPath(path, "/area") {
-				if _, ok := areas[path]; !ok {
-					areas[path] = make(coords)
-				}
-				areas[path][tile.Coord] = true
-			}
+	for path, areaCoords := range e.dmm.Index().Areas() {
+		areas[path] = make(coords, len(areaCoords))
+		for _, coord := range areaCoords {
+			areas[path][coord] = true
 		}
 	}
 
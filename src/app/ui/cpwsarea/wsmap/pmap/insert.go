@@ -0,0 +1,102 @@
+package pmap
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"sdmm/app/ui/cpwsarea/wsmap/pmap/overlay"
+	"sdmm/app/ui/cpwsarea/wsmap/tools"
+	"sdmm/dmapi/dmmap"
+	"sdmm/dmapi/dmmap/dmmdata"
+	w "sdmm/imguiext/widget"
+	"sdmm/util"
+
+	"github.com/SpaiR/imgui-go"
+)
+
+// mapInsert is a state of the map data placement.
+// While it exists, tools are suspended and the placement preview follows the mouse.
+type mapInsert struct {
+	name string
+	data *dmmdata.DmmData
+	opts dmmap.InsertOptions
+
+	// The insert is finished, but tools are resumed only when the mouse is released,
+	// so the click which has placed the map won't be handled by the selected tool.
+	finished bool
+}
+
+// StartMapInsert starts the placement of the provided map data on the map.
+// The data is inserted on the left mouse click on the canvas.
+func (p *PaneMap) StartMapInsert(data *dmmdata.DmmData) {
+	log.Println("[pmap] start map insert:", data.Filepath)
+	p.mapInsert = &mapInsert{name: filepath.Base(data.Filepath), data: data}
+	tools.SetSuspended(true)
+}
+
+func (p *PaneMap) isMapInserting() bool {
+	return p.mapInsert != nil && !p.mapInsert.finished
+}
+
+func (p *PaneMap) doPlaceMapInsert() {
+	if !p.isMapInserting() || p.canvasState.HoverOutOfBounds() {
+		return
+	}
+	coord := p.canvasState.HoveredTile()
+	log.Println("[pmap] place map insert:", coord)
+	p.editor.InsertMap(p.mapInsert.data, coord, p.mapInsert.opts)
+	p.mapInsert.finished = true
+}
+
+func (p *PaneMap) doCancelMapInsert() {
+	if p.isMapInserting() {
+		log.Println("[pmap] cancel map insert")
+		p.mapInsert.finished = true
+	}
+}
+
+func (p *PaneMap) processMapInsert() {
+	if p.mapInsert != nil && p.mapInsert.finished && !imgui.IsMouseDown(imgui.MouseButtonLeft) {
+		p.stopMapInsert()
+	}
+}
+
+func (p *PaneMap) stopMapInsert() {
+	if p.mapInsert != nil {
+		p.mapInsert = nil
+		tools.SetSuspended(false)
+		log.Println("[pmap] map insert stopped")
+	}
+}
+
+func (p *PaneMap) processCanvasOverlayMapInsert() {
+	if !p.isMapInserting() || p.canvasState.HoverOutOfBounds() {
+		return
+	}
+
+	// The hovered tile is where the bottom-left tile of the inserted map will be placed.
+	coord := p.canvasState.HoveredTile()
+	p.editor.OverlayPushArea(util.Bounds{
+		X1: float32(coord.X),
+		Y1: float32(coord.Y),
+		X2: float32(coord.X + p.mapInsert.data.MaxX - 1),
+		Y2: float32(coord.Y + p.mapInsert.data.MaxY - 1),
+	}, overlay.ColorMapInsertFill, overlay.ColorMapInsertBorder)
+}
+
+func (p *PaneMap) showMapInsertPanel() {
+	data := p.mapInsert.data
+
+	w.Layout{
+		w.Text(fmt.Sprintf("Insert: %s [%dx%dx%d]", p.mapInsert.name, data.MaxX, data.MaxY, data.MaxZ)),
+		w.Separator(),
+		w.Custom(func() {
+			imgui.Checkbox("Skip Areas", &p.mapInsert.opts.SkipAreas)
+			imgui.Checkbox("Skip Turfs", &p.mapInsert.opts.SkipTurfs)
+		}),
+		w.TextDisabled("LMB: place / Esc: cancel"),
+		w.Button("Cancel", p.doCancelMapInsert).
+			Size(imgui.Vec2{X: -1}),
+	}.Build()
+}
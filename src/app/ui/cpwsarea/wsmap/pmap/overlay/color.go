@@ -35,4 +35,7 @@ var (
 	ColorFlickInstance = util.MakeColor(0, 1, 0, 1)
 
 	ColorAreaBorder = util.MakeColor(1, 1, 1, 1)
+
+	ColorMapInsertFill   = util.MakeColor(0, .5, 1, 0.25)
+	ColorMapInsertBorder = util.MakeColor(0, .5, 1, 1)
 )
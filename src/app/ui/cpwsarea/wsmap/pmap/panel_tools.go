@@ -142,25 +142,36 @@ func (p *PaneMap) panelToolsLayoutTools() (layout w.Layout) {
 }
 
 func (p *PaneMap) panelToolsLayoutSettings() w.Layout {
-	var bntStyle w.ButtonStyle
-	if p.showSettings {
-		bntStyle = style.ButtonGreen{}
-	} else {
-		bntStyle = style.ButtonDefault{}
-	}
 	return w.Layout{
+		w.Button(icon.AccessTime, p.doToggleHistory).
+			Tooltip("History").
+			Style(toggleButtonStyle(p.showHistory)).
+			Round(true),
+		w.SameLine(),
 		w.Button(icon.Cog, p.doToggleSettings).
 			Tooltip("Settings").
-			Style(bntStyle).
+			Style(toggleButtonStyle(p.showSettings)).
 			Round(true),
 	}
 }
 
+func toggleButtonStyle(toggled bool) w.ButtonStyle {
+	if toggled {
+		return style.ButtonGreen{}
+	}
+	return style.ButtonDefault{}
+}
+
 func (p *PaneMap) doToggleSettings() {
 	log.Println("[pmap] toggle settings:")
 	p.showSettings = !p.showSettings
 }
 
+func (p *PaneMap) doToggleHistory() {
+	log.Println("[pmap] toggle history:")
+	p.showHistory = !p.showHistory
+}
+
 func (p *PaneMap) doPreviousLevel() {
 	if p.hasPreviousLevel() {
 		p.activeLevel--
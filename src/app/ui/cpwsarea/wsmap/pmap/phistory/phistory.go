@@ -0,0 +1,122 @@
+package phistory
+
+import (
+	"fmt"
+	"log"
+
+	"sdmm/app/command"
+	"sdmm/app/window"
+	"sdmm/dmapi/dmmap"
+	"sdmm/util"
+
+	"github.com/SpaiR/imgui-go"
+)
+
+type App interface {
+	CommandStorage() *command.Storage
+}
+
+type editor interface {
+	Dmm() *dmmap.Dmm
+	OverlaySetTileFlick(coord util.Point)
+}
+
+type Panel struct {
+	app App
+
+	editor editor
+
+	// Affected tiles flick only once, when the entry becomes hovered.
+	lastHoveredIdx int
+}
+
+func New(app App, editor editor) *Panel {
+	return &Panel{app: app, editor: editor, lastHoveredIdx: -1}
+}
+
+const tableFlags = imgui.TableFlagsBordersInner | imgui.TableFlagsNoSavedSettings
+
+func (p *Panel) Process() {
+	commands, applied := p.app.CommandStorage().Commands(p.stackId())
+
+	hoveredIdx := -1
+
+	imgui.BeginChildV("history", imgui.Vec2{X: p.width(), Y: p.height()}, false, imgui.WindowFlagsNone)
+	if imgui.BeginTableV("history_commands", 3, tableFlags, imgui.Vec2{}, 0) {
+		imgui.TableSetupColumnV("Name", imgui.TableColumnFlagsWidthStretch, 0, 0)
+		imgui.TableSetupColumnV("Time", imgui.TableColumnFlagsWidthFixed, 0, 0)
+		imgui.TableSetupColumnV("Tiles", imgui.TableColumnFlagsWidthFixed, 0, 0)
+
+		// The initial state is the state before the first command.
+		imgui.TableNextRow()
+		imgui.TableNextColumn()
+		if imgui.SelectableV("Initial State", applied == 0, imgui.SelectableFlagsSpanAllColumns, imgui.Vec2{}) {
+			p.doGoTo(0)
+		}
+
+		for idx, cmd := range commands {
+			imgui.TableNextRow()
+			imgui.TableNextColumn()
+
+			// Undone commands are shown as disabled, since they are available only to redo.
+			undone := idx >= applied
+			if undone {
+				imgui.PushStyleColor(imgui.StyleColorText, imgui.CurrentStyle().Color(imgui.StyleColorTextDisabled))
+			}
+
+			label := fmt.Sprint(cmd.ReadableName(), "##history_command_", idx)
+			if imgui.SelectableV(label, idx+1 == applied, imgui.SelectableFlagsSpanAllColumns, imgui.Vec2{}) {
+				p.doGoTo(idx + 1)
+			}
+			if imgui.IsItemHovered() {
+				hoveredIdx = idx
+			}
+
+			imgui.TableNextColumn()
+			imgui.Text(cmd.Time().Format("15:04:05"))
+
+			imgui.TableNextColumn()
+			if len(cmd.Coords()) != 0 {
+				imgui.Text(fmt.Sprint(len(cmd.Coords())))
+			} else {
+				imgui.Text("-")
+			}
+
+			if undone {
+				imgui.PopStyleColor()
+			}
+		}
+
+		imgui.EndTable()
+	}
+	imgui.EndChild()
+
+	if hoveredIdx != -1 && hoveredIdx != p.lastHoveredIdx {
+		p.flickTiles(commands[hoveredIdx].Coords())
+	}
+	p.lastHoveredIdx = hoveredIdx
+}
+
+// Command stack of the map is identified by the absolute path of the map.
+func (p *Panel) stackId() string {
+	return p.editor.Dmm().Path.Absolute
+}
+
+func (p *Panel) doGoTo(applied int) {
+	log.Printf("[phistory] go to [%s]: %d", p.editor.Dmm().Name, applied)
+	p.app.CommandStorage().GoTo(p.stackId(), applied)
+}
+
+func (p *Panel) flickTiles(coords []util.Point) {
+	for _, coord := range coords {
+		p.editor.OverlaySetTileFlick(coord)
+	}
+}
+
+func (p *Panel) width() float32 {
+	return window.PointSize() * 300
+}
+
+func (p *Panel) height() float32 {
+	return window.PointSize() * 250
+}
@@ -8,6 +8,7 @@ import (
 	"sdmm/app/render"
 	"sdmm/app/ui/cpwsarea/wsmap/pmap/canvas"
 	"sdmm/app/ui/cpwsarea/wsmap/pmap/editor"
+	"sdmm/app/ui/cpwsarea/wsmap/pmap/phistory"
 	"sdmm/app/ui/cpwsarea/wsmap/pmap/pquickedit"
 	"sdmm/app/ui/cpwsarea/wsmap/pmap/psettings"
 	"sdmm/app/ui/cpwsarea/wsmap/pmap/tilemenu"
@@ -29,6 +30,7 @@ type App interface {
 	tilemenu.App
 	pquickedit.App
 	psettings.App
+	phistory.App
 
 	Prefs() prefs.Prefs
 
@@ -87,8 +89,10 @@ type PaneMap struct {
 
 	pQuickEdit *pquickedit.Panel
 	pSettings  *psettings.Panel
+	pHistory   *phistory.Panel
 
 	showSettings bool
+	showHistory  bool
 
 	canvas        *canvas.Canvas
 	canvasState   *canvas.State
@@ -111,6 +115,9 @@ type PaneMap struct {
 	// The value of the Z-level with which the user is currently working.
 	activeLevel int
 
+	// The state of the map data placement. Nil when there is nothing to insert.
+	mapInsert *mapInsert
+
 	tmpLastHoveredInstance *dmminstance.Instance
 }
 
@@ -177,12 +184,14 @@ func New(app App, dmm *dmmap.Dmm) *PaneMap {
 
 	p.pQuickEdit = pquickedit.New(app, p.editor)
 	p.pSettings = psettings.New(app, p.editor)
+	p.pHistory = phistory.New(app, p.editor)
 
 	p.canvas = canvas.New()
-	p.canvasState = canvas.NewState(dmm.MaxX, dmm.MaxY, dmmap.WorldIconSize)
+	p.canvasState = canvas.NewState(dmm.MaxX, dmm.MaxY, dmm.Context().WorldIconSize)
 	p.canvasControl = canvas.NewControl()
 	p.canvasOverlay = canvas.NewOverlay()
 
+	p.canvasControl.SetOnLmbClick(p.doPlaceMapInsert)
 	p.canvasControl.SetOnRmbClick(p.openTileMenu)
 
 	p.canvas.Render().SetOverlay(p.canvasOverlay)
@@ -213,6 +222,7 @@ func (p *PaneMap) Process() {
 	p.canvasControl.Process(p.size)
 	p.canvas.Process(p.size)
 
+	p.processMapInsert()
 	p.processCanvasCamera()
 	p.processCanvasOverlay()
 	p.processCanvasHoveredInstance()
@@ -222,6 +232,8 @@ func (p *PaneMap) Process() {
 	p.showCanvas()
 	p.showPanel("canvasTool_"+p.dmm.Name, pPosTop, p.showToolsPanel)
 	p.showPanelV("settings_"+p.dmm.Name, pPosRightTop, p.showSettings, p.pSettings.Process)
+	p.showPanelV("history_"+p.dmm.Name, pPosLeftTop, p.showHistory && !p.isMapInserting(), p.pHistory.Process)
+	p.showPanelV("mapInsert_"+p.dmm.Name, pPosLeftTop, p.isMapInserting(), p.showMapInsertPanel)
 	p.showPanelV(
 		"quickEdit_"+p.dmm.Name,
 		pPosRightBottom,
@@ -236,6 +248,7 @@ func (p *PaneMap) Dispose() {
 		lastActivePane = nil
 	}
 
+	p.stopMapInsert()
 	p.syncActiveCamera()
 	p.syncActivePane()
 	p.canvas.Dispose()
@@ -302,6 +315,7 @@ func (p *PaneMap) OnActivate() {
 func (p *PaneMap) OnDeactivate() {
 	p.focused = false
 	p.active = false
+	p.stopMapInsert()
 	tools.Selected().OnDeselect()
 	p.syncActiveCamera()
 	p.syncActivePane()
@@ -335,6 +349,11 @@ func (p *PaneMap) reloadCanvas() {
 	p.canvasState.SetMaxY(p.dmm.MaxY)
 }
 
+// OnEnvironmentReload re-renders the map, since variables of its prefabs could be changed.
+func (p *PaneMap) OnEnvironmentReload() {
+	p.reloadCanvas()
+}
+
 func (p *PaneMap) OnMapSizeChange() {
 	p.reloadCanvas()
 	p.pSettings.DropSessionMapSize()
@@ -14,6 +14,7 @@ import (
 	"sdmm/dmapi/dmmap/dmmdata/dmmprefab"
 	"sdmm/dmapi/dmmap/dmminstance"
 	"sdmm/dmapi/dmvars"
+	"sdmm/dmapi/dmvars/dmvalue"
 	"sdmm/util"
 
 	"github.com/SpaiR/imgui-go"
@@ -92,9 +93,9 @@ func (p *Panel) showNudgeOption(label string, xAxis bool, instance *dmminstance.
 	}
 	applyChange := func() {
 		p.sanitizeInstanceVar(instance, nudgeVarName, "0")
-		dmmap.PrefabStorage.Put(instance.Prefab())
+		p.editor.Dmm().Context().Prefabs.Put(instance.Prefab())
 		p.editor.InstanceSelect(instance)
-		go p.editor.CommitChanges("Quick Edit: " + label)
+		p.editor.CommitChanges("Quick Edit: " + label)
 	}
 
 	imgui.SetNextItemWidth(window.PointSize() * 50)
@@ -169,9 +170,9 @@ func (p *Panel) showDirOption(instance *dmminstance.Instance) {
 	}
 	applyChange := func() {
 		p.sanitizeInstanceVar(instance, "dir", "0")
-		dmmap.PrefabStorage.Put(instance.Prefab())
+		p.editor.Dmm().Context().Prefabs.Put(instance.Prefab())
 		p.editor.InstanceSelect(instance)
-		go p.editor.CommitChanges("Quick Edit: Dir")
+		p.editor.CommitChanges("Quick Edit: Dir")
 	}
 
 	imgui.SetNextItemWidth(window.PointSize() * 50)
@@ -213,20 +214,23 @@ func (p *Panel) isScrollEdit() bool {
 
 func (p *Panel) sanitizeInstanceVar(instance *dmminstance.Instance, varName, defaultValue string) {
 	vars := instance.Prefab().Vars()
-	if p.initialVarValue(instance.Prefab().Path(), varName) == vars.ValueV(varName, defaultValue) {
+	if dmvalue.Equal(p.initialVarValue(instance.Prefab().Path(), varName), vars.ValueV(varName, defaultValue)) {
 		vars = dmvars.Delete(vars, varName)
 		instance.SetPrefab(dmmprefab.New(dmmprefab.IdNone, instance.Prefab().Path(), vars))
 	}
 }
 
 func (p *Panel) initialVarValue(path, varName string) string {
-	return p.app.LoadedEnvironment().Objects[path].Vars.ValueV(varName, dmvars.NullValue)
+	if obj, ok := p.editor.Dmm().Context().Env().Objects[path]; ok {
+		return obj.Vars.ValueV(varName, dmvars.NullValue)
+	}
+	return dmvars.NullValue // Unknown types have no initial values.
 }
 
 func (p *Panel) getIconMaxDirs(vars *dmvars.Variables) int32 {
 	icon := vars.TextV("icon", "")
 	iconState := vars.TextV("icon_state", "")
-	state, err := dmicon.Cache.GetState(icon, iconState)
+	state, err := dmicon.CacheOf(p.editor.Dmm().Context()).GetState(icon, iconState)
 	if err != nil {
 		return 0
 	}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"math"
 
+	"sdmm/dmapi/dmmap"
 	"sdmm/imguiext"
 	"sdmm/imguiext/style"
 	w "sdmm/imguiext/widget"
@@ -18,12 +19,16 @@ const (
 	possibleMaxZ = math.MaxInt
 )
 
+// Labels of anchors in the order of their declaration.
+var anchorLabels = []string{"NW", "N", "NE", "W", "C", "E", "SW", "S", "SE"}
+
 type sessionMapSize struct {
 	maxX, maxY, maxZ int32
+	anchor           dmmap.Anchor
 }
 
 func (s sessionMapSize) String() string {
-	return fmt.Sprintf("maxX: %d, maxY: %d, maxZ: %d", s.maxX, s.maxY, s.maxZ)
+	return fmt.Sprintf("maxX: %d, maxY: %d, maxZ: %d, anchor: %s", s.maxX, s.maxY, s.maxZ, anchorLabels[s.anchor])
 }
 
 func (p *Panel) DropSessionMapSize() {
@@ -37,6 +42,8 @@ func (p *Panel) showMapSize() {
 				maxX: int32(p.editor.Dmm().MaxX),
 				maxY: int32(p.editor.Dmm().MaxY),
 				maxZ: int32(p.editor.Dmm().MaxZ),
+				// By default, the map is grown or cut at the max edge.
+				anchor: dmmap.AnchorSouthWest,
 			}
 		}
 
@@ -58,20 +65,75 @@ func (p *Panel) showMapSize() {
 		imgui.SetNextItemWidth(-1)
 		imguiext.InputIntClamp("##max_z", &p.sessionMapSize.maxZ, 1, possibleMaxZ, 1, 10)
 
+		imgui.Text("Anchor")
+		p.showAnchors()
+
 		imgui.Separator()
 
 		w.Button("Set", p.doSetMapSize).
 			Style(style.ButtonGreen{}).
 			Build()
+
+		imgui.Separator()
+
+		imgui.Text(fmt.Sprintf("Level: %d", p.editor.ActiveLevel()))
+		w.Layout{
+			w.Button("Insert Below", p.doInsertLevelBelow),
+			w.SameLine(),
+			w.Button("Insert Above", p.doInsertLevelAbove),
+		}.Build()
+		w.Button("Delete", p.doDeleteLevel).
+			Style(style.ButtonRed{}).
+			Build()
+		imguiext.SetItemHoveredTooltip("Delete the active level")
 	} else {
 		p.sessionMapSize = nil
 	}
 }
 
+// Shows anchors as a 3x3 grid, where the selected anchor is highlighted.
+func (p *Panel) showAnchors() {
+	for idx, label := range anchorLabels {
+		anchor := dmmap.Anchor(idx)
+
+		if idx%3 != 0 {
+			imgui.SameLine()
+		}
+
+		btn := w.Button(label+"##anchor", func() {
+			p.sessionMapSize.anchor = anchor
+		}).Size(imgui.Vec2{X: imgui.FrameHeight() * 1.5})
+		if anchor == p.sessionMapSize.anchor {
+			btn.Style(style.ButtonGreen{})
+		}
+		btn.Build()
+	}
+}
+
 func (p *Panel) doSetMapSize() {
 	log.Printf("[psettings] do set map size [%s]: %v", p.editor.Dmm().Name, p.sessionMapSize)
-	oldMaxX, oldMaxY, oldMaxZ := p.editor.Dmm().MaxX, p.editor.Dmm().MaxY, p.editor.Dmm().MaxZ
-	p.editor.Dmm().SetMapSize(int(p.sessionMapSize.maxX), int(p.sessionMapSize.maxY), int(p.sessionMapSize.maxZ))
-	p.editor.CommitMapSizeChange(oldMaxX, oldMaxY, oldMaxZ)
+	p.editor.SetMapSize(int(p.sessionMapSize.maxX), int(p.sessionMapSize.maxY), int(p.sessionMapSize.maxZ), p.sessionMapSize.anchor)
+	p.sessionMapSize = nil
+}
+
+func (p *Panel) doInsertLevelBelow() {
+	log.Printf("[psettings] do insert level below [%s]: %d", p.editor.Dmm().Name, p.editor.ActiveLevel())
+	p.editor.InsertLevel(p.editor.ActiveLevel())
+	p.sessionMapSize = nil
+}
+
+func (p *Panel) doInsertLevelAbove() {
+	log.Printf("[psettings] do insert level above [%s]: %d", p.editor.Dmm().Name, p.editor.ActiveLevel())
+	p.editor.InsertLevel(p.editor.ActiveLevel() + 1)
+	p.sessionMapSize = nil
+}
+
+func (p *Panel) doDeleteLevel() {
+	if p.editor.Dmm().MaxZ == 1 {
+		log.Printf("[psettings] unable to delete the last level [%s]", p.editor.Dmm().Name)
+		return
+	}
+	log.Printf("[psettings] do delete level [%s]: %d", p.editor.Dmm().Name, p.editor.ActiveLevel())
+	p.editor.DeleteLevel(p.editor.ActiveLevel())
 	p.sessionMapSize = nil
 }
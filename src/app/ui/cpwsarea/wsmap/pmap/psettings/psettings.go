@@ -19,7 +19,9 @@ type editor interface {
 	ActiveLevel() int
 
 	Dmm() *dmmap.Dmm
-	CommitMapSizeChange(oldMaxX, oldMaxY, oldMaxZ int)
+	SetMapSize(maxX, maxY, maxZ int, anchor dmmap.Anchor)
+	InsertLevel(z int)
+	DeleteLevel(z int)
 }
 
 type Panel struct {
@@ -10,7 +10,6 @@ import (
 	"sdmm/app/render/bucket/level/chunk/unit"
 	"sdmm/app/ui/cpwsarea/wsmap/pmap/canvas"
 	appdialog "sdmm/app/ui/dialog"
-	"sdmm/dmapi/dmmap"
 	"sdmm/imguiext/icon"
 	"sdmm/imguiext/style"
 	w "sdmm/imguiext/widget"
@@ -55,7 +54,8 @@ func (p *Panel) showScreenshot() {
 func (p *Panel) createScreenshot() {
 	p.sessionScreenshot.saving = true
 
-	width, height := p.editor.Dmm().MaxX*dmmap.WorldIconSize, p.editor.Dmm().MaxY*dmmap.WorldIconSize
+	dmm := p.editor.Dmm()
+	width, height := dmm.MaxX*dmm.Context().WorldIconSize, dmm.MaxY*dmm.Context().WorldIconSize
 
 	c := canvas.New()
 	c.ClearColor = canvas.Color{} // Empty clear color with no alpha
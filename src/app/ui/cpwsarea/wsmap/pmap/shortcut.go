@@ -38,6 +38,13 @@ func (p *PaneMap) addShortcuts() {
 		Action:      p.DoDeselect,
 	})
 
+	p.shortcuts.Add(shortcut.Shortcut{
+		Name:      "pmap#doCancelMapInsert",
+		FirstKey:  glfw.KeyEscape,
+		Action:    p.doCancelMapInsert,
+		IsEnabled: p.isMapInserting,
+	})
+
 	p.shortcuts.Add(shortcut.Shortcut{
 		Name:         "pmap#doToggleArea",
 		FirstKey:     platform.KeyModLeft(),
@@ -72,7 +72,7 @@ func (t *TileMenu) showControls() {
 
 func (t *TileMenu) showInstance(i *dmminstance.Instance, idx int) {
 	p := i.Prefab()
-	s := getSprite(p)
+	s := t.getSprite(p)
 	iconSize := t.iconSize()
 	r, g, b, _ := util.ParseColor(p.Vars().TextV("color", "")).RGBA()
 	name := fmt.Sprintf("%s##prefab_row_%d", p.Vars().TextV("name", ""), idx)
@@ -201,8 +201,8 @@ func (t *TileMenu) doSearchByPrefabID(i *dmminstance.Instance) func() {
 	}
 }
 
-func getSprite(i *dmmprefab.Prefab) *dmicon.Sprite {
-	return dmicon.Cache.GetSpriteOrPlaceholderV(
+func (t *TileMenu) getSprite(i *dmmprefab.Prefab) *dmicon.Sprite {
+	return dmicon.CacheOf(t.editor.Dmm().Context()).GetSpriteOrPlaceholderV(
 		i.Vars().TextV("icon", ""),
 		i.Vars().TextV("icon_state", ""),
 		i.Vars().IntV("dir", dm.DirDefault),
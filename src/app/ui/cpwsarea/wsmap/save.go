@@ -5,11 +5,47 @@ import (
 
 	"sdmm/app/prefs"
 	"sdmm/dmapi/dmmsave"
+	"sdmm/util"
 )
 
 func (ws *WsMap) Save() bool {
 	log.Println("[wsmap] saving map workspace:", ws.CommandStackId())
 
+	err := dmmsave.Save(ws.paneMap.Dmm().Context(), ws.paneMap.Dmm(), ws.saveConfig())
+	if err != nil {
+		log.Printf("[wsmap] unable to save map workspace [%s]: %v", ws.CommandStackId(), err)
+		util.ShowErrorDialog("Unable to save the map: " + err.Error())
+		return false
+	}
+
+	ws.app.CommandStorage().ForceBalance(ws.CommandStackId())
+	ws.storeHistory()
+	return true
+}
+
+// ExportSelection saves tiles of the selected area as a standalone map by the provided path.
+func (ws *WsMap) ExportSelection(path string) bool {
+	log.Printf("[wsmap] exporting selection of map workspace [%s] to: %s", ws.CommandStackId(), path)
+
+	data, err := ws.paneMap.Editor().ExtractSelected()
+	if err != nil {
+		log.Printf("[wsmap] unable to extract selection of map workspace [%s]: %v", ws.CommandStackId(), err)
+		util.ShowErrorDialog("Unable to export the selection: " + err.Error())
+		return false
+	}
+
+	// The exported map is a new file, so it has no initial data to keep keys from.
+	data.Filepath = path
+	if err = dmmsave.SaveData(data, data, path, ws.saveConfig()); err != nil {
+		log.Printf("[wsmap] unable to save selection of map workspace [%s]: %v", ws.CommandStackId(), err)
+		util.ShowErrorDialog("Unable to export the selection: " + err.Error())
+		return false
+	}
+
+	return true
+}
+
+func (ws *WsMap) saveConfig() dmmsave.Config {
 	editorPrefs := ws.app.Prefs().Editor
 
 	var saveFormat dmmsave.Format
@@ -22,11 +58,10 @@ func (ws *WsMap) Save() bool {
 		saveFormat = dmmsave.FormatDM
 	}
 
-	dmmsave.Save(ws.app.LoadedEnvironment(), ws.paneMap.Dmm(), dmmsave.Config{
+	return dmmsave.Config{
 		Format:            saveFormat,
 		SanitizeVariables: editorPrefs.SanitizeVariables,
-	})
-
-	ws.app.CommandStorage().ForceBalance(ws.CommandStackId())
-	return true
+		DeterministicKeys: editorPrefs.DeterministicKeys,
+		PreserveLayout:    editorPrefs.PreserveLayout,
+	}
 }
@@ -55,6 +55,6 @@ func (t *ToolAdd) onMove(coord util.Point) {
 func (t *ToolAdd) onStop(util.Point) {
 	if len(t.editedTiles) != 0 {
 		t.editedTiles = make(map[util.Point]bool, len(t.editedTiles))
-		go ed.CommitChanges("Add Atoms")
+		ed.CommitChanges("Add Atoms")
 	}
 }
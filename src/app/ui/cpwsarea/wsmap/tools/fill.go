@@ -79,7 +79,7 @@ func (t *ToolFill) onStop(util.Point) {
 			}
 		}
 
-		go ed.CommitChanges("Fill Atoms")
+		ed.CommitChanges("Fill Atoms")
 	}
 
 	t.start = util.Point{}
@@ -193,6 +193,64 @@ func (t *ToolGrab) moveArea(coord util.Point) {
 	ed.UpdateCanvasByCoords(updateCoords)
 }
 
+// Transform rotates or flips the selected area in place. The bottom-left corner of the area is kept.
+// Returns false, if there is no selected area or the transformed area doesn't fit the map.
+func (t *ToolGrab) Transform(transform dmmap.Transform) bool {
+	if !t.active() || len(t.initTiles) == 0 || t.dragging {
+		return false
+	}
+
+	dmm := ed.Dmm()
+
+	width, height := transform.Size(int(t.fillArea.X2-t.fillArea.X1)+1, int(t.fillArea.Y2-t.fillArea.Y1)+1)
+	nextArea := util.Bounds{
+		X1: t.fillArea.X1,
+		Y1: t.fillArea.Y1,
+		X2: t.fillArea.X1 + float32(width-1),
+		Y2: t.fillArea.Y1 + float32(height-1),
+	}
+
+	if int(nextArea.X2) > dmm.MaxX || int(nextArea.Y2) > dmm.MaxY {
+		log.Printf("[tools] unable to transform grabbed area [%v], out of bounds: %v", transform, nextArea)
+		return false
+	}
+
+	log.Printf("[tools] transform grabbed area [%v]: %v", transform, t.fillArea)
+
+	if t.prevTiles == nil {
+		t.prevTiles = make(map[util.Point]dmmdata.Prefabs)
+	}
+
+	var updateCoords []util.Point
+
+	for _, initTile := range t.initTiles {
+		updateCoords = append(updateCoords, initTile.Coord)
+		ed.TileDelete(initTile.Coord)
+	}
+
+	for _, tile := range dmmap.TransformTiles(t.initTiles, transform) {
+		// Tiles out of the initial area are overwritten, so they are remembered as the moved through ones.
+		if !t.fillArea.Contains(float32(tile.Coord.X), float32(tile.Coord.Y)) {
+			if _, ok := t.prevTiles[tile.Coord]; !ok {
+				t.prevTiles[tile.Coord] = dmm.GetTile(tile.Coord).Instances().Prefabs().Copy()
+			}
+		}
+		updateCoords = append(updateCoords, tile.Coord)
+
+		prefabs := make(dmmdata.Prefabs, 0, len(tile.Instances()))
+		for _, prefab := range tile.Instances().Prefabs() {
+			prefabs = append(prefabs, dmm.Context().Prefabs.Put(prefab))
+		}
+		ed.TileReplace(tile.Coord, prefabs)
+	}
+
+	t.fillArea = nextArea
+	t.stopMoveArea()
+
+	ed.UpdateCanvasByCoords(updateCoords)
+	return true
+}
+
 func (t *ToolGrab) onStop(util.Point) {
 	if !t.active() {
 		return
@@ -203,7 +261,7 @@ func (t *ToolGrab) onStop(util.Point) {
 		t.stopSelectArea()
 	case tSelectModeMoveArea:
 		t.stopMoveArea()
-		go ed.CommitChanges("Move Grabbed Area")
+		ed.CommitChanges("Move Grabbed Area")
 	}
 
 	t.dragging = false
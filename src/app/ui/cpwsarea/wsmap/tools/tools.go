@@ -81,6 +81,9 @@ var (
 	selectedToolName = TNAdd
 
 	startedTool Tool
+
+	// Suspended tools aren't started from the canvas, while the map pane handles the mouse by itself.
+	suspended bool
 )
 
 func SetSelected(toolName string) Tool {
@@ -108,6 +111,12 @@ func SetCanvasState(canvasState canvasState) {
 	cs = canvasState
 }
 
+// SetSuspended suspends tools from reacting on the canvas.
+func SetSuspended(isSuspended bool) {
+	log.Println("[tools] suspended:", isSuspended)
+	suspended = isSuspended
+}
+
 func Selected() Tool {
 	return tools[selectedToolName]
 }
@@ -144,8 +153,17 @@ func SelectedTiles() []util.Point {
 	return []util.Point{cs.LastHoveredTile()}
 }
 
+// SelectedArea returns the area selected with the ToolGrab and its z-level.
+// The last value is false, if there is no selected area.
+func SelectedArea() (area util.Bounds, zLevel int, ok bool) {
+	if selectTool, ok := Selected().(*ToolGrab); ok && selectTool.active() && len(selectTool.initTiles) > 0 {
+		return selectTool.fillArea, selectTool.fillStart.Z, true
+	}
+	return util.Bounds{}, 0, false
+}
+
 func processSelectedToolStart() {
-	if cs == nil || cc == nil || cs.HoverOutOfBounds() && !Selected().IgnoreBounds() {
+	if suspended || cs == nil || cc == nil || cs.HoverOutOfBounds() && !Selected().IgnoreBounds() {
 		return
 	}
 	if cc.Dragging() && !active {
@@ -10,6 +10,7 @@ import (
 	"sdmm/app/ui/cpwsarea/wsmap/pmap"
 	"sdmm/dmapi/dmenv"
 	"sdmm/dmapi/dmmap"
+	"sdmm/dmapi/dmmsnap"
 
 	"github.com/SpaiR/imgui-go"
 )
@@ -20,6 +21,9 @@ type App interface {
 	LoadedEnvironment() *dmenv.Dme
 	CommandStorage() *command.Storage
 	Prefs() prefs.Prefs
+
+	LoadMapHistory(dmm *dmmap.Dmm) (dmmsnap.History, bool)
+	StoreMapHistory(dmm *dmmap.Dmm, history dmmsnap.History)
 }
 
 type WsMap struct {
@@ -31,10 +35,12 @@ type WsMap struct {
 }
 
 func New(app App, dmm *dmmap.Dmm) *WsMap {
-	return &WsMap{
+	ws := &WsMap{
 		app:     app,
 		paneMap: pmap.New(app, dmm),
 	}
+	ws.restoreHistory()
+	return ws
 }
 
 func (ws *WsMap) Map() *pmap.PaneMap {
@@ -51,3 +51,16 @@ type OptionPref struct {
 func MakeOptionPref() OptionPref {
 	return OptionPref{}
 }
+
+type StringPref struct {
+	basePref
+
+	FGet func() string
+	FSet func(string)
+
+	Hint string
+}
+
+func MakeStringPref() StringPref {
+	return StringPref{}
+}
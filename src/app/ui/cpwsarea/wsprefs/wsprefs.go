@@ -57,6 +57,9 @@ func (ws *WsPrefs) showContent() {
 			if pref, ok := pref.(OptionPref); ok {
 				showOptionPref(pref)
 			}
+			if pref, ok := pref.(StringPref); ok {
+				showStringPref(pref)
+			}
 			imgui.PopID()
 		}
 	}
@@ -126,6 +129,22 @@ func showOptionPref(pref OptionPref) {
 	}
 }
 
+func showStringPref(pref StringPref) {
+	markdown.ShowHeader(pref.Name, window.FontH3)
+
+	imgui.PushTextWrapPos()
+	imgui.TextDisabled(pref.Desc)
+	showHelp(pref.Help)
+	imgui.PopTextWrapPos()
+
+	v := pref.FGet()
+	imgui.InputTextWithHint(pref.Label, pref.Hint, &v)
+	// The value is applied only when the input is finished, so it's not changed on every typed character.
+	if imgui.IsItemDeactivatedAfterEdit() && v != pref.FGet() {
+		pref.FSet(v)
+	}
+}
+
 func showHelp(helpText string) {
 	if helpText != "" {
 		imgui.SameLine()
@@ -5,7 +5,7 @@ import "log"
 const (
 	configName    = "layout"
 	configVersion = 1
-	configState   = 1
+	configState   = 3
 )
 
 type layoutConfig struct {
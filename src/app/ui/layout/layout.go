@@ -4,9 +4,11 @@ import (
 	"log"
 
 	"sdmm/app/config"
+	"sdmm/app/ui/cpdiagnostics"
 	"sdmm/app/ui/cpenvironment"
 	"sdmm/app/ui/cpprefabs"
 	"sdmm/app/ui/cpsearch"
+	"sdmm/app/ui/cpstats"
 	"sdmm/app/ui/cpvareditor"
 	"sdmm/app/ui/cpwsarea"
 	"sdmm/app/ui/layout/lnode"
@@ -16,9 +18,11 @@ import (
 )
 
 type app interface {
+	cpdiagnostics.App
 	cpenvironment.App
 	cpprefabs.App
 	cpsearch.App
+	cpstats.App
 	cpwsarea.App
 	cpvareditor.App
 
@@ -29,9 +33,11 @@ type app interface {
 }
 
 type Layout struct {
+	cpdiagnostics.Diagnostics
 	cpenvironment.Environment
 	cpprefabs.Prefabs
 	cpsearch.Search
+	cpstats.Stats
 	cpwsarea.WsArea
 	cpvareditor.VarEditor
 
@@ -55,8 +61,10 @@ func New(app app) *Layout {
 	l := &Layout{app: app}
 	l.loadConfig()
 	l.Environment.Init(app)
+	l.Diagnostics.Init(app)
 	l.Prefabs.Init(app)
 	l.Search.Init(app)
+	l.Stats.Init(app)
 	l.WsArea.Init(app)
 	l.VarEditor.Init(app)
 	return l
@@ -66,8 +74,10 @@ func (l *Layout) Process() {
 	l.updateNodes()
 
 	l.showEnvironmentNode()
+	l.showDiagnosticsNode()
 	l.showPrefabsNode()
 	l.showSearchNode()
+	l.showStatisticsNode()
 	l.showVariablesNode()
 	l.showWorkspaceAreaNode() // The latest node will have a focus by default
 
@@ -102,6 +112,10 @@ func (l *Layout) showEnvironmentNode() {
 	l.wrapNode(lnode.NameEnvironment, int(l.leftNodeId), l.Environment.Process)
 }
 
+func (l *Layout) showDiagnosticsNode() {
+	l.wrapNode(lnode.NameDiagnostics, int(l.leftNodeId), l.Diagnostics.Process)
+}
+
 func (l *Layout) showWorkspaceAreaNode() {
 	l.wrapNodeV(lnode.NameWorkspaceArea, int(l.centerNodeId), func() {
 		l.WsArea.Process(int(l.centerNodeId))
@@ -118,6 +132,10 @@ func (l *Layout) showSearchNode() {
 	l.wrapNode(lnode.NameSearch, int(l.rightUpNodeId), l.Search.Process)
 }
 
+func (l *Layout) showStatisticsNode() {
+	l.wrapNode(lnode.NameStatistics, int(l.rightUpNodeId), l.Stats.Process)
+}
+
 func (l *Layout) showVariablesNode() {
 	l.wrapNode(lnode.NameVariables, int(l.rightDownNodeId), l.VarEditor.Process)
 }
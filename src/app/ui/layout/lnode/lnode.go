@@ -3,8 +3,10 @@ package lnode
 // Names for all layout nodes.
 const (
 	NameEnvironment   = "Environment"
+	NameDiagnostics   = "Diagnostics"
 	NameWorkspaceArea = "Workspace Area"
 	NamePrefabs       = "Prefabs"
 	NameSearch        = "Search"
+	NameStatistics    = "Statistics"
 	NameVariables     = "Variables"
 )
@@ -5,6 +5,7 @@ import (
 	"sdmm/app/ui/shortcut"
 	"sdmm/dmapi/dm"
 	"sdmm/dmapi/dmenv"
+	"sdmm/dmapi/dmmap"
 	"sdmm/dmapi/dmmclip"
 	"sdmm/imguiext/icon"
 	"sdmm/imguiext/style"
@@ -23,6 +24,7 @@ type app interface {
 	DoLoadResource(path string)
 	DoClearRecentMaps()
 	DoCloseEnvironment()
+	DoInvalidateEnvironmentCache()
 	DoClose()
 	DoCloseAll()
 	DoSave()
@@ -36,10 +38,14 @@ type app interface {
 	DoCopy()
 	DoPaste()
 	DoCut()
+	DoTransform(transform dmmap.Transform)
 	DoDelete()
 	DoSearch()
+	DoStatistics()
 	DoDeselect()
 	DoCreateMap()
+	DoExportSelectionAsMap()
+	DoInsertMapFile()
 
 	// View
 	DoAreaBorders()
@@ -47,6 +53,7 @@ type app interface {
 	DoMirrorCanvasCamera()
 
 	// Window
+	DoDiagnostics()
 	DoResetLayout()
 
 	// Help
@@ -71,6 +78,7 @@ type app interface {
 	HasLoadedEnvironment() bool
 
 	HasActiveMap() bool
+	HasSelectedArea() bool
 
 	PathsFilter() *dm.PathsFilter
 	CommandStorage() *command.Storage
@@ -134,6 +142,9 @@ func (m *Menu) Process() {
 			w.MenuItem("Close Environment", m.app.DoCloseEnvironment).
 				IconEmpty().
 				Enabled(m.app.HasLoadedEnvironment()),
+			w.MenuItem("Invalidate Environment Cache", m.app.DoInvalidateEnvironmentCache).
+				IconEmpty().
+				Enabled(m.app.HasLoadedEnvironment()),
 			w.Separator(),
 			w.MenuItem("Close", m.app.DoClose).
 				IconEmpty().
@@ -185,15 +196,41 @@ func (m *Menu) Process() {
 			w.MenuItem("Deselect", m.app.DoDeselect).
 				IconEmpty().
 				Shortcut(platform.KeyModName(), "D"),
+			w.Menu("Transform", w.Layout{
+				w.MenuItem("Rotate Clockwise", m.doTransform(dmmap.TransformRotate90)).
+					IconEmpty().
+					Shortcut("R"),
+				w.MenuItem("Rotate 180 Degrees", m.doTransform(dmmap.TransformRotate180)).
+					IconEmpty(),
+				w.MenuItem("Rotate Counterclockwise", m.doTransform(dmmap.TransformRotate270)).
+					IconEmpty().
+					Shortcut("Shift", "R"),
+				w.Separator(),
+				w.MenuItem("Flip Horizontally", m.doTransform(dmmap.TransformFlipHorizontal)).
+					IconEmpty(),
+				w.MenuItem("Flip Vertically", m.doTransform(dmmap.TransformFlipVertical)).
+					IconEmpty(),
+			}).
+				IconEmpty().
+				Enabled(m.isTransformEnabled()),
 			w.Separator(),
 			w.MenuItem("Search", m.app.DoSearch).
 				Icon(icon.Search).
 				Enabled(m.app.HasActiveMap()).
 				Shortcut(platform.KeyModName(), "F"),
+			w.MenuItem("Statistics", m.app.DoStatistics).
+				IconEmpty().
+				Enabled(m.app.HasActiveMap()),
 			w.Separator(),
 			w.MenuItem("Create Map", m.app.DoCreateMap).
 				IconEmpty().
 				Enabled(m.app.HasLoadedEnvironment()),
+			w.MenuItem("Export Selection as Map...", m.app.DoExportSelectionAsMap).
+				IconEmpty().
+				Enabled(m.app.HasSelectedArea()),
+			w.MenuItem("Insert Map File...", m.app.DoInsertMapFile).
+				IconEmpty().
+				Enabled(m.app.HasActiveMap()),
 		}),
 
 		w.Menu("View", w.Layout{
@@ -234,6 +271,9 @@ func (m *Menu) Process() {
 		}),
 
 		w.Menu("Window", w.Layout{
+			w.MenuItem("Diagnostics", m.app.DoDiagnostics).
+				IconEmpty(),
+			w.Separator(),
 			w.MenuItem("Reset Layout", m.app.DoResetLayout).Shortcut("F5").
 				Icon(icon.WindowRestore),
 		}),
@@ -304,6 +344,16 @@ func (m *Menu) doShowAll() {
 	m.app.PathsFilter().Clear()
 }
 
+func (m *Menu) doTransform(transform dmmap.Transform) func() {
+	return func() {
+		m.app.DoTransform(transform)
+	}
+}
+
+func (m *Menu) isTransformEnabled() bool {
+	return m.app.HasSelectedArea() || m.app.Clipboard().HasData()
+}
+
 func (m *Menu) isAreaToggled() bool {
 	return m.app.PathsFilter().IsVisiblePath("/area")
 }
@@ -2,6 +2,7 @@ package menu
 
 import (
 	"sdmm/app/ui/shortcut"
+	"sdmm/dmapi/dmmap"
 	"sdmm/platform"
 
 	"github.com/go-gl/glfw/v3.3/glfw"
@@ -122,6 +123,22 @@ func (m *Menu) addShortcuts() {
 		FirstKey: glfw.KeyDelete,
 		Action:   m.app.DoDelete,
 	})
+	// Shortcuts without modifiers are easy to press by accident, so they transform only the selected area.
+	// Tiles of the global clipboard are transformed with the menu.
+	m.shortcuts.Add(shortcut.Shortcut{
+		Name:      "menu#DoTransformRotate90",
+		FirstKey:  glfw.KeyR,
+		Action:    m.doTransform(dmmap.TransformRotate90),
+		IsEnabled: m.app.HasSelectedArea,
+	})
+	m.shortcuts.Add(shortcut.Shortcut{
+		Name:        "menu#DoTransformRotate270",
+		FirstKey:    glfw.KeyLeftShift,
+		FirstKeyAlt: glfw.KeyRightShift,
+		SecondKey:   glfw.KeyR,
+		Action:      m.doTransform(dmmap.TransformRotate270),
+		IsEnabled:   m.app.HasSelectedArea,
+	})
 	m.shortcuts.Add(shortcut.Shortcut{
 		Name:        "menu#DoSearch",
 		FirstKey:    platform.KeyModLeft(),
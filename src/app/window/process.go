@@ -44,10 +44,15 @@ func (w *Window) startFrame() {
 }
 
 func runLaterJobs() {
-	for _, job := range laterJobs {
+	// Jobs could queue new jobs, so they are taken before running.
+	laterJobsMu.Lock()
+	jobs := laterJobs
+	laterJobs = nil
+	laterJobsMu.Unlock()
+
+	for _, job := range jobs {
 		job()
 	}
-	laterJobs = nil
 }
 
 func runRepeatJobs() {
@@ -1,6 +1,9 @@
 package window
 
-import "log"
+import (
+	"log"
+	"sync"
+)
 
 func (w *Window) AddMouseChangeCallback(cb func(uint, uint)) (callbackId int) {
 	id := w.mouseChangeCallbackId
@@ -15,10 +18,16 @@ func (w *Window) RemoveMouseChangeCallback(id int) {
 	log.Println("[window] mouse change callback deleted:", id)
 }
 
-var laterJobs []func()
+var (
+	laterJobs   []func()
+	laterJobsMu sync.Mutex
+)
 
 // RunLater queues provided job to be run in the next frame.
+// It's safe to call from any goroutine, so background jobs could pass their results to the main thread.
 func RunLater(job func()) {
+	laterJobsMu.Lock()
+	defer laterJobsMu.Unlock()
 	laterJobs = append(laterJobs, job)
 }
 
@@ -0,0 +1,84 @@
+// Package cli provides headless commands of the editor.
+// Commands work without a window, so they can be used in CI pipelines, git hooks etc.
+// They are built as a separate binary from the cmd/sdmm package.
+package cli
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+)
+
+// Exit codes returned by commands.
+const (
+	ExitOk    = 0
+	ExitError = 1
+	ExitUsage = 2
+)
+
+type command struct {
+	description string
+	run         func(args []string) int
+}
+
+var commands = map[string]command{
+	"convert": {
+		description: "convert maps between DM and TGM formats",
+		run:         runConvert,
+	},
+	"merge": {
+		description: "three-way merge of maps, usable as a git merge driver",
+		run:         runMerge,
+	},
+	"stats": {
+		description: "print statistics of maps as JSON",
+		run:         runStats,
+	},
+}
+
+// Run executes a headless command from the provided program arguments (without the executable path).
+func Run(args []string) (exitCode int) {
+	if len(args) == 0 {
+		printUsage(os.Stderr)
+		return ExitUsage
+	}
+
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage(os.Stdout)
+		return ExitOk
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		printErr(args[0], "unknown command")
+		printUsage(os.Stderr)
+		return ExitUsage
+	}
+
+	// Internal packages log a lot, but that only makes sense for the editor.
+	log.SetOutput(io.Discard)
+
+	return cmd.run(args[1:])
+}
+
+func printUsage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "Usage: sdmm <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-10s %s\n", name, commands[name].description)
+	}
+}
+
+// Prints an error of the command to stderr.
+func printErr(cmd string, format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "sdmm %s: %s\n", cmd, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sdmm/dmapi/dmmap/dmmdata"
+	"sdmm/dmapi/dmmsave"
+)
+
+const cmdConvert = "convert"
+
+// runConvert converts map files between DM and TGM formats.
+// Maps are converted in place, unless an output path is provided.
+// For several input files the output path is treated as a directory.
+func runConvert(args []string) int {
+	fs := flag.NewFlagSet(cmdConvert, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: sdmm convert [-format tgm|dm] [-o output] <map.dmm>...")
+		fs.PrintDefaults()
+	}
+
+	format := fs.String("format", "tgm", "format to convert maps to: tgm or dm")
+	output := fs.String("o", "", "output file, or directory when converting several maps (default: in place)")
+
+	if err := fs.Parse(args); err != nil {
+		return ExitUsage
+	}
+
+	var saveFormat dmmsave.Format
+	switch *format {
+	case "tgm":
+		saveFormat = dmmsave.FormatTGM
+	case "dm":
+		saveFormat = dmmsave.FormatDM
+	default:
+		printErr(cmdConvert, "unknown format: %s", *format)
+		return ExitUsage
+	}
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		fs.Usage()
+		return ExitUsage
+	}
+
+	// Flags are parsed only before maps, so a flag after them would be taken as a map path.
+	for _, path := range paths {
+		if strings.HasPrefix(path, "-") {
+			printErr(cmdConvert, "flags should go before maps: %s", path)
+			return ExitUsage
+		}
+	}
+
+	outputIsDir := len(paths) > 1
+	if info, err := os.Stat(*output); err == nil && info.IsDir() {
+		outputIsDir = true
+	}
+	if outputIsDir && *output != "" {
+		if err := os.MkdirAll(*output, os.ModePerm); err != nil {
+			printErr(cmdConvert, "unable to create output directory: %v", err)
+			return ExitError
+		}
+	}
+
+	exitCode := ExitOk
+
+	for _, path := range paths {
+		dst := path
+		if *output != "" {
+			if outputIsDir {
+				dst = filepath.Join(*output, filepath.Base(path))
+			} else {
+				dst = *output
+			}
+		}
+
+		if err := convertMap(path, dst, saveFormat); err != nil {
+			printErr(cmdConvert, "%s: %v", path, err)
+			exitCode = ExitError
+		}
+	}
+
+	return exitCode
+}
+
+func convertMap(path, dst string, format dmmsave.Format) error {
+	data, err := dmmdata.New(path)
+	if err != nil {
+		return err
+	}
+	return dmmsave.SaveData(data, data, dst, dmmsave.Config{Format: format, DeterministicKeys: true})
+}
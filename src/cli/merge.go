@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+
+	"sdmm/dmapi/dmmap/dmmdata"
+	"sdmm/dmapi/dmmap/dmmdata/dmmprefab"
+	"sdmm/dmapi/dmmmerge"
+	"sdmm/dmapi/dmmsave"
+	"sdmm/dmapi/dmvars"
+)
+
+const cmdMerge = "merge"
+
+const mergeUsage = `Usage: sdmm merge [-marker path] [-o output] <base.dmm> <ours.dmm> <theirs.dmm>
+
+Does a three-way merge of maps. The result is written to the ours file, unless an output path is provided.
+Exits with 1 when there are conflicts, so the command can be used as a git merge driver:
+
+  .gitattributes:
+    *.dmm merge=sdmm
+
+  .git/config:
+    [merge "sdmm"]
+      name = StrongDMM map merge
+      driver = sdmm merge %O %A %B
+
+Flags:`
+
+// runMerge merges maps tile by tile. Conflicted tiles are marked with the marker prefab and reported to stderr.
+func runMerge(args []string) int {
+	fs := flag.NewFlagSet(cmdMerge, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), mergeUsage)
+		fs.PrintDefaults()
+	}
+
+	marker := fs.String("marker", dmmmerge.DefaultMarkerPath, "type path of the prefab to mark conflicted tiles with")
+	output := fs.String("o", "", "output file (default: the ours file)")
+
+	if err := fs.Parse(args); err != nil {
+		return ExitUsage
+	}
+	if fs.NArg() != 3 {
+		fs.Usage()
+		return ExitUsage
+	}
+
+	var maps [3]*dmmdata.DmmData
+	for idx, path := range fs.Args() {
+		data, err := dmmdata.New(path)
+		if err != nil {
+			printErr(cmdMerge, "%s: %v", path, err)
+			return ExitError
+		}
+		maps[idx] = data
+	}
+
+	base, ours, theirs := maps[0], maps[1], maps[2]
+
+	result, err := dmmmerge.Merge(base, ours, theirs, dmmmerge.Config{
+		Marker: dmmprefab.New(dmmprefab.IdNone, *marker, &dmvars.Variables{}),
+	})
+	if err != nil {
+		printErr(cmdMerge, "unable to merge: %v", err)
+		return ExitError
+	}
+
+	dst := ours.Filepath
+	if *output != "" {
+		dst = *output
+	}
+
+	// Our map is the initial one, so the result has the smallest diff with the current state of the branch.
+	if err = dmmsave.SaveData(ours, result.Data, dst, dmmsave.Config{DeterministicKeys: true, PreserveLayout: true}); err != nil {
+		printErr(cmdMerge, "unable to save the result: %v", err)
+		return ExitError
+	}
+
+	if len(result.Conflicts) == 0 {
+		return ExitOk
+	}
+
+	for _, conflict := range result.Conflicts {
+		if conflict.Dropped {
+			printErr(cmdMerge, "conflict at (%d,%d,%d): the tile is out of the merged map bounds and was dropped",
+				conflict.Loc.X, conflict.Loc.Y, conflict.Loc.Z)
+		} else {
+			printErr(cmdMerge, "conflict at (%d,%d,%d)", conflict.Loc.X, conflict.Loc.Y, conflict.Loc.Z)
+		}
+	}
+	printErr(cmdMerge, "%d conflicted tile(s) marked with %s", len(result.Conflicts), *marker)
+
+	return ExitError
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"sdmm/dmapi/dmenv"
+	"sdmm/dmapi/dmmap"
+	"sdmm/dmapi/dmmap/dmmdata"
+	"sdmm/dmapi/dmmstats"
+)
+
+const cmdStats = "stats"
+
+// runStats prints statistics of maps as a JSON array, one element for every map.
+// The environment isn't parsed, so types are counted as they are written in maps.
+func runStats(args []string) int {
+	fs := flag.NewFlagSet(cmdStats, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: sdmm stats [-o output] <map.dmm>...")
+		fs.PrintDefaults()
+	}
+
+	output := fs.String("o", "", "output file (default: stdout)")
+
+	if err := fs.Parse(args); err != nil {
+		return ExitUsage
+	}
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		fs.Usage()
+		return ExitUsage
+	}
+
+	exitCode := ExitOk
+
+	report := make([]*dmmstats.Stats, 0, len(paths))
+	for _, path := range paths {
+		stats, err := collectStats(path)
+		if err != nil {
+			printErr(cmdStats, "%s: %v", path, err)
+			exitCode = ExitError
+			continue
+		}
+		report = append(report, stats)
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		printErr(cmdStats, "unable to serialize stats: %v", err)
+		return ExitError
+	}
+	data = append(data, '\n')
+
+	if *output == "" {
+		_, err = os.Stdout.Write(data)
+	} else {
+		err = os.WriteFile(*output, data, 0644)
+	}
+	if err != nil {
+		printErr(cmdStats, "unable to write stats: %v", err)
+		return ExitError
+	}
+
+	return exitCode
+}
+
+func collectStats(path string) (*dmmstats.Stats, error) {
+	data, err := dmmdata.New(path)
+	if err != nil {
+		return nil, err
+	}
+	dmm, _ := dmmap.New(dmenv.NewEmpty(path).Context(), data, path)
+	return dmmstats.Collect(dmm), nil
+}
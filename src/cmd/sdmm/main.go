@@ -0,0 +1,13 @@
+// The sdmm binary runs headless commands of the editor.
+// It's built apart from the editor, so it never links GLFW or OpenGL and works on machines without a display.
+package main
+
+import (
+	"os"
+
+	"sdmm/cli"
+)
+
+func main() {
+	os.Exit(cli.Run(os.Args[1:]))
+}
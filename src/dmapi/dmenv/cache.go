@@ -0,0 +1,183 @@
+package dmenv
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"sdmm/third_party/sdmmparser"
+)
+
+// cacheVersion should be increased every time the format of the parsed environment is changed.
+const cacheVersion = 2
+
+// Cache keeps parsed environments on the disk.
+// The parsed environment is reused until any of its code files (.dm and .dme) is modified, added or deleted.
+// A stale environment could still be used while the actual one is parsed in the background (see NewV).
+type Cache struct {
+	dir string
+
+	parse func(path string) (*sdmmparser.Environment, error)
+}
+
+type cacheEntry struct {
+	Version     int
+	Key         string
+	Environment *sdmmparser.Environment
+}
+
+// NewCache creates a cache which stores its files in the provided directory.
+func NewCache(dir string) *Cache {
+	return &Cache{
+		dir:   dir,
+		parse: sdmmparser.ParseEnvironment,
+	}
+}
+
+// New works like the package New function, but the environment is taken from the cache if it's actual.
+// Otherwise, the environment is parsed and stored in the cache.
+func (c *Cache) New(path string) (*Dme, error) {
+	env, _, err := c.newV(path, false)
+	return env, err
+}
+
+// NewV returns the environment from the cache even if it's stale, so it could be used while the actual one is parsed.
+// Same as New but has the second return value, which is true if the returned environment is stale.
+// Such environment should be revalidated with the New method, which parses and stores the actual one.
+func (c *Cache) NewV(path string) (*Dme, bool, error) {
+	return c.newV(path, true)
+}
+
+func (c *Cache) newV(path string, allowStale bool) (*Dme, bool, error) {
+	// The key is made before the parsing, so files modified during the parsing make the cache stale.
+	key := cacheKey(filepath.Dir(path))
+
+	if parsed, stale, ok := c.load(path, key); ok && (!stale || allowStale) {
+		if stale {
+			log.Println("[dmenv] stale environment loaded from the cache:", path)
+		} else {
+			log.Println("[dmenv] environment loaded from the cache:", path)
+		}
+		return newFromParsed(path, parsed), stale, nil
+	}
+
+	parsed, err := c.parse(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("[dmenv] unable to create dme by path [%s]: %w", path, err)
+	}
+
+	c.store(path, key, parsed)
+
+	return newFromParsed(path, parsed), false, nil
+}
+
+// Invalidate deletes the cache of the environment, so it will be parsed on the next load.
+func (c *Cache) Invalidate(path string) {
+	if err := os.Remove(c.entryPath(path)); err != nil && !os.IsNotExist(err) {
+		log.Printf("[dmenv] unable to invalidate environment cache [%s]: %v", path, err)
+		return
+	}
+	log.Println("[dmenv] environment cache invalidated:", path)
+}
+
+// Returns the cached environment and true as the second value, if code files were modified since it was stored.
+// Entries of other versions can't be read, so they are not returned at all.
+func (c *Cache) load(path, key string) (parsed *sdmmparser.Environment, stale bool, ok bool) {
+	data, err := os.ReadFile(c.entryPath(path))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("[dmenv] unable to read environment cache [%s]: %v", path, err)
+		}
+		return nil, false, false
+	}
+
+	var entry cacheEntry
+	if err = json.Unmarshal(data, &entry); err != nil {
+		log.Printf("[dmenv] unable to deserialize environment cache [%s]: %v", path, err)
+		return nil, false, false
+	}
+
+	if entry.Version != cacheVersion || entry.Environment == nil {
+		log.Println("[dmenv] environment cache has another version:", path)
+		return nil, false, false
+	}
+
+	if entry.Key != key {
+		log.Println("[dmenv] environment cache is stale:", path)
+		return entry.Environment, true, true
+	}
+
+	return entry.Environment, false, true
+}
+
+func (c *Cache) store(path, key string, parsed *sdmmparser.Environment) {
+	start := time.Now()
+
+	data, err := json.Marshal(cacheEntry{Version: cacheVersion, Key: key, Environment: parsed})
+	if err != nil {
+		log.Printf("[dmenv] unable to serialize environment cache [%s]: %v", path, err)
+		return
+	}
+
+	if err = os.MkdirAll(c.dir, os.ModePerm); err != nil {
+		log.Printf("[dmenv] unable to create environment cache dir [%s]: %v", c.dir, err)
+		return
+	}
+
+	// The file is written completely before replacing the previous one, so a broken cache is never read.
+	tmp, err := os.CreateTemp(c.dir, "*.tmp")
+	if err != nil {
+		log.Printf("[dmenv] unable to create environment cache [%s]: %v", path, err)
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.entryPath(path))
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		log.Printf("[dmenv] unable to write environment cache [%s]: %v", path, err)
+		return
+	}
+
+	log.Printf("[dmenv] environment cache stored [%s] in [%d] ms", path, time.Since(start).Milliseconds())
+}
+
+// Every environment has its own file named by the hash of the environment path.
+func (c *Cache) entryPath(path string) string {
+	if absPath, err := filepath.Abs(path); err == nil {
+		path = absPath
+	}
+	hash := sha256.Sum256([]byte(path))
+	return filepath.Join(c.dir, hex.EncodeToString(hash[:])+".json")
+}
+
+// Returns a hash of paths, sizes and modification times of all code files in the root directory.
+// Files are only stated, not read, so the key is cheap to make even for big environments.
+// Files which are not included into the environment are counted too, since only the parser knows about includes.
+func cacheKey(rootDir string) string {
+	files := scanCodeFiles(rootDir)
+
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	hash := sha256.New()
+	for _, path := range paths {
+		state := files[path]
+		_, _ = fmt.Fprintf(hash, "%s\x00%d\x00%d\n", path, state.size, state.modTime.UnixNano())
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
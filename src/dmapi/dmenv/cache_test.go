@@ -0,0 +1,188 @@
+package dmenv
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"sdmm/third_party/sdmmparser"
+)
+
+func newTestCache(t *testing.T) (cache *Cache, parses *int) {
+	parses = new(int)
+	cache = NewCache(t.TempDir())
+	cache.parse = func(string) (*sdmmparser.Environment, error) {
+		*parses++
+		return &sdmmparser.Environment{
+			Tree: sdmmparser.ObjectTreeType{
+				Children: []sdmmparser.ObjectTreeType{{
+					Path: "/obj",
+					Vars: []sdmmparser.ObjectTreeVar{{
+						Name:  "name",
+						Value: `"thing"`,
+						Decl:  &sdmmparser.ObjectTreeVarDecl{Owner: "/atom", File: "code/file.dm", Line: 1},
+					}},
+				}},
+			},
+			Diagnostics: []sdmmparser.Diagnostic{
+				{File: "code/file.dm", Line: 1, Column: 2, Severity: "warning", Description: "unused var"},
+			},
+		}, nil
+	}
+	return cache, parses
+}
+
+func newTestEnvDir(t *testing.T) (envPath, codePath string) {
+	dir := t.TempDir()
+	codePath = filepath.Join(dir, "code", "file.dm")
+	envPath = filepath.Join(dir, "env.dme")
+	require.NoError(t, os.MkdirAll(filepath.Dir(codePath), os.ModePerm))
+	require.NoError(t, os.WriteFile(codePath, []byte("/obj"), 0644))
+	require.NoError(t, os.WriteFile(envPath, []byte(`#include "code/file.dm"`), 0644))
+	return envPath, codePath
+}
+
+func TestCacheNew(t *testing.T) {
+	cache, parses := newTestCache(t)
+	envPath, _ := newTestEnvDir(t)
+
+	parsed, err := cache.New(envPath)
+	require.NoError(t, err)
+	assert.Equal(t, 1, *parses)
+
+	cached, err := cache.New(envPath)
+	require.NoError(t, err)
+	assert.Equal(t, 1, *parses)
+
+	// The environment from the cache is the same as the parsed one.
+	assert.Equal(t, envPath, cached.RootFile)
+	assert.Equal(t, parsed.Objects["/obj"].Vars.ValueV("name", ""), cached.Objects["/obj"].Vars.ValueV("name", ""))
+	assert.Equal(t, parsed.Objects["/obj"].VarsDecls, cached.Objects["/obj"].VarsDecls)
+	assert.Equal(t, parsed.Diagnostics, cached.Diagnostics)
+	assert.Len(t, cached.Diagnostics, 1)
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	cache, parses := newTestCache(t)
+	envPath, codePath := newTestEnvDir(t)
+	dir := filepath.Dir(envPath)
+
+	load := func() {
+		_, err := cache.New(envPath)
+		require.NoError(t, err)
+	}
+
+	load()
+	assert.Equal(t, 1, *parses)
+
+	// Non-code files don't matter.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "map.dmm"), []byte{}, 0644))
+	load()
+	assert.Equal(t, 1, *parses)
+
+	modTime := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(codePath, modTime, modTime))
+	load()
+	assert.Equal(t, 2, *parses)
+
+	require.NoError(t, os.WriteFile(codePath, []byte("/obj/thing"), 0644))
+	require.NoError(t, os.Chtimes(codePath, modTime, modTime))
+	load()
+	assert.Equal(t, 3, *parses)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "code", "new.dm"), []byte{}, 0644))
+	load()
+	assert.Equal(t, 4, *parses)
+
+	require.NoError(t, os.Remove(codePath))
+	load()
+	assert.Equal(t, 5, *parses)
+
+	load()
+	assert.Equal(t, 5, *parses)
+
+	cache.Invalidate(envPath)
+	load()
+	assert.Equal(t, 6, *parses)
+
+	// Invalidation of the missing cache does nothing.
+	cache.Invalidate(envPath)
+	cache.Invalidate(filepath.Join(dir, "other.dme"))
+}
+
+func TestCacheNewV_Stale(t *testing.T) {
+	cache, parses := newTestCache(t)
+	envPath, codePath := newTestEnvDir(t)
+
+	_, stale, err := cache.NewV(envPath)
+	require.NoError(t, err)
+	assert.False(t, stale)
+	assert.Equal(t, 1, *parses)
+
+	// The stale environment is returned without the parsing.
+	modTime := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(codePath, modTime, modTime))
+	env, stale, err := cache.NewV(envPath)
+	require.NoError(t, err)
+	assert.True(t, stale)
+	assert.Contains(t, env.Objects, "/obj")
+	assert.Equal(t, 1, *parses)
+
+	// The revalidation parses and stores the actual environment.
+	_, err = cache.New(envPath)
+	require.NoError(t, err)
+	assert.Equal(t, 2, *parses)
+
+	_, stale, err = cache.NewV(envPath)
+	require.NoError(t, err)
+	assert.False(t, stale)
+	assert.Equal(t, 2, *parses)
+}
+
+func TestCacheBrokenEntry(t *testing.T) {
+	cache, parses := newTestCache(t)
+	envPath, _ := newTestEnvDir(t)
+
+	_, err := cache.New(envPath)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(cache.entryPath(envPath), []byte("{broken"), 0644))
+	_, err = cache.New(envPath)
+	require.NoError(t, err)
+	assert.Equal(t, 2, *parses)
+
+	// Entries of other versions are not used.
+	require.NoError(t, os.WriteFile(cache.entryPath(envPath), []byte(`{"Version":0}`), 0644))
+	_, err = cache.New(envPath)
+	require.NoError(t, err)
+	assert.Equal(t, 3, *parses)
+
+	_, err = cache.New(envPath)
+	require.NoError(t, err)
+	assert.Equal(t, 3, *parses)
+}
+
+func TestCacheParseErrors(t *testing.T) {
+	cache, parses := newTestCache(t)
+	envPath, _ := newTestEnvDir(t)
+
+	parse := cache.parse
+	cache.parse = func(path string) (*sdmmparser.Environment, error) {
+		parsed, err := parse(path)
+		parsed.Diagnostics = append(parsed.Diagnostics, sdmmparser.Diagnostic{Severity: "error", Description: "bad"})
+		return parsed, err
+	}
+
+	// Environments with errors are loaded and cached with their diagnostics.
+	for i := 0; i < 2; i++ {
+		dme, err := cache.New(envPath)
+		require.NoError(t, err)
+		assert.Equal(t, 1, dme.Errors())
+		assert.Contains(t, dme.Objects, "/obj")
+	}
+	assert.Equal(t, 1, *parses)
+}
@@ -0,0 +1,97 @@
+package dmenv
+
+import (
+	"log"
+
+	"sdmm/dmapi/dmmap/dmmdata/dmmprefab"
+	"sdmm/dmapi/dmvars"
+)
+
+// Context is a state shared by maps of the environment: stored prefabs, base prefabs and loaded icons.
+// Every environment has its own context, so several environments can be loaded at the same time.
+type Context struct {
+	env *Dme
+
+	Prefabs *PrefabStorage
+
+	/*
+		Tiles should have at least one area and one turf.
+		Those prefabs are used to ensure that the tile has a proper content.
+	*/
+	BaseArea *dmmprefab.Prefab
+	BaseTurf *dmmprefab.Prefab
+
+	WorldIconSize int
+
+	// Icons is a cache of icons loaded for the environment.
+	// The cache is provided by the dmicon package, which depends on the graphics, so it's unknown here.
+	Icons IconsCache
+}
+
+// IconsCache is a cache of environment icons, which is disposed with the context.
+type IconsCache interface {
+	Free()
+}
+
+func newContext(env *Dme) *Context {
+	ctx := &Context{
+		env:     env,
+		Prefabs: newPrefabStorage(env),
+	}
+	ctx.loadWorld()
+	log.Println("[dmenv] context created for:", env.RootFile)
+	return ctx
+}
+
+// Loads settings of the world object, which could be changed on the environment reload.
+func (c *Context) loadWorld() {
+	c.WorldIconSize = 32
+
+	// Environments without the world object (like test ones) use built-in defaults.
+	baseAreaPath, baseTurfPath := "/area", "/turf"
+	if world, ok := c.env.Objects["/world"]; ok {
+		c.WorldIconSize = world.Vars.IntV("icon_size", 32)
+		baseAreaPath = world.Vars.ValueV("area", baseAreaPath)
+		baseTurfPath = world.Vars.ValueV("turf", baseTurfPath)
+	}
+
+	c.BaseArea = c.Prefabs.Initial(baseAreaPath)
+	c.BaseTurf = c.Prefabs.Initial(baseTurfPath)
+
+	log.Println("[dmenv] base area:", baseAreaPath)
+	log.Println("[dmenv] base turf:", baseTurfPath)
+}
+
+// Env returns the environment which owns the context.
+func (c *Context) Env() *Dme {
+	return c.env
+}
+
+// Remap returns a prefab of the context environment with the same path and explicit variables as the provided one.
+// Used to move prefabs between environments: the prefab is linked with an object of the context environment.
+// If the environment doesn't have an object with the prefab path, the second return value will be a "false".
+func (c *Context) Remap(prefab *dmmprefab.Prefab) (*dmmprefab.Prefab, bool) {
+	parent, ok := c.env.ObjectVars(prefab.Path())
+	if !ok {
+		return nil, false
+	}
+
+	vars := dmvars.MutableVariables{}
+	vars.LinkParent(parent)
+	if prefab.Vars() != nil {
+		for _, name := range prefab.Vars().Iterate() {
+			value, _ := prefab.Vars().Value(name)
+			vars.Put(name, value)
+		}
+	}
+
+	return c.Prefabs.Get(prefab.Path(), vars.ToImmutable()), true
+}
+
+// Free disposes stored prefabs and loaded icons of the context.
+func (c *Context) Free() {
+	c.Prefabs.Free()
+	if c.Icons != nil {
+		c.Icons.Free()
+	}
+}
@@ -0,0 +1,57 @@
+package dmenv
+
+import (
+	"fmt"
+
+	"sdmm/third_party/sdmmparser"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+	SeverityHint    Severity = "hint"
+)
+
+// Diagnostic is a problem found by the parser in the environment code.
+type Diagnostic struct {
+	// File is a path relative to the environment root directory. Empty for builtin locations.
+	File         string
+	Line, Column int
+	Severity     Severity
+	Msg          string
+}
+
+func (d Diagnostic) String() string {
+	file := d.File
+	if file == "" {
+		file = "builtins"
+	}
+	return fmt.Sprintf("%s:%d:%d: %s: %s", file, d.Line, d.Column, d.Severity, d.Msg)
+}
+
+// Errors returns a number of environment diagnostics with the error severity.
+func (d *Dme) Errors() (count int) {
+	for _, diagnostic := range d.Diagnostics {
+		if diagnostic.Severity == SeverityError {
+			count++
+		}
+	}
+	return count
+}
+
+func makeDiagnostics(parsed []sdmmparser.Diagnostic) []Diagnostic {
+	diagnostics := make([]Diagnostic, 0, len(parsed))
+	for _, d := range parsed {
+		diagnostics = append(diagnostics, Diagnostic{
+			File:     d.File,
+			Line:     d.Line,
+			Column:   d.Column,
+			Severity: Severity(d.Severity),
+			Msg:      d.Description,
+		})
+	}
+	return diagnostics
+}
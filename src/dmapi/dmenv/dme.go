@@ -2,10 +2,14 @@ package dmenv
 
 import (
 	"fmt"
+	"log"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 
 	"sdmm/dmapi/dm"
+	"sdmm/dmapi/dmmap/dmmdata/dmmprefab"
 	"sdmm/dmapi/dmvars"
 	"sdmm/third_party/sdmmparser"
 )
@@ -15,9 +19,39 @@ type Dme struct {
 	RootDir  string
 	RootFile string
 	Objects  map[string]*Object
+
+	// mu guards objects and their variables, which are replaced on the reload by the UI thread,
+	// while background jobs (like snapshot commits) look them up with the ObjectVars method.
+	mu sync.RWMutex
+
+	// Diagnostics are problems found by the parser. The parser recovers from errors,
+	// so environments with errors are created too, but some of their objects could be missing.
+	Diagnostics []Diagnostic
+
+	ctx     *Context
+	ctxOnce sync.Once
 }
 
+// New parses the environment by the provided path.
+// Errors of the environment code don't fail the parsing, they are kept in the environment diagnostics.
 func New(path string) (*Dme, error) {
+	parsed, err := sdmmparser.ParseEnvironment(path)
+	if err != nil {
+		return nil, fmt.Errorf("[dmenv] unable to create dme by path [%s]: %w", path, err)
+	}
+	return newFromParsed(path, parsed), nil
+}
+
+func newFromParsed(path string, parsed *sdmmparser.Environment) *Dme {
+	dme := newFromTree(path, &parsed.Tree)
+	dme.Diagnostics = makeDiagnostics(parsed.Diagnostics)
+	if errors := dme.Errors(); errors != 0 {
+		log.Printf("[dmenv] environment [%s] has errors: [%d]", path, errors)
+	}
+	return dme
+}
+
+func newFromTree(path string, objectTreeType *sdmmparser.ObjectTreeType) *Dme {
 	dme := Dme{
 		Name:     filepath.Base(path),
 		RootDir:  filepath.Dir(path),
@@ -25,11 +59,6 @@ func New(path string) (*Dme, error) {
 		Objects:  make(map[string]*Object),
 	}
 
-	objectTreeType, err := sdmmparser.ParseEnvironment(path)
-	if err != nil {
-		return nil, fmt.Errorf("[dmenv] unable to create dme by path [%s]: %w", path, err)
-	}
-
 	traverseTree0(objectTreeType, "", &dme)
 
 	linkPathFamily(&dme, "/atom", "/datum")
@@ -45,7 +74,104 @@ func New(path string) (*Dme, error) {
 		}
 	}
 
-	return &dme, nil
+	return &dme
+}
+
+// NewEmpty creates an environment without objects for the provided path.
+// All types of maps opened with such environment are unknown, which is enough when types don't matter (like for headless commands).
+func NewEmpty(path string) *Dme {
+	return &Dme{
+		Name:     filepath.Base(path),
+		RootDir:  filepath.Dir(path),
+		RootFile: path,
+		Objects:  make(map[string]*Object),
+	}
+}
+
+// Context returns the context of the environment. The context is created on the first call.
+func (d *Dme) Context() *Context {
+	d.ctxOnce.Do(func() {
+		d.ctx = newContext(d)
+	})
+	return d.ctx
+}
+
+// Reload replaces objects of the environment with objects of the provided one, which is its newly parsed version.
+// The environment is modified in place, so opened maps keep working with it: prefabs of the context are relinked
+// to new objects and keep their IDs, which keeps the history of maps valid.
+// Returns sorted paths of types which were removed from the environment, but are still used by prefabs.
+// Prefabs of such types are kept as unknown ones.
+func (d *Dme) Reload(parsed *Dme) []string {
+	ctx := d.Context()
+
+	for _, object := range parsed.Objects {
+		object.env = d
+	}
+
+	removed := make(map[string]bool)
+	var notRelinked int
+
+	d.mu.Lock()
+	oldObjects := d.Objects
+	d.Objects = parsed.Objects
+	d.Diagnostics = parsed.Diagnostics
+
+	// Deleted prefabs could still be used by the history of maps, so they are relinked too.
+	ctx.Prefabs.forEachWithDeleted(func(prefab *dmmprefab.Prefab) {
+		var vars, oldVars *dmvars.Variables
+		object, ok := d.Objects[prefab.Path()]
+		if ok {
+			vars = object.Vars
+		}
+		if oldObject, known := oldObjects[prefab.Path()]; known {
+			oldVars = oldObject.Vars
+			if !ok {
+				removed[prefab.Path()] = true
+			}
+		}
+		if prefab.Vars() != nil && !prefab.Vars().RelinkParent(oldVars, vars) {
+			notRelinked++
+		}
+	})
+	d.mu.Unlock()
+
+	if notRelinked != 0 {
+		log.Printf("[dmenv] [%d] prefabs are not linked to their objects, so they are not relinked", notRelinked)
+	}
+
+	ctx.loadWorld()
+
+	removedPaths := make([]string, 0, len(removed))
+	for path := range removed {
+		removedPaths = append(removedPaths, path)
+	}
+	sort.Strings(removedPaths)
+
+	log.Printf("[dmenv] environment reloaded [%s]: [%d] objects, [%d] removed types in use",
+		d.RootFile, len(d.Objects), len(removedPaths))
+
+	return removedPaths
+}
+
+// IsKnownPath returns true if there is an object with the provided path in the environment.
+// Maps can have instances of unknown types. Such instances have no initial variables
+// and are kept on the map "as is", so they won't be lost on save.
+func (d *Dme) IsKnownPath(path string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	_, ok := d.Objects[path]
+	return ok
+}
+
+// ObjectVars returns variables of the environment object with the provided path.
+// The second value is false for unknown paths.
+func (d *Dme) ObjectVars(path string) (*dmvars.Variables, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if object, ok := d.Objects[path]; ok {
+		return object.Vars, true
+	}
+	return nil, false
 }
 
 func nameFromPath(path string, parentName string) string {
@@ -57,11 +183,24 @@ func nameFromPath(path string, parentName string) string {
 
 func traverseTree0(root *sdmmparser.ObjectTreeType, parentName string, dme *Dme) {
 	variables := dmvars.MutableVariables{}
+	varsDecls := make(map[string]VarDecl)
 	var name string
 
 	for _, treeVar := range root.Vars {
 		value := sanitizeVar(treeVar.Value)
 
+		if decl := treeVar.Decl; decl != nil {
+			varsDecls[treeVar.Name] = VarDecl{
+				Owner:  decl.Owner,
+				Type:   decl.Type,
+				Tmp:    decl.Tmp,
+				Const:  decl.Const,
+				Static: decl.Static,
+				File:   decl.File,
+				Line:   decl.Line,
+			}
+		}
+
 		if treeVar.Name == "name" {
 			if value == dmvars.NullValue {
 				value = nameFromPath(root.Path, parentName)
@@ -88,6 +227,7 @@ func traverseTree0(root *sdmmparser.ObjectTreeType, parentName string, dme *Dme)
 		Path:           root.Path,
 		Vars:           variables.ToImmutable(),
 		DirectChildren: children,
+		VarsDecls:      varsDecls,
 	}
 }
 
@@ -0,0 +1,127 @@
+package dmenv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"sdmm/dmapi/dmvars"
+	"sdmm/third_party/sdmmparser"
+)
+
+func TestDmeReload(t *testing.T) {
+	env := newTestDme("32", "/turf")
+	ctx := env.Context()
+
+	floor := ctx.Prefabs.Initial("/turf/floor")
+	edited := ctx.Prefabs.Get("/turf", dmvars.Set(dmvars.FromParent(env.Objects["/turf"].Vars), "dir", "4"))
+	unknown := ctx.Prefabs.Initial("/obj/unknown")
+	// Deleted prefabs could be used by the history of maps.
+	deleted := ctx.Prefabs.Get("/turf", dmvars.Set(dmvars.FromParent(env.Objects["/turf"].Vars), "dir", "8"))
+	ctx.Prefabs.Delete(deleted)
+
+	parsed := newTestDme("64", "/turf")
+	delete(parsed.Objects, "/turf/floor")
+	parsed.Objects["/obj/unknown"] = &Object{Path: "/obj/unknown", Vars: dmvars.Set(&dmvars.Variables{}, "name", `"known"`)}
+	parsed.Diagnostics = []Diagnostic{{File: "code/file.dm", Line: 1, Severity: SeverityWarning, Msg: "unused"}}
+
+	removed := env.Reload(parsed)
+
+	// Only types which were known before are reported.
+	assert.Equal(t, []string{"/turf/floor"}, removed)
+	assert.True(t, env.Objects["/obj/unknown"].env == env)
+	assert.Equal(t, 64, ctx.WorldIconSize)
+	assert.Equal(t, parsed.Diagnostics, env.Diagnostics)
+
+	// Prefabs are kept with the same IDs, but linked to new objects.
+	assert.True(t, edited.Vars().Parent() == parsed.Objects["/turf"].Vars)
+	assert.True(t, deleted.Vars().Parent() == parsed.Objects["/turf"].Vars)
+	assert.Equal(t, `"known"`, unknown.Vars().ValueV("name", ""))
+	assert.False(t, floor.Vars().HasParent())
+	assert.True(t, floor == ctx.Prefabs.Initial("/turf/floor"))
+	assert.True(t, ctx.BaseTurf == ctx.Prefabs.Initial("/turf"))
+}
+
+func TestDmeReload_Concurrent(t *testing.T) {
+	env := newTestDme("32", "/turf")
+	ctx := env.Context()
+
+	// Background jobs look up objects, while the UI thread reloads the environment.
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			if vars, ok := env.ObjectVars("/turf"); ok {
+				ctx.Prefabs.Get("/turf", dmvars.Set(dmvars.FromParent(vars), "dir", "4"))
+			}
+		}
+	}()
+
+	for i := 0; i < 10; i++ {
+		env.Reload(newTestDme("32", "/turf"))
+	}
+	<-done
+
+	assert.Equal(t, 32, ctx.WorldIconSize)
+}
+
+func TestObjectVarDecl(t *testing.T) {
+	cacheDecl := &sdmmparser.ObjectTreeVarDecl{Owner: "/datum/thing", Type: "/list", Tmp: true, File: "code/thing.dm", Line: 3}
+	tagDecl := &sdmmparser.ObjectTreeVarDecl{Owner: "/datum"}
+
+	root := &sdmmparser.ObjectTreeType{
+		Path: "/datum",
+		Vars: []sdmmparser.ObjectTreeVar{{Name: "tag", Value: "null", Decl: tagDecl}},
+		Children: []sdmmparser.ObjectTreeType{{
+			Path: "/datum/thing",
+			Vars: []sdmmparser.ObjectTreeVar{
+				{Name: "tag", Value: `"thing"`, Decl: tagDecl},
+				{Name: "cache", Value: "null", Decl: cacheDecl},
+				{Name: "name", Value: "null"},
+			},
+			Children: []sdmmparser.ObjectTreeType{{Path: "/datum/thing/child"}},
+		}},
+	}
+
+	dme := &Dme{Objects: make(map[string]*Object)}
+	traverseTree0(root, "", dme)
+	linkPathFamily(dme, "/datum", "")
+
+	child := dme.Objects["/datum/thing/child"]
+
+	decl, ok := child.VarDecl("cache")
+	assert.True(t, ok)
+	assert.Equal(t, VarDecl{Owner: "/datum/thing", Type: "/list", Tmp: true, File: "code/thing.dm", Line: 3}, decl)
+	assert.True(t, decl.IsHidden())
+
+	decl, ok = child.VarDecl("tag")
+	assert.True(t, ok)
+	assert.Equal(t, "/datum", decl.Owner)
+	assert.False(t, decl.IsHidden())
+
+	// Vars without a declaration found by the parser are unknown.
+	_, ok = child.VarDecl("name")
+	assert.False(t, ok)
+	_, ok = dme.Objects["/datum"].VarDecl("cache")
+	assert.False(t, ok)
+}
+
+func TestNewFromParsed(t *testing.T) {
+	warning := sdmmparser.Diagnostic{File: "code/file.dm", Line: 3, Column: 5, Severity: "warning", Description: "unused"}
+	parseErr := sdmmparser.Diagnostic{File: "code/file.dm", Line: 7, Column: 1, Severity: "error", Description: "bad"}
+
+	dme := newFromParsed("env.dme", &sdmmparser.Environment{Diagnostics: []sdmmparser.Diagnostic{warning}})
+	assert.Equal(t, []Diagnostic{{File: "code/file.dm", Line: 3, Column: 5, Severity: SeverityWarning, Msg: "unused"}}, dme.Diagnostics)
+	assert.Equal(t, "code/file.dm:3:5: warning: unused", dme.Diagnostics[0].String())
+	assert.Equal(t, 0, dme.Errors())
+
+	// Errors don't prevent the environment from loading too.
+	dme = newFromParsed("env.dme", &sdmmparser.Environment{
+		Tree:        sdmmparser.ObjectTreeType{Children: []sdmmparser.ObjectTreeType{{Path: "/obj"}}},
+		Diagnostics: []sdmmparser.Diagnostic{warning, parseErr},
+	})
+	assert.Contains(t, dme.Objects, "/obj")
+	assert.Len(t, dme.Diagnostics, 2)
+	assert.Equal(t, 1, dme.Errors())
+	assert.Equal(t, "code/file.dm:7:1: error: bad", dme.Diagnostics[1].String())
+}
@@ -11,8 +11,43 @@ type Object struct {
 	Vars           *dmvars.Variables
 	Path           string
 	DirectChildren []string
+
+	// VarsDecls are declarations of vars which are declared or overridden by the object itself.
+	VarsDecls map[string]VarDecl
+}
+
+// VarDecl is a declaration of the object var.
+type VarDecl struct {
+	// Owner is a type path of the object which declares the var.
+	Owner string
+	// Type is a declared type of the var, e.g. "/list" for "var/list/x". Empty when the var is untyped.
+	Type string
+
+	Tmp    bool
+	Const  bool
+	Static bool
+
+	// File is a path relative to the environment root directory. Empty for builtin vars.
+	File string
+	Line int
+}
+
+// IsHidden returns true for vars which are not expected to be edited on the map.
+func (d VarDecl) IsHidden() bool {
+	return d.Tmp || d.Const || d.Static
 }
 
 func (o *Object) Parent() *Object {
 	return o.parent
 }
+
+// VarDecl returns a declaration of the var with the provided name. Parents of the object are checked as well.
+// The second value is false for unknown vars.
+func (o *Object) VarDecl(name string) (VarDecl, bool) {
+	for object := o; object != nil; object = object.parent {
+		if decl, ok := object.VarsDecls[name]; ok {
+			return decl, true
+		}
+	}
+	return VarDecl{}, false
+}
@@ -0,0 +1,148 @@
+package dmenv
+
+import (
+	"log"
+	"sync"
+
+	"sdmm/dmapi/dmmap/dmmdata/dmmprefab"
+	"sdmm/dmapi/dmvars"
+)
+
+// PrefabStorage keeps prefabs used by maps of the environment, so the same prefab is shared by all its instances.
+// The storage is used by the UI thread and by background snapshot commits, so it's guarded with a lock.
+type PrefabStorage struct {
+	mu sync.RWMutex
+
+	env *Dme
+
+	prefabs       map[uint64]*dmmprefab.Prefab
+	prefabsByPath map[string][]*dmmprefab.Prefab
+
+	// Deleted prefabs could still be used by the history of maps, so they are kept to be relinked on reload.
+	// They are persisted again, when the same prefab is requested.
+	deleted map[uint64]*dmmprefab.Prefab
+}
+
+func newPrefabStorage(env *Dme) *PrefabStorage {
+	return &PrefabStorage{
+		env:           env,
+		prefabs:       make(map[uint64]*dmmprefab.Prefab),
+		prefabsByPath: make(map[string][]*dmmprefab.Prefab),
+		deleted:       make(map[uint64]*dmmprefab.Prefab),
+	}
+}
+
+func (s *PrefabStorage) Free() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("[dmenv] prefabs free; [%d] prefabs disposed", len(s.prefabs))
+	s.prefabs = make(map[uint64]*dmmprefab.Prefab)
+	s.prefabsByPath = make(map[string][]*dmmprefab.Prefab)
+	s.deleted = make(map[uint64]*dmmprefab.Prefab)
+}
+
+// Put persists the provided prefab in the storage.
+func (s *PrefabStorage) Put(prefab *dmmprefab.Prefab) *dmmprefab.Prefab {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cachedPrefab, ok := s.prefabs[prefab.Id()]; ok {
+		return cachedPrefab
+	}
+	if deletedPrefab, ok := s.deleted[prefab.Id()]; ok {
+		s.persist(deletedPrefab)
+		return deletedPrefab
+	}
+	if prefab.Id() != dmmprefab.IdStage { // Ignore staged prefabs.
+		s.persist(prefab)
+	}
+	return prefab
+}
+
+// Initial returns a prefab with an initial state (initial prefabs).
+// Prefabs of unknown types have no variables in the initial state.
+func (s *PrefabStorage) Initial(path string) *dmmprefab.Prefab {
+	if vars, ok := s.env.ObjectVars(path); ok {
+		return s.Get(path, dmvars.FromParent(vars))
+	}
+	return s.Get(path, &dmvars.Variables{})
+}
+
+// Get returns a prefab for the provided path and variables.
+func (s *PrefabStorage) Get(path string, vars *dmvars.Variables) *dmmprefab.Prefab {
+	p, _ := s.GetV(path, vars)
+	return p
+}
+
+// GetV returns a prefab for the provided path and variables.
+// Same as Get but has the second argument which shows if the prefab was created.
+func (s *PrefabStorage) GetV(path string, vars *dmvars.Variables) (*dmmprefab.Prefab, bool) {
+	id := dmmprefab.Id(path, vars)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if prefab, ok := s.prefabs[id]; ok {
+		return prefab, false
+	}
+	if prefab, ok := s.deleted[id]; ok {
+		s.persist(prefab)
+		return prefab, true
+	}
+	prefab := dmmprefab.New(id, path, vars)
+	s.persist(prefab)
+	return prefab, true
+}
+
+// Delete deletes the provided prefab from the storage.
+func (s *PrefabStorage) Delete(prefab *dmmprefab.Prefab) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.prefabs[prefab.Id()]; !ok {
+		return
+	}
+	s.deleted[prefab.Id()] = s.prefabs[prefab.Id()]
+	delete(s.prefabs, prefab.Id())
+	prefabs := s.prefabsByPath[prefab.Path()]
+	for idx, p := range prefabs {
+		if p.Id() == prefab.Id() {
+			// Slices returned by GetAllByPath could be in use, so a new slice is created.
+			s.prefabsByPath[prefab.Path()] = append(prefabs[:idx:idx], prefabs[idx+1:]...)
+			break
+		}
+	}
+}
+
+// GetById returns a prefab by the provided id. If the prefab is a null, the second return value will be a "false".
+func (s *PrefabStorage) GetById(id uint64) (*dmmprefab.Prefab, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	prefab, ok := s.prefabs[id]
+	return prefab, ok
+}
+
+// GetAllByPath returns a slice of prefabs for the provided path.
+// The returned slice is never modified by the storage.
+func (s *PrefabStorage) GetAllByPath(path string) []*dmmprefab.Prefab {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	prefabs := s.prefabsByPath[path]
+	return prefabs[:len(prefabs):len(prefabs)]
+}
+
+// Calls the provided function for every stored prefab, deleted ones included.
+// The storage is locked during the call, so prefabs can't be added or deleted meanwhile.
+func (s *PrefabStorage) forEachWithDeleted(f func(prefab *dmmprefab.Prefab)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, prefab := range s.prefabs {
+		f(prefab)
+	}
+	for _, prefab := range s.deleted {
+		f(prefab)
+	}
+}
+
+// Should be called with the lock held.
+func (s *PrefabStorage) persist(prefab *dmmprefab.Prefab) {
+	delete(s.deleted, prefab.Id())
+	s.prefabs[prefab.Id()] = prefab
+	s.prefabsByPath[prefab.Path()] = append(s.prefabsByPath[prefab.Path()], prefab)
+}
@@ -0,0 +1,128 @@
+package dmenv
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"sdmm/dmapi/dmvars"
+)
+
+func newTestDme(iconSize, baseTurf string) *Dme {
+	world := &dmvars.MutableVariables{}
+	world.Put("icon_size", iconSize)
+	world.Put("area", "/area")
+	world.Put("turf", baseTurf)
+
+	turf := &dmvars.MutableVariables{}
+	turf.Put("name", `"turf"`)
+
+	return &Dme{
+		Objects: map[string]*Object{
+			"/world":      {Path: "/world", Vars: world.ToImmutable()},
+			"/area":       {Path: "/area", Vars: &dmvars.Variables{}},
+			"/turf":       {Path: "/turf", Vars: turf.ToImmutable()},
+			"/turf/floor": {Path: "/turf/floor", Vars: &dmvars.Variables{}},
+		},
+	}
+}
+
+// Should be run with the race detector to make sense.
+func TestPrefabStorageConcurrentUsage(t *testing.T) {
+	storage := newTestDme("32", "/turf").Context().Prefabs
+
+	const workers, prefabs = 8, 100
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < prefabs; i++ {
+				vars := dmvars.Set(&dmvars.Variables{}, "name", strconv.Itoa(i))
+				prefab := storage.Get("/obj", vars)
+				assert.True(t, prefab == storage.Put(prefab))
+
+				if i%workers == w {
+					storage.Delete(prefab)
+				}
+				for _, p := range storage.GetAllByPath("/obj") {
+					storage.GetById(p.Id())
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+func TestPrefabStorageDelete(t *testing.T) {
+	storage := newTestDme("32", "/turf").Context().Prefabs
+
+	vars := dmvars.Set(&dmvars.Variables{}, "name", `"thing"`)
+	prefab := storage.Get("/obj", vars)
+	storage.Delete(prefab)
+
+	_, ok := storage.GetById(prefab.Id())
+	assert.False(t, ok)
+	assert.Empty(t, storage.GetAllByPath("/obj"))
+
+	// Deleted prefabs could be still used, so the same prefab is returned when it's requested again.
+	restored, isNew := storage.GetV("/obj", vars)
+	assert.True(t, isNew)
+	assert.True(t, prefab == restored)
+	assert.True(t, prefab == storage.Put(prefab))
+	assert.Len(t, storage.GetAllByPath("/obj"), 1)
+}
+
+func TestContextIndependentEnvironments(t *testing.T) {
+	first := newTestDme("32", "/turf").Context()
+	second := newTestDme("64", "/turf/floor").Context()
+
+	assert.Equal(t, 32, first.WorldIconSize)
+	assert.Equal(t, 64, second.WorldIconSize)
+	assert.Equal(t, "/turf", first.BaseTurf.Path())
+	assert.Equal(t, "/turf/floor", second.BaseTurf.Path())
+
+	// Initial prefabs are linked with objects of their own environment.
+	initial := first.Prefabs.Initial("/turf")
+	assert.Equal(t, `"turf"`, initial.Vars().ValueV("name", ""))
+	assert.True(t, initial == first.Prefabs.Initial("/turf"))
+
+	// Prefabs stored in one environment are unknown for another one.
+	_, ok := second.Prefabs.GetById(initial.Id())
+	assert.False(t, ok)
+
+	first.Free()
+	_, ok = first.Prefabs.GetById(initial.Id())
+	assert.False(t, ok)
+	_, ok = second.Prefabs.GetById(second.BaseTurf.Id())
+	assert.True(t, ok)
+}
+
+func TestContextWithoutWorld(t *testing.T) {
+	ctx := (&Dme{Objects: map[string]*Object{}}).Context()
+
+	assert.Equal(t, 32, ctx.WorldIconSize)
+	assert.Equal(t, "/area", ctx.BaseArea.Path())
+	assert.Equal(t, "/turf", ctx.BaseTurf.Path())
+	assert.True(t, ctx.Env().Context() == ctx)
+}
+
+func TestContextRemap(t *testing.T) {
+	source := newTestDme("32", "/turf").Context()
+	target := newTestDme("32", "/turf").Context()
+	delete(target.Env().Objects, "/turf/floor")
+
+	prefab := source.Prefabs.Get("/turf", dmvars.Set(dmvars.FromParent(source.Env().Objects["/turf"].Vars), "dir", "4"))
+	remapped, ok := target.Remap(prefab)
+	assert.True(t, ok)
+	assert.Equal(t, prefab.Id(), remapped.Id())
+	assert.Equal(t, "4", remapped.Vars().ValueV("dir", ""))
+	assert.True(t, remapped.Vars().Parent() == target.Env().Objects["/turf"].Vars)
+	assert.True(t, remapped == target.Prefabs.Put(prefab))
+
+	_, ok = target.Remap(source.Prefabs.Initial("/turf/floor"))
+	assert.False(t, ok)
+}
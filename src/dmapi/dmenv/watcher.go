@@ -0,0 +1,180 @@
+package dmenv
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Watcher looks for modifications of the environment code files (.dm and .dme).
+// Only files included into the environment are watched, so the rest of the environment directory doesn't matter.
+// Files are polled, since the editor should work the same way on every platform without native dependencies.
+type Watcher struct {
+	rootFile string
+	onChange func()
+
+	files map[string]fileState
+
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+// fileState is a zero value for files which don't exist.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+func (s fileState) equals(other fileState) bool {
+	return s.modTime.Equal(other.modTime) && s.size == other.size
+}
+
+// NewWatcher creates a watcher for the environment. The onChange callback is called from the watcher goroutine.
+func NewWatcher(env *Dme, onChange func()) *Watcher {
+	return &Watcher{
+		rootFile: env.RootFile,
+		onChange: onChange,
+		files:    scanIncludedFiles(env.RootFile),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start runs the watcher in the background. Files are checked with the provided period.
+func (w *Watcher) Start(period time.Duration) {
+	log.Printf("[dmenv] watching [%s] with [%d] files every [%s]", w.rootFile, len(w.files), period)
+	go func() {
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				if w.check() {
+					w.onChange()
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops the watcher. It's safe to call it several times.
+func (w *Watcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+		log.Println("[dmenv] watcher stopped:", w.rootFile)
+	})
+}
+
+// Returns true if included code files were modified, created or deleted since the previous check.
+// Files are only checked for their state, while their includes are read again only when something is changed.
+func (w *Watcher) check() bool {
+	changed := false
+	for path, state := range w.files {
+		if !statCodeFile(path).equals(state) {
+			changed = true
+			break
+		}
+	}
+	if changed {
+		w.files = scanIncludedFiles(w.rootFile)
+		log.Println("[dmenv] environment code modified:", w.rootFile)
+	}
+	return changed
+}
+
+// Returns states of the environment file and all code files it includes recursively.
+// Included files which don't exist are kept with the zero state, so their creation is noticed too.
+func scanIncludedFiles(rootFile string) map[string]fileState {
+	files := make(map[string]fileState)
+
+	var scan func(path string)
+	scan = func(path string) {
+		if _, ok := files[path]; ok {
+			return
+		}
+		state := statCodeFile(path)
+		files[path] = state
+		if state.equals(fileState{}) {
+			return
+		}
+		for _, include := range readIncludes(path) {
+			scan(include)
+		}
+	}
+
+	scan(rootFile)
+	return files
+}
+
+// Returns states of all code files in the root directory, including files which aren't included into the environment.
+func scanCodeFiles(rootDir string) map[string]fileState {
+	files := make(map[string]fileState)
+	err := filepath.WalkDir(rootDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Files could be deleted while walking.
+		}
+		if entry.IsDir() {
+			// Skip hidden directories, like ".git".
+			if path != rootDir && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ext := filepath.Ext(path); ext != ".dm" && ext != ".dme" {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			files[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[dmenv] unable to scan environment files [%s]: %v", rootDir, err)
+	}
+	return files
+}
+
+func statCodeFile(path string) fileState {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{modTime: info.ModTime(), size: info.Size()}
+}
+
+// Returns paths of code files included with the #include directive. Paths are relative to the including file.
+// Directives are not preprocessed, so files from disabled #if blocks are included too.
+func readIncludes(path string) (includes []string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("[dmenv] unable to read environment file [%s]: %v", path, err)
+		return nil
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "#") || !strings.HasPrefix(strings.TrimSpace(line[1:]), "include") {
+			continue
+		}
+
+		start, end := strings.IndexByte(line, '"'), strings.LastIndexByte(line, '"')
+		if start == -1 || end <= start {
+			continue
+		}
+
+		// Paths are usually written with Windows separators.
+		include := filepath.FromSlash(strings.ReplaceAll(line[start+1:end], "\\", "/"))
+		if ext := strings.ToLower(filepath.Ext(include)); ext != ".dm" && ext != ".dme" {
+			continue
+		}
+
+		includes = append(includes, filepath.Join(filepath.Dir(path), include))
+	}
+
+	return includes
+}
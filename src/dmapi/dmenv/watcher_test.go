@@ -0,0 +1,64 @@
+package dmenv
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcherCheck(t *testing.T) {
+	envPath, code := newTestEnvDir(t)
+	dir := filepath.Dir(envPath)
+
+	w := NewWatcher(&Dme{RootFile: envPath}, nil)
+	assert.False(t, w.check())
+
+	// Files which are not included are ignored.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "map.dmm"), []byte{}, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "code", "other.dm"), []byte{}, 0644))
+	assert.False(t, w.check())
+
+	modTime := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(code, modTime, modTime))
+	assert.True(t, w.check())
+	assert.False(t, w.check())
+
+	// Includes are relative to the including file and are written with Windows separators.
+	require.NoError(t, os.WriteFile(code, []byte("/obj\r\n#include \"nested\\new.dm\"\r\n"), 0644))
+	assert.True(t, w.check())
+	assert.Contains(t, w.files, filepath.Join(dir, "code", "nested", "new.dm"))
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "code", "nested"), os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "code", "nested", "new.dm"), []byte{}, 0644))
+	assert.True(t, w.check())
+
+	require.NoError(t, os.Remove(code))
+	assert.True(t, w.check())
+	assert.NotContains(t, w.files, filepath.Join(dir, "code", "nested", "new.dm"))
+}
+
+func TestWatcherStart(t *testing.T) {
+	envPath, code := newTestEnvDir(t)
+
+	changed := make(chan struct{}, 1)
+	w := NewWatcher(&Dme{RootFile: envPath}, func() {
+		changed <- struct{}{}
+	})
+	w.Start(10 * time.Millisecond)
+	defer w.Stop()
+
+	require.NoError(t, os.WriteFile(code, []byte("/obj/changed"), 0644))
+
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("change isn't detected")
+	}
+
+	w.Stop()
+	w.Stop()
+}
@@ -6,29 +6,39 @@ import (
 	"log"
 
 	"sdmm/dmapi/dm"
+	"sdmm/dmapi/dmenv"
 )
 
-var Cache = &IconsCache{icons: make(map[string]*Dmi)}
-
+// IconsCache keeps icons loaded from the root directory of the environment.
 type IconsCache struct {
 	rootDirPath string
 	icons       map[string]*Dmi
 }
 
+func NewCache(rootDirPath string) *IconsCache {
+	log.Println("[dmicon] cache root dir:", rootDirPath)
+	return &IconsCache{rootDirPath: rootDirPath, icons: make(map[string]*Dmi)}
+}
+
+// CacheOf returns the icons cache of the provided environment context.
+// The cache is created on the first call. Icons are loaded by the main thread only, so there is no lock.
+func CacheOf(ctx *dmenv.Context) *IconsCache {
+	if cache, ok := ctx.Icons.(*IconsCache); ok {
+		return cache
+	}
+	cache := NewCache(ctx.Env().RootDir)
+	ctx.Icons = cache
+	return cache
+}
+
 func (i *IconsCache) Free() {
 	for _, dmi := range i.icons {
 		dmi.free()
 	}
 	log.Printf("[dmicon] cache free; [%d] icons disposed", len(i.icons))
-	i.rootDirPath = ""
 	i.icons = make(map[string]*Dmi)
 }
 
-func (i *IconsCache) SetRootDirPath(rootDirPath string) {
-	i.rootDirPath = rootDirPath
-	log.Println("[dmicon] cache root dir:", rootDirPath)
-}
-
 func (i *IconsCache) Get(icon string) (*Dmi, error) {
 	if len(icon) == 0 {
 		return nil, errors.New("dmi icon is empty")
@@ -3,8 +3,11 @@ package dmmap
 import (
 	"log"
 	"path/filepath"
+	"sync"
 
+	"sdmm/dmapi/dm"
 	"sdmm/dmapi/dmmap/dmmdata/dmmprefab"
+	"sdmm/dmapi/dmmap/dmminstance"
 
 	"sdmm/dmapi/dmenv"
 	"sdmm/dmapi/dmmap/dmmdata"
@@ -13,19 +16,48 @@ import (
 
 // Dmm stores information about the map.
 // Unlike the dmmdata.DmmData this information is needed mostly for the editor usages.
+//
+// The map is modified by the UI thread, but it's also read by background jobs (like snapshot commits).
+// Methods of the map and its tiles are safe for such usage. The Tiles slice itself is replaced
+// only by the map methods, so it should be read directly only by the thread which modifies the map.
 type Dmm struct {
 	Name string
 	Path DmmPath
 
+	// mu guards the tiles slice, the map size and the content of tiles.
+	mu    sync.RWMutex
 	Tiles []*Tile
 
 	MaxX, MaxY, MaxZ int
 
 	Backup string
+
+	ctx     *dmenv.Context
+	journal *Journal
+	index   *Index
+}
+
+// Context returns the context of the environment, which the map is opened with.
+func (d *Dmm) Context() *dmenv.Context {
+	return d.ctx
+}
+
+// Journal returns the journal of tiles modified on the map.
+func (d *Dmm) Journal() *Journal {
+	return d.journal
 }
 
-func (d *Dmm) Copy() Dmm {
-	dmm := Dmm{}
+// Index returns the index of instances on the map.
+func (d *Dmm) Index() *Index {
+	return d.index
+}
+
+// Copy returns a deep copy of the map. The copy has its own journal, so its modifications aren't recorded for the original map.
+func (d *Dmm) Copy() *Dmm {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	dmm := &Dmm{ctx: d.ctx, journal: newJournal()}
 	dmm.Name = d.Name
 	dmm.Path = d.Path
 	dmm.MaxX = d.MaxX
@@ -36,61 +68,111 @@ func (d *Dmm) Copy() Dmm {
 	// Do a deep copy for tiles
 	dmm.Tiles = make([]*Tile, 0, len(d.Tiles))
 	for _, t := range d.Tiles {
-		tile := t.Copy()
+		tile := t.copy()
+		tile.attach(dmm)
 		dmm.Tiles = append(dmm.Tiles, &tile)
 	}
 
+	dmm.index = newIndex(dmm)
+
 	return dmm
 }
 
 func (d *Dmm) HasTile(coord util.Point) bool {
-	return coord.X > 0 && coord.Y > 0 && coord.Z > 0 && coord.X <= d.MaxX && coord.Y <= d.MaxY && coord.Z <= d.MaxZ
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.hasTile(coord)
 }
 
 func (d *Dmm) GetTile(coord util.Point) *Tile {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.getTile(coord)
+}
+
+// GetTileV returns a tile for the provided coord.
+// Same as GetTile but has the second return value, which is false if the map has no such tile.
+func (d *Dmm) GetTileV(coord util.Point) (*Tile, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if !d.hasTile(coord) {
+		return nil, false
+	}
+	return d.getTile(coord), true
+}
+
+// AllTiles returns a copy of the tiles slice. Unlike the Tiles field, it's safe to use from any goroutine.
+func (d *Dmm) AllTiles() []*Tile {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	tiles := make([]*Tile, len(d.Tiles))
+	copy(tiles, d.Tiles)
+	return tiles
+}
+
+func (d *Dmm) hasTile(coord util.Point) bool {
+	return coord.X > 0 && coord.Y > 0 && coord.Z > 0 && coord.X <= d.MaxX && coord.Y <= d.MaxY && coord.Z <= d.MaxZ
+}
+
+func (d *Dmm) getTile(coord util.Point) *Tile {
 	return d.Tiles[d.tileIndex(coord.X, coord.Y, coord.Z)]
 }
 
 // IsInstanceExist returns true if there is an instance with the provided ID on the map.
 func (d *Dmm) IsInstanceExist(instanceId uint64) bool {
-	for _, tile := range d.Tiles {
-		for _, instance := range tile.instances {
-			if instance.Id() == instanceId {
-				return true
+	_, ok := d.index.InstanceCoord(instanceId)
+	return ok
+}
+
+// InstancesByPrefabId returns all instances on the map with the provided prefab ID.
+func (d *Dmm) InstancesByPrefabId(prefabId uint64) (result []*dmminstance.Instance) {
+	for _, coord := range d.index.CoordsByPrefabId(prefabId) {
+		if tile, ok := d.GetTileV(coord); ok {
+			for _, instance := range tile.Instances() {
+				if instance.Prefab().Id() == prefabId {
+					result = append(result, instance)
+				}
 			}
 		}
 	}
-	return false
+	return result
 }
 
-func (d *Dmm) SetMapSize(maxX, maxY, maxZ int) {
-	newTiles := make([]*Tile, maxX*maxY*maxZ)
-
-	for z := 1; z <= maxZ; z++ {
-		for y := 1; y <= maxY; y++ {
-			for x := 1; x <= maxX; x++ {
-				coord := util.Point{X: x, Y: y, Z: z}
-				tileIndex := tileIndex(maxX, maxY, x, y, z)
-				if d.HasTile(coord) {
-					newTiles[tileIndex] = d.GetTile(util.Point{X: x, Y: y, Z: z})
-				} else {
-					// Fill an empty tile with basic prefabs.
-					newTiles[tileIndex] = &Tile{
-						Coord:     coord,
-						instances: InstancesFromPrefabs(coord, dmmdata.Prefabs{BaseTurf, BaseArea}),
-					}
+// InstancesByPath returns all instances on the map with the provided type path.
+// If subtypes is true, instances of subtypes are returned as well.
+func (d *Dmm) InstancesByPath(path string, subtypes bool) (result []*dmminstance.Instance) {
+	for _, coord := range d.index.CoordsByPath(path, subtypes) {
+		if tile, ok := d.GetTileV(coord); ok {
+			for _, instance := range tile.Instances() {
+				if instancePath := instance.Prefab().Path(); instancePath == path || subtypes && dm.IsPath(instancePath, path) {
+					result = append(result, instance)
 				}
 			}
 		}
 	}
+	return result
+}
+
+// SetMapSize changes the size of the map. Tiles are grown or cut at the max edge of the map.
+func (d *Dmm) SetMapSize(maxX, maxY, maxZ int) {
+	d.SetMapSizeV(maxX, maxY, maxZ, AnchorSouthWest)
+}
+
+// SetTiles replaces all tiles of the map. Tiles are expected to fit the current map size.
+func (d *Dmm) SetTiles(tiles []*Tile) {
+	d.mu.Lock()
+	for _, tile := range tiles {
+		tile.attach(d)
+	}
+	d.Tiles = tiles
+	d.mu.Unlock()
 
-	d.Tiles = newTiles
-	d.MaxX = maxX
-	d.MaxY = maxY
-	d.MaxZ = maxZ
+	// The journal updates the index, which reads tiles with the map lock.
+	d.journal.TouchAll()
 }
 
 func (d *Dmm) setTile(x, y, z int, tile *Tile) {
+	tile.attach(d)
 	d.Tiles[d.tileIndex(x, y, z)] = tile
 }
 
@@ -103,38 +185,59 @@ func tileIndex(maxX, maxY, x, y, z int) int {
 	return maxX*maxY*(z-1) + maxX*(y-1) + (x - 1)
 }
 
-func New(dme *dmenv.Dme, data *dmmdata.DmmData, backup string) (dmm *Dmm, unknownPrefabs map[string]*dmmprefab.Prefab) {
+// New creates a map from the provided data. Prefabs of the map are stored in the provided environment context.
+// Prefabs with types unknown for the environment are kept on the map "as is", so they are saved back without changes.
+// Those prefabs are returned as the second value by their paths.
+func New(ctx *dmenv.Context, data *dmmdata.DmmData, backup string) (dmm *Dmm, unknownPrefabs map[string]*dmmprefab.Prefab) {
 	unknownPrefabs = make(map[string]*dmmprefab.Prefab)
 	dmm = &Dmm{
 		Name:  filepath.Base(data.Filepath),
-		Path:  newDmmPath(dme.RootDir, data),
+		Path:  newDmmPath(ctx.Env().RootDir, data),
 		Tiles: make([]*Tile, data.MaxX*data.MaxY*data.MaxZ),
 		MaxX:  data.MaxX,
 		MaxY:  data.MaxY,
 		MaxZ:  data.MaxZ,
 
 		Backup: backup,
+
+		ctx:     ctx,
+		journal: newJournal(),
 	}
+	dmm.index = newIndex(dmm)
 
+	// Prefabs are resolved once for every key, since the same key is used by many tiles.
+	prefabsByKey := make(map[dmmdata.Key]dmmdata.Prefabs, len(data.Dictionary))
+	for key, prefabs := range data.Dictionary {
+		stored := make(dmmdata.Prefabs, 0, len(prefabs))
+		for _, prefab := range prefabs {
+			if vars, ok := ctx.Env().ObjectVars(prefab.Path()); ok {
+				// Prefabs from the dmmdata don't know about environment objects.
+				if !prefab.Vars().HasParent() {
+					prefab.Vars().LinkParent(vars)
+				}
+			} else if _, ok := unknownPrefabs[prefab.Path()]; !ok {
+				log.Println("[dmmap] unknown prefab:", prefab.Path())
+				unknownPrefabs[prefab.Path()] = prefab
+			}
+			stored = append(stored, ctx.Prefabs.Put(prefab))
+		}
+		prefabsByKey[key] = stored
+	}
+
+	tiles := make([]Tile, len(dmm.Tiles))
 	for z := 1; z <= data.MaxZ; z++ {
 		for y := 1; y <= data.MaxY; y++ {
 			for x := 1; x <= data.MaxX; x++ {
-				tile := Tile{Coord: util.Point{X: x, Y: y, Z: z}}
-
-				for _, prefab := range data.Dictionary[data.Grid[tile.Coord]] {
-					if obj, ok := dme.Objects[prefab.Path()]; ok {
-						// Prefabs from the dmmdata don't know about environment objects.
-						if !prefab.Vars().HasParent() {
-							prefab.Vars().LinkParent(obj.Vars)
-						}
-						tile.InstancesAdd(PrefabStorage.Put(prefab))
-					} else {
-						log.Println("[dmmap] unknown prefab:", prefab.Path())
-						unknownPrefabs[prefab.Path()] = prefab
-					}
+				tile := &tiles[tileIndex(data.MaxX, data.MaxY, x, y, z)]
+				tile.Coord = util.Point{X: x, Y: y, Z: z}
+
+				prefabs := prefabsByKey[data.Grid.Get(tile.Coord)]
+				tile.instances = make(Instances, 0, len(prefabs))
+				for _, prefab := range prefabs {
+					tile.InstancesAdd(prefab)
 				}
 
-				dmm.setTile(x, y, z, &tile)
+				dmm.setTile(x, y, z, tile)
 			}
 		}
 	}
@@ -144,9 +247,9 @@ func New(dme *dmenv.Dme, data *dmmdata.DmmData, backup string) (dmm *Dmm, unknow
 
 // PersistPrefabs persists all prefabs from instances on the current map.
 func (d *Dmm) PersistPrefabs() {
-	for _, tile := range d.Tiles {
+	for _, tile := range d.AllTiles() {
 		for _, instance := range tile.Instances() {
-			PrefabStorage.Put(instance.Prefab())
+			d.ctx.Prefabs.Put(instance.Prefab())
 		}
 	}
 }
@@ -0,0 +1,48 @@
+package dmmdata
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Severity string
+
+const (
+	// SeverityError is a problem which makes the map data unreliable, like a truncated block.
+	SeverityError Severity = "error"
+	// SeverityWarning is a problem which doesn't prevent the map from loading, like an undefined key.
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic is a problem found in the map file while parsing it.
+type Diagnostic struct {
+	Line, Column int
+	Severity     Severity
+	Msg          string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("at line %d, column %d: %s: %s", d.Line, d.Column, d.Severity, d.Msg)
+}
+
+// ParseError is returned when the map file has errors. It contains all diagnostics at once, warnings included.
+type ParseError struct {
+	Diagnostics []Diagnostic
+}
+
+func (e *ParseError) Error() string {
+	lines := make([]string, 0, len(e.Diagnostics))
+	for _, d := range e.Diagnostics {
+		lines = append(lines, d.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func hasErrors(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
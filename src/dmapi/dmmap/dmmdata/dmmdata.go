@@ -8,10 +8,7 @@ import (
 	"sdmm/util"
 )
 
-type (
-	DataDictionary map[Key]Prefabs
-	DataGrid       map[util.Point]Key
-)
+type DataDictionary map[Key]Prefabs
 
 // DmmData stores raw information about the map. Mostly needed to for parsing and saving.
 type DmmData struct {
@@ -25,14 +22,41 @@ type DmmData struct {
 
 	Dictionary DataDictionary
 	Grid       DataGrid
+
+	// Header is a comment from the first line of the file, like "//MAP CONVERTED BY dmm2tgm.py...".
+	// Such a comment makes the map TGM, so the header is written only in the TGM format.
+	Header string
+	// Blocks are grid blocks in the order they are written in the file.
+	// When they are empty or don't match the grid, the default layout of the format is used.
+	Blocks []GridBlock
+}
+
+// GridBlock is a part of the grid written in the file as "(x,y,z) = {"..."}".
+type GridBlock struct {
+	// Coord is the block position from its header. Unlike the grid, the Y axis goes from top to bottom there.
+	Coord         util.Point
+	Width, Height int
+
+	EmptyLinesBefore int
+	// EmptyRows are empty lines inside the block. Every value is an index of the row, which goes after the empty line.
+	// Empty rows are not a part of the grid, so Height counts only rows with keys.
+	EmptyRows []int
+	// Inline blocks have their content on the same line with the header: (1,1,1) = {"abc"}.
+	Inline bool
+}
+
+// Save writes the data to its file path in the format of the data with the default layout.
+func (d DmmData) Save() error {
+	return d.SaveV(false)
 }
 
-func (d DmmData) Save() {
+// SaveV writes the data to its file path in the format of the data.
+// With the preserved layout, the header and the grid blocks of the data are written instead of the default ones.
+func (d DmmData) SaveV(preserveLayout bool) error {
 	if d.IsTgm {
-		d.SaveTGM(d.Filepath)
-	} else {
-		d.SaveDM(d.Filepath)
+		return d.saveTGM(d.Filepath, preserveLayout)
 	}
+	return d.saveDM(d.Filepath, preserveLayout)
 }
 
 func (d DmmData) Keys() []Key {
@@ -66,3 +90,14 @@ func New(path string) (*DmmData, error) {
 	defer file.Close()
 	return parse(file)
 }
+
+// NewTolerant parses the map without failing on problems in its content, so damaged maps could be opened and repaired.
+// It returns the best-effort data with all found problems. The error is returned only when the file can't be read.
+func NewTolerant(path string) (*DmmData, []Diagnostic, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	return parseTolerant(file)
+}
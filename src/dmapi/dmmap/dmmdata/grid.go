@@ -0,0 +1,40 @@
+package dmmdata
+
+import "sdmm/util"
+
+// DataGrid stores keys of the map in a dense slice. Points are 1-based, as they are on the map.
+type DataGrid struct {
+	maxX, maxY, maxZ int
+	keys             []Key
+}
+
+func NewDataGrid(maxX, maxY, maxZ int) DataGrid {
+	return DataGrid{
+		maxX: maxX,
+		maxY: maxY,
+		maxZ: maxZ,
+		keys: make([]Key, maxX*maxY*maxZ),
+	}
+}
+
+// Get returns the key by the provided point. Points out of the grid have an empty key.
+func (g DataGrid) Get(point util.Point) Key {
+	if idx, ok := g.index(point); ok {
+		return g.keys[idx]
+	}
+	return ""
+}
+
+// Set sets the key by the provided point. Points out of the grid are ignored.
+func (g DataGrid) Set(point util.Point, key Key) {
+	if idx, ok := g.index(point); ok {
+		g.keys[idx] = key
+	}
+}
+
+func (g DataGrid) index(point util.Point) (int, bool) {
+	if point.X < 1 || point.X > g.maxX || point.Y < 1 || point.Y > g.maxY || point.Z < 1 || point.Z > g.maxZ {
+		return 0, false
+	}
+	return g.maxX*g.maxY*(point.Z-1) + g.maxX*(point.Y-1) + (point.X - 1), true
+}
@@ -2,18 +2,52 @@ package dmmdata
 
 type Key string
 
-const base = 52
+const (
+	base   = 52
+	base52 = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+	// MaxKeyLength is the maximum length of keys which BYOND is able to read.
+	MaxKeyLength = 3
+	// The last key of the maximum length is "ymi": https://secure.byond.com/forum/?post=2340796#comment23770802
+	maxKeyNum = 65528
+)
 
 var base52r map[rune]int
 
 func init() {
-	var base52 = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 	base52r = make(map[rune]int, len(base52))
 	for idx, c := range base52 {
 		base52r[c] = idx
 	}
 }
 
+// KeyFromNum returns a key with the provided length, which represents the provided number.
+// It's an opposite of the Key.ToNum method.
+func KeyFromNum(num, length int) Key {
+	key := make([]byte, length)
+	for idx := length - 1; idx >= 0; idx-- {
+		key[idx] = base52[num%base]
+		num /= base
+	}
+	return Key(key)
+}
+
+// KeyCapacity returns how many keys are available for the provided key length.
+// Every unique tile content on the map needs its own key.
+func KeyCapacity(keyLength int) int {
+	if keyLength < 1 || keyLength > MaxKeyLength {
+		return 0
+	}
+	if keyLength == MaxKeyLength {
+		return maxKeyNum + 1
+	}
+	capacity := 1
+	for i := 0; i < keyLength; i++ {
+		capacity *= base
+	}
+	return capacity
+}
+
 func (k Key) ToNum() int {
 	num := 0
 	for _, c := range k {
@@ -4,11 +4,10 @@ import (
 	"bufio"
 	"fmt"
 	"io"
-	"strconv"
+	"log"
 
 	"sdmm/dmapi/dmmap/dmmdata/dmmprefab"
 	"sdmm/dmapi/dmvars"
-	"sdmm/util"
 )
 
 func max(a, b int) int {
@@ -29,6 +28,23 @@ https://raw.githubusercontent.com/SpaceManiac/SpacemanDMM/5e51421/src/tools/dmm/
 Unlike the original one, doesn't care about storing keys as base52 number and uses a simple string for that.
 */
 func parse(file namedReader) (*DmmData, error) {
+	dmmData, diagnostics, err := parseTolerant(file)
+	if err != nil {
+		return nil, err
+	}
+	if hasErrors(diagnostics) {
+		return nil, &ParseError{Diagnostics: diagnostics}
+	}
+	// Warnings don't prevent the map from loading, since they were always accepted.
+	for _, diagnostic := range diagnostics {
+		log.Printf("[dmmdata] map [%s] has a problem %s", file.Name(), diagnostic)
+	}
+	return dmmData, nil
+}
+
+// parseTolerant doesn't stop on problems in the map content. All of them are returned as diagnostics,
+// while the data contains everything that was possible to read. The error is returned only when the file can't be read.
+func parseTolerant(file namedReader) (*DmmData, []Diagnostic, error) {
 	r := bufio.NewReader(file)
 
 	var (
@@ -36,11 +52,14 @@ func parse(file namedReader) (*DmmData, error) {
 		dmmData = DmmData{
 			Filepath:   file.Name(),
 			Dictionary: make(DataDictionary),
-			Grid:       make(DataGrid),
 			LineBreak:  "\n",
 		}
 
-		lineNo = 1
+		diagnostics []Diagnostic
+
+		// Position of the last read rune.
+		lineNo, colNo = 1, 0
+		prevRune      rune
 
 		inCommentLine  bool
 		commentTrigger bool
@@ -50,6 +69,17 @@ func parse(file namedReader) (*DmmData, error) {
 		inVarEditBlock bool
 		afterDataBlock = true
 		escaping       bool
+		gridStarted    bool
+		inHeader       bool
+
+		currHeader []rune
+
+		// Line where the last dictionary entry or the grid block has ended.
+		endLine int
+
+		// Positions where the current blocks have started.
+		keyLine, keyCol   int
+		dataLine, dataCol int
 
 		currData      Prefabs
 		currPath      = ""
@@ -60,6 +90,31 @@ func parse(file namedReader) (*DmmData, error) {
 		currKey []rune
 
 		// Functions:
+		readRune = func() (rune, error) {
+			c, _, err := r.ReadRune()
+			if err != nil {
+				return c, err
+			}
+			switch {
+			case c == '\n' && prevRune == '\r':
+				// CRLF is a single line break.
+			case c == '\n' || c == '\r':
+				lineNo++
+				colNo = 0
+			default:
+				colNo++
+			}
+			prevRune = c
+			return c, nil
+		}
+		report = func(line, col int, severity Severity, msg string, args ...any) {
+			diagnostics = append(diagnostics, Diagnostic{
+				Line:     line,
+				Column:   col,
+				Severity: severity,
+				Msg:      fmt.Sprintf(msg, args...),
+			})
+		}
 		flushCurrPrefab = func() {
 			currData = append(currData, dmmprefab.New(dmmprefab.IdNone, currPath, currVariables.ToImmutable()))
 			currPath = ""
@@ -74,35 +129,51 @@ func parse(file namedReader) (*DmmData, error) {
 			currVar = currVar[:0]
 			currDatum = currDatum[:0]
 		}
-		lineErr = func(msg string, args ...any) (*DmmData, error) {
-			args = append([]any{1}, args...)
-			args[0] = lineNo
-			return nil, fmt.Errorf("at line %d: "+msg, args...)
+		flushCurrKey = func() {
+			key := Key(currKey)
+			// Keys with inconsistent length are already reported and can't be used on the grid anyway.
+			if len(currKey) == dmmData.KeyLength {
+				// The last definition wins, as it always did.
+				if _, ok := dmmData.Dictionary[key]; ok {
+					report(keyLine, keyCol, SeverityWarning, "duplicate key definition [%s]", key)
+				}
+				data := make(Prefabs, len(currData))
+				copy(data, currData)
+				dmmData.Dictionary[key] = data
+			}
+			currKey = currKey[:0]
+			currData = currData[:0]
 		}
 	)
 
 	for {
-		if c, _, err := r.ReadRune(); err != nil {
+		if c, err := readRune(); err != nil {
 			if err == io.EOF {
 				break
 			} else {
-				return nil, err
+				return nil, nil, err
 			}
 		} else {
 			if c == '\n' || c == '\r' {
-				if c == '\n' {
-					lineNo++
-				} else {
+				if c == '\r' {
 					dmmData.LineBreak = "\r\n"
 				}
+				if inHeader {
+					dmmData.Header = string(currHeader)
+					inHeader = false
+				}
 				inCommentLine = false
 				commentTrigger = false
 				continue
 			} else if inCommentLine {
+				if inHeader {
+					currHeader = append(currHeader, c)
+				}
 				continue
 			} else if c == ' ' || c == '\t' {
 				if commentTrigger {
-					return lineErr("expected comment or type, got whitespace")
+					report(lineNo, colNo, SeverityError, "expected comment or type, got whitespace")
+					commentTrigger = false
 				}
 				if inQuoteBlock {
 					if c == '\t' {
@@ -120,6 +191,11 @@ func parse(file namedReader) (*DmmData, error) {
 					// If the first line and it's a comment, then we make an assumption that it's a TGM format.
 					if lineNo == 1 {
 						dmmData.IsTgm = true
+						// The comment is kept as the header, only if it takes the whole line.
+						if colNo == 2 {
+							inHeader = true
+							currHeader = append(currHeader, '/', '/')
+						}
 					}
 					continue
 				} else {
@@ -172,14 +248,10 @@ func parse(file namedReader) (*DmmData, error) {
 						flushCurrPath()
 					}
 					flushCurrPrefab()
-					key := Key(currKey)
-					currKey = currKey[:0]
-					data := make(Prefabs, len(currData))
-					copy(data, currData)
-					currData = currData[:0]
-					dmmData.Dictionary[key] = data
+					flushCurrKey()
 					inDataBlock = false
 					afterDataBlock = true
+					endLine = lineNo
 				} else {
 					currDatum = append(currDatum, c)
 				}
@@ -190,7 +262,7 @@ func parse(file namedReader) (*DmmData, error) {
 						if dmmData.KeyLength == 0 {
 							dmmData.KeyLength = len(currKey)
 						} else {
-							return lineErr("inconsistent key length: %d vs %d", dmmData.KeyLength, len(currKey))
+							report(keyLine, keyCol, SeverityError, "inconsistent key length: %d vs %d", dmmData.KeyLength, len(currKey))
 						}
 					}
 				} else {
@@ -198,141 +270,66 @@ func parse(file namedReader) (*DmmData, error) {
 				}
 			} else if c == '"' {
 				if !afterDataBlock {
-					return lineErr("failed to start a data block")
+					// The previous key is dropped, so we could continue with the current one.
+					report(keyLine, keyCol, SeverityError, "failed to start a data block for the key [%s]", string(currKey))
+					currKey = currKey[:0]
 				}
 				inKeyBlock = true
 				afterDataBlock = false
+				keyLine, keyCol = lineNo, colNo
 			} else if c == '(' {
 				if afterDataBlock {
 					currKey = currKey[:0]
+					gridStarted = true
 					break
 				} else {
 					inDataBlock = true
+					dataLine, dataCol = lineNo, colNo
 				}
 			}
 		}
 	}
 
-	type axis int
-
-	const (
-		X axis = iota
-		Y
-		Z
-	)
-
-	var (
-		readingAxis = X
-
-		currX, currY, currZ = 0, 0, 0
-		currNum             = 0
-		baseX               = 0
-
-		inCoordBlock = true
-		inMapString  = false
+	// The header takes the whole file.
+	if inHeader {
+		dmmData.Header = string(currHeader)
+	}
 
-		finishLine = func() error {
-			if len(currKey) != 0 {
-				_, err := lineErr("extra characters at EOL [%s]", string(currKey))
-				return err
+	// The file has ended before the grid, so the last key could be incomplete.
+	if !gridStarted {
+		if inDataBlock {
+			report(dataLine, dataCol, SeverityError, "truncated data block of the key [%s]", string(currKey))
+			if inVarEditBlock && len(currVar) > 0 {
+				flushCurrVariable()
 			}
-			if currX != baseX {
-				currY++
-				dmmData.MaxX = max(dmmData.MaxX, currX-1)
-				currX = baseX
+			if len(currPath) == 0 && len(currDatum) > 0 {
+				flushCurrPath()
 			}
-			return nil
+			flushCurrPrefab()
+			flushCurrKey()
+		} else if inKeyBlock {
+			report(keyLine, keyCol, SeverityError, "truncated key [%s]", string(currKey))
+		} else if !afterDataBlock {
+			report(keyLine, keyCol, SeverityError, "failed to start a data block for the key [%s]", string(currKey))
 		}
-	)
+		currKey = currKey[:0]
+	}
 
-	for {
-		if c, _, err := r.ReadRune(); err != nil {
-			if err == io.EOF {
-				break
-			} else {
-				return nil, err
-			}
-		} else {
-			if inCoordBlock {
-				if c == ',' {
-					if readingAxis == X {
-						currX = currNum
-						currNum = 0
-						baseX = currX
-						readingAxis = Y
-					} else if readingAxis == Y {
-						currY = currNum
-						currNum = 0
-						readingAxis = Z
-					} else {
-						return lineErr("incorrect number of axis [%d]", readingAxis)
-					}
-				} else if c == ')' {
-					if readingAxis != Z {
-						return lineErr("incorrect reading axis [%d] (expected %d)", readingAxis, Z)
-					}
-					currZ = currNum
-					currNum = 0
-					dmmData.MaxZ = max(dmmData.MaxZ, currZ)
-					inCoordBlock = false
-					readingAxis = X
-				} else {
-					x, err := strconv.ParseInt(string(c), 10, 16)
-					if err != nil {
-						return lineErr("%w", err)
-					}
-					currNum = 10*currNum + int(x)
-				}
-			} else if inMapString {
-				if c == '"' {
-					if err := finishLine(); err != nil {
-						return nil, err
-					}
-					inMapString = false
-					dmmData.MaxY = max(dmmData.MaxY, currY-1)
-				} else if c == '\r' {
-					dmmData.LineBreak = "\r\n" // Windows line break for sure.
-				} else if c == '\n' {
-					if err := finishLine(); err != nil {
-						return nil, err
-					}
-					lineNo++
-				} else {
-					currKey = append(currKey, c)
-					if len(currKey) == dmmData.KeyLength {
-						dmmData.Grid[util.Point{X: currX, Y: currY, Z: currZ}] = Key(currKey)
-						currKey = currKey[:0]
-						currX++
-					}
-				}
-			} else if c == '(' {
-				inCoordBlock = true
-			} else if c == '"' {
-				inMapString = true
-			} else if c == '\n' {
-				lineNo++
-			}
-		}
+	if !gridStarted {
+		return &dmmData, diagnostics, nil
 	}
 
-	// Make Y axis to go from bottom to top
-	reversedGrid := make(DataGrid, len(dmmData.Grid))
-	for z := 1; z <= dmmData.MaxZ; z++ {
-		for y := 1; y <= dmmData.MaxY; y++ {
-			for x := 1; x <= dmmData.MaxX; x++ {
-				reversedGrid[util.Point{
-					X: x,
-					Y: dmmData.MaxY + 1 - y,
-					Z: z,
-				}] = dmmData.Grid[util.Point{
-					X: x,
-					Y: y,
-					Z: z,
-				}]
-			}
-		}
+	// The rest of the file is the grid. It's read at once, so it could be parsed concurrently.
+	grid, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
 	}
-	dmmData.Grid = reversedGrid
 
-	return &dmmData, nil
+	diagnostics = append(diagnostics, parseGrid(&dmmData, grid, gridPos{
+		line:    lineNo,
+		col:     colNo,
+		endLine: endLine,
+	})...)
+
+	return &dmmData, diagnostics, nil
 }
@@ -0,0 +1,462 @@
+package dmmdata
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	"sdmm/util"
+)
+
+// The grid is parsed in three steps:
+//  1. Blocks of the grid are found sequentially. It requires only to read their coordinates and to look for quotes.
+//  2. Map strings of the blocks are parsed concurrently, with a goroutine for every z-level.
+//  3. Keys are put into the dense grid concurrently, again with a goroutine for every z-level.
+// Map strings take almost the whole file, so most of the work is done concurrently.
+
+// gridPos is a position in the file where the grid starts. The opening bracket of the first block is already read.
+type gridPos struct {
+	line, col int
+	// Line where the dictionary has ended.
+	endLine int
+}
+
+// gridBlock is a block of the grid found in the file.
+type gridBlock struct {
+	layout GridBlock
+	// Position of the opening quote of the map string.
+	line, col int
+	content   []byte
+	truncated bool
+
+	// Results of the map string parsing.
+	// Keys are stored as indexes: non-negative for keys of the dictionary, negative for undefined keys of the block.
+	keys          []int32
+	undefinedKeys []Key
+	rowWidths     []int
+	diagnostics   []gridDiagnostic
+}
+
+type gridDiagnostic struct {
+	Diagnostic
+	// Undefined keys are reported only once for the whole grid, since they are usually used many times.
+	undefinedKey Key
+}
+
+func parseGrid(dmmData *DmmData, grid []byte, pos gridPos) []Diagnostic {
+	blocks, diagnostics := splitGrid(dmmData, grid, pos)
+
+	// Keys of the dictionary are reused for the grid, so every tile doesn't allocate its own key.
+	keys := make([]Key, 0, len(dmmData.Dictionary))
+	keysIdx := make(map[string]int32, len(dmmData.Dictionary))
+	for key := range dmmData.Dictionary {
+		keysIdx[string(key)] = int32(len(keys))
+		keys = append(keys, key)
+	}
+
+	blocksByZ := groupBlocksByZ(blocks)
+
+	var wg sync.WaitGroup
+	for _, zBlocks := range blocksByZ {
+		wg.Add(1)
+		go func(zBlocks []*gridBlock) {
+			defer wg.Done()
+			for _, block := range zBlocks {
+				block.parse(dmmData.KeyLength, keysIdx)
+			}
+		}(zBlocks)
+	}
+	wg.Wait()
+
+	for _, block := range blocks {
+		// The content isn't needed anymore.
+		block.content = nil
+		for _, width := range block.rowWidths {
+			dmmData.MaxX = max(dmmData.MaxX, block.layout.Coord.X+width-1)
+		}
+		dmmData.MaxY = max(dmmData.MaxY, block.layout.Coord.Y+block.layout.Height-1)
+		diagnostics = append(diagnostics, block.diagnostics...)
+		if !block.truncated {
+			dmmData.Blocks = append(dmmData.Blocks, block.layout)
+		}
+	}
+
+	dmmData.Grid = NewDataGrid(dmmData.MaxX, dmmData.MaxY, dmmData.MaxZ)
+
+	for _, zBlocks := range blocksByZ {
+		wg.Add(1)
+		go func(zBlocks []*gridBlock) {
+			defer wg.Done()
+			for _, block := range zBlocks {
+				block.fill(dmmData.Grid, dmmData.MaxY, keys)
+			}
+		}(zBlocks)
+	}
+	wg.Wait()
+
+	return sortGridDiagnostics(diagnostics)
+}
+
+// Finds blocks of the grid by their coordinates and quotes of their map strings.
+// Blocks with broken coordinates are reported and skipped.
+func splitGrid(dmmData *DmmData, grid []byte, pos gridPos) (blocks []*gridBlock, diagnostics []gridDiagnostic) {
+	type axis int
+
+	const (
+		X axis = iota
+		Y
+		Z
+	)
+
+	var (
+		line, col = pos.line, pos.col
+		endLine   = pos.endLine
+		prevCR    bool
+
+		readingAxis = X
+
+		currX, currY, currZ = 0, 0, 0
+		currNum             = 0
+
+		// The opening bracket of the first block is already read.
+		inCoordBlock = true
+		// Coordinates are broken, so they are skipped along with the map string of the block.
+		brokenCoordBlock bool
+		skipMapString    bool
+
+		currBlock           GridBlock
+		blockLine, blockCol = line, col
+
+		report = func(line, col int, msg string, args ...any) {
+			diagnostics = append(diagnostics, gridDiagnostic{Diagnostic: Diagnostic{
+				Line:     line,
+				Column:   col,
+				Severity: SeverityError,
+				Msg:      fmt.Sprintf(msg, args...),
+			}})
+		}
+		startBlock = func() {
+			currBlock = GridBlock{
+				EmptyLinesBefore: max(0, line-endLine-1),
+				Inline:           true,
+			}
+			blockLine, blockCol = line, col
+		}
+	)
+
+	startBlock()
+
+	for i := 0; i < len(grid); {
+		c := grid[i]
+
+		// Map strings are skipped at once, since they are parsed later.
+		if !inCoordBlock && c == '"' {
+			col++
+			prevCR = false
+
+			block := &gridBlock{line: line, col: col}
+
+			content := grid[i+1:]
+			if end := bytes.IndexByte(content, '"'); end != -1 {
+				content = content[:end]
+			} else {
+				block.truncated = true
+				report(line, col, "truncated map string")
+			}
+
+			if bytes.IndexByte(content, '\r') != -1 {
+				dmmData.LineBreak = "\r\n" // Windows line break for sure.
+			}
+
+			line, col, prevCR = advancePos(content, line, col, prevCR)
+			i += 1 + len(content) + 1
+
+			if !block.truncated {
+				col++ // closing quote
+				prevCR = false
+			}
+
+			if !skipMapString {
+				block.layout = currBlock
+				block.content = content
+				blocks = append(blocks, block)
+				if !block.truncated {
+					endLine = line
+				}
+			}
+			continue
+		}
+
+		size := 1
+		if c >= utf8.RuneSelf {
+			_, size = utf8.DecodeRune(grid[i:])
+		}
+
+		switch {
+		case c == '\n' && prevCR:
+		case c == '\n' || c == '\r':
+			line++
+			col = 0
+		default:
+			col++
+		}
+		prevCR = c == '\r'
+
+		if inCoordBlock {
+			if c == ')' {
+				if !brokenCoordBlock && readingAxis != Z {
+					report(line, col, "incorrect reading axis [%d] (expected %d)", readingAxis, Z)
+					brokenCoordBlock = true
+				}
+				if brokenCoordBlock {
+					skipMapString = true
+				} else {
+					currZ = currNum
+					dmmData.MaxZ = max(dmmData.MaxZ, currZ)
+					currBlock.Coord = util.Point{X: currX, Y: currY, Z: currZ}
+				}
+				currNum = 0
+				inCoordBlock = false
+				brokenCoordBlock = false
+				readingAxis = X
+			} else if brokenCoordBlock {
+				// Skip the rest of the broken coordinates.
+			} else if c == ',' {
+				if readingAxis == X {
+					currX = currNum
+					currNum = 0
+					readingAxis = Y
+				} else if readingAxis == Y {
+					currY = currNum
+					currNum = 0
+					readingAxis = Z
+				} else {
+					report(line, col, "incorrect number of axis [%d]", readingAxis)
+					brokenCoordBlock = true
+				}
+			} else if c >= '0' && c <= '9' {
+				currNum = 10*currNum + int(c-'0')
+			} else {
+				_, err := strconv.ParseInt(string(grid[i:i+size]), 10, 16)
+				report(line, col, "%v", err)
+				brokenCoordBlock = true
+			}
+		} else if c == '(' {
+			inCoordBlock = true
+			skipMapString = false
+			startBlock()
+		}
+
+		i += size
+	}
+
+	if inCoordBlock {
+		report(blockLine, blockCol, "truncated coordinates block")
+	}
+
+	return blocks, diagnostics
+}
+
+// Returns the position after the provided content.
+func advancePos(content []byte, line, col int, prevCR bool) (int, int, bool) {
+	if len(content) == 0 {
+		return line, col, prevCR
+	}
+
+	// Fast path for the most common case without CR line breaks.
+	if bytes.IndexByte(content, '\r') == -1 {
+		lastLineBreak := bytes.LastIndexByte(content, '\n')
+		if lastLineBreak == -1 {
+			return line, col + utf8.RuneCount(content), false
+		}
+		lines := bytes.Count(content, []byte{'\n'})
+		if prevCR && content[0] == '\n' {
+			lines--
+		}
+		return line + lines, utf8.RuneCount(content[lastLineBreak+1:]), false
+	}
+
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case c == '\n' && prevCR:
+		case c == '\n' || c == '\r':
+			line++
+			col = 0
+		case c&0xC0 != 0x80: // Continuation bytes of UTF-8 runes are not counted.
+			col++
+		}
+		prevCR = c == '\r'
+	}
+	return line, col, prevCR
+}
+
+// Blocks of every z-level keep the order they have in the file, so later blocks overwrite earlier ones.
+func groupBlocksByZ(blocks []*gridBlock) [][]*gridBlock {
+	indexByZ := make(map[int]int)
+	var blocksByZ [][]*gridBlock
+	for _, block := range blocks {
+		idx, ok := indexByZ[block.layout.Coord.Z]
+		if !ok {
+			idx = len(blocksByZ)
+			indexByZ[block.layout.Coord.Z] = idx
+			blocksByZ = append(blocksByZ, nil)
+		}
+		blocksByZ[idx] = append(blocksByZ[idx], block)
+	}
+	return blocksByZ
+}
+
+// Parses keys of the map string. Keys are looked up as bytes, so known keys don't allocate anything.
+func (b *gridBlock) parse(keyLength int, keysIdx map[string]int32) {
+	var (
+		line, col = b.line, b.col
+		prevCR    bool
+
+		keyStart        = -1
+		keyRunes        = 0
+		keyLine, keyCol int
+
+		rowStart         int
+		undefinedKeysIdx map[string]int32
+
+		// The first line and empty lines at the end of the block are a part of its syntax, so they are not empty rows.
+		firstLine  = true
+		emptyLines int
+	)
+
+	if keyLength > 0 {
+		b.keys = make([]int32, 0, len(b.content)/keyLength)
+	}
+
+	finishRow := func(end int) {
+		if keyStart != -1 {
+			b.report(keyLine, keyCol, SeverityError, "", "extra characters at EOL [%s]", b.content[keyStart:end])
+			keyStart = -1
+			keyRunes = 0
+		}
+		if width := len(b.keys) - rowStart; width != 0 {
+			for ; emptyLines > 0; emptyLines-- {
+				b.layout.EmptyRows = append(b.layout.EmptyRows, b.layout.Height)
+			}
+			b.rowWidths = append(b.rowWidths, width)
+			b.layout.Width = max(b.layout.Width, width)
+			b.layout.Height++
+			rowStart = len(b.keys)
+		}
+	}
+
+	content := b.content
+	for i := 0; i < len(content); {
+		c := content[i]
+
+		if c == '\n' || c == '\r' {
+			if c == '\r' || !prevCR {
+				if !firstLine && keyStart == -1 && len(b.keys) == rowStart {
+					emptyLines++
+				}
+				firstLine = false
+				line++
+				col = 0
+			}
+			finishRow(i)
+			prevCR = c == '\r'
+			b.layout.Inline = false
+			i++
+			continue
+		}
+
+		prevCR = false
+		col++
+
+		if keyStart == -1 {
+			keyStart = i
+			keyLine, keyCol = line, col
+		}
+
+		if c < utf8.RuneSelf {
+			i++
+		} else {
+			_, size := utf8.DecodeRune(content[i:])
+			i += size
+		}
+
+		if keyRunes++; keyRunes == keyLength {
+			rawKey := content[keyStart:i]
+			idx, ok := keysIdx[string(rawKey)]
+			if !ok {
+				if idx, ok = undefinedKeysIdx[string(rawKey)]; !ok {
+					if undefinedKeysIdx == nil {
+						undefinedKeysIdx = make(map[string]int32)
+					}
+					key := Key(rawKey)
+					b.undefinedKeys = append(b.undefinedKeys, key)
+					idx = -int32(len(b.undefinedKeys))
+					undefinedKeysIdx[string(key)] = idx
+					b.report(keyLine, keyCol, SeverityWarning, key, "undefined key [%s]", key)
+				}
+			}
+			b.keys = append(b.keys, idx)
+			keyStart = -1
+			keyRunes = 0
+		}
+	}
+
+	finishRow(len(content))
+}
+
+func (b *gridBlock) report(line, col int, severity Severity, undefinedKey Key, msg string, args ...any) {
+	b.diagnostics = append(b.diagnostics, gridDiagnostic{
+		Diagnostic: Diagnostic{
+			Line:     line,
+			Column:   col,
+			Severity: severity,
+			Msg:      fmt.Sprintf(msg, args...),
+		},
+		undefinedKey: undefinedKey,
+	})
+}
+
+// Puts keys of the block into the grid. Rows of the block go from top to bottom, while the grid Y axis is reversed.
+func (b *gridBlock) fill(grid DataGrid, maxY int, keys []Key) {
+	blockKeys := b.keys
+	for row, width := range b.rowWidths {
+		point := util.Point{Y: maxY + 1 - (b.layout.Coord.Y + row), Z: b.layout.Coord.Z}
+		for col, idx := range blockKeys[:width] {
+			point.X = b.layout.Coord.X + col
+			if idx >= 0 {
+				grid.Set(point, keys[idx])
+			} else {
+				grid.Set(point, b.undefinedKeys[-idx-1])
+			}
+		}
+		blockKeys = blockKeys[width:]
+	}
+}
+
+// Diagnostics are sorted by their position in the file, since blocks are parsed concurrently.
+// Undefined keys are left only for their first occurrence.
+func sortGridDiagnostics(diagnostics []gridDiagnostic) []Diagnostic {
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].Line != diagnostics[j].Line {
+			return diagnostics[i].Line < diagnostics[j].Line
+		}
+		return diagnostics[i].Column < diagnostics[j].Column
+	})
+
+	result := make([]Diagnostic, 0, len(diagnostics))
+	reportedKeys := make(map[Key]bool)
+	for _, diagnostic := range diagnostics {
+		if key := diagnostic.undefinedKey; key != "" {
+			if reportedKeys[key] {
+				continue
+			}
+			reportedKeys[key] = true
+		}
+		result = append(result, diagnostic.Diagnostic)
+	}
+	return result
+}
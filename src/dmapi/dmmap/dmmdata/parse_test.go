@@ -1,12 +1,18 @@
 package dmmdata
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"strings"
 	"testing"
 	"testing/iotest"
 
+	"sdmm/dmapi/dmmap/dmmdata/dmmprefab"
+	"sdmm/dmapi/dmvars"
+	"sdmm/util"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -61,11 +67,12 @@ func validateBase(t *testing.T, input string, isDmm bool, onlyLF bool) {
 		expectedPath := "/obj/foo" + string(key[0]-'a'+'1')
 		assert.Equal(expectedPath, prefabs[0].Path(), key)
 	}
-	for point, key := range dmm.Grid {
-		assert.Equal(1, point.Z, key)
-
-		expectedKey := string(rune((point.Y-point.X+6)%6 + 'a'))
-		assert.Equal(Key(expectedKey), key, point)
+	for y := 1; y <= dmm.MaxY; y++ {
+		for x := 1; x <= dmm.MaxX; x++ {
+			point := util.Point{X: x, Y: y, Z: 1}
+			expectedKey := string(rune((point.Y-point.X+6)%6 + 'a'))
+			assert.Equal(Key(expectedKey), dmm.Grid.Get(point), point)
+		}
 	}
 }
 
@@ -258,7 +265,7 @@ func TestFailure(t *testing.T) {
 		{input: "(1,1,1)={\"ab\n\"}", err: "extra characters at EOL [ab]"},
 		{input: `(1,1,1,1)`, err: "incorrect number of axis"},
 		{input: `(1,1)`, err: "incorrect reading axis [1] (expected 2)"},
-		{input: "\"a\"=(/1)\n(\"b\"=/2)", err: "at line 2: strconv.ParseInt"},
+		{input: "\"a\"=(/1)\n(\"b\"=/2)", err: "at line 2, column 2: error: strconv.ParseInt"},
 	}
 
 	for _, tc := range tests {
@@ -271,6 +278,133 @@ func TestFailure(t *testing.T) {
 	}
 }
 
+func TestDiagnostics(t *testing.T) {
+	input := "\"a\" = (/turf,/area)\r\n" +
+		"\"bb\" = (/turf)\r\n" +
+		"\"a\" = (/obj)\r\n" +
+		"\r\n" +
+		"(1,1,1) = {\"\r\n" +
+		"aca\r\n" +
+		"ac\r\n" +
+		"aa\r\n" +
+		"\"}\r\n" +
+		"(1,1) = {\"\r\n" +
+		"aa\r\n" +
+		"\"}\r\n" +
+		"(3,1,1) = {\"\r\n" +
+		"c"
+
+	_, parseErr := parse(&testReader{strings.NewReader(input)})
+	require.IsType(t, &ParseError{}, parseErr)
+
+	dmm, diagnostics, err := parseTolerant(&testReader{strings.NewReader(input)})
+	require.NoError(t, err)
+	assert.Equal(t, parseErr.(*ParseError).Diagnostics, diagnostics)
+	assert.Equal(t, []Diagnostic{
+		{Line: 2, Column: 1, Severity: SeverityError, Msg: "inconsistent key length: 1 vs 2"},
+		{Line: 3, Column: 1, Severity: SeverityWarning, Msg: "duplicate key definition [a]"},
+		{Line: 6, Column: 2, Severity: SeverityWarning, Msg: "undefined key [c]"},
+		{Line: 10, Column: 5, Severity: SeverityError, Msg: "incorrect reading axis [1] (expected 2)"},
+		{Line: 13, Column: 12, Severity: SeverityError, Msg: "truncated map string"},
+	}, diagnostics)
+
+	assert.Equal(t, "\r\n", dmm.LineBreak)
+	assert.Equal(t, 3, dmm.MaxX)
+	assert.Equal(t, 3, dmm.MaxY)
+	assert.Equal(t, 1, dmm.MaxZ)
+
+	// Keys with inconsistent length are dropped, while the last definition of the duplicated key is kept.
+	assert.NotContains(t, dmm.Dictionary, Key("bb"))
+	require.Len(t, dmm.Dictionary["a"], 1)
+	assert.Equal(t, "/obj", dmm.Dictionary["a"][0].Path())
+	assert.Equal(t, Key("c"), dmm.Grid.Get(util.Point{X: 2, Y: 3, Z: 1}))
+	// Keys of the truncated map string are kept too.
+	assert.Equal(t, Key("c"), dmm.Grid.Get(util.Point{X: 3, Y: 3, Z: 1}))
+}
+
+func TestDiagnostics_Truncated(t *testing.T) {
+	tests := []struct {
+		input      string
+		diagnostic Diagnostic
+	}{
+		{input: "\"a\" = (/turf{name = \"x", diagnostic: Diagnostic{Line: 1, Column: 7, Severity: SeverityError, Msg: "truncated data block of the key [a]"}},
+		{input: "\"a\" = (/turf)\n\"b", diagnostic: Diagnostic{Line: 2, Column: 1, Severity: SeverityError, Msg: "truncated key [b]"}},
+		{input: "\"a\" = (/turf)\n\n(1,1", diagnostic: Diagnostic{Line: 3, Column: 1, Severity: SeverityError, Msg: "truncated coordinates block"}},
+		{input: "\"a\" = (/turf)\n(1,1,1) = {\"\nab", diagnostic: Diagnostic{Line: 2, Column: 12, Severity: SeverityError, Msg: "truncated map string"}},
+	}
+
+	for _, tc := range tests {
+		dmm, diagnostics, err := parseTolerant(&testReader{strings.NewReader(tc.input)})
+		require.NoError(t, err, tc.input)
+		require.NotNil(t, dmm, tc.input)
+		assert.Contains(t, diagnostics, tc.diagnostic, tc.input)
+		assert.Contains(t, dmm.Dictionary, Key("a"), tc.input)
+	}
+}
+
+// Undefined and duplicate keys are only warnings, so such maps are still loaded.
+func TestParseWarnings(t *testing.T) {
+	input := `"a" = (/turf)
+"b" = (/obj,/turf)
+"b" = (/turf)
+
+(1,1,1) = {"
+abx
+"}
+`
+
+	dmm, err := parse(&testReader{strings.NewReader(input)})
+	require.NoError(t, err)
+	assert.Equal(t, 3, dmm.MaxX)
+	assert.Equal(t, Key("x"), dmm.Grid.Get(util.Point{X: 3, Y: 1, Z: 1}))
+	require.Len(t, dmm.Dictionary["b"], 1)
+	assert.Equal(t, "/turf", dmm.Dictionary["b"][0].Path())
+
+	_, diagnostics, err := parseTolerant(&testReader{strings.NewReader(input)})
+	require.NoError(t, err)
+	assert.Equal(t, []Diagnostic{
+		{Line: 3, Column: 1, Severity: SeverityWarning, Msg: "duplicate key definition [b]"},
+		{Line: 6, Column: 3, Severity: SeverityWarning, Msg: "undefined key [x]"},
+	}, diagnostics)
+}
+
+// Z-levels are parsed concurrently, but the result is the same as if they were parsed in order.
+func TestParseLevels(t *testing.T) {
+	input := `"a" = (/turf)
+"b" = (/obj,/turf)
+
+(1,1,1) = {"
+ab
+"}
+(1,1,2) = {"
+bx
+"}
+(1,1,3) = {"
+xa
+"}
+(2,1,3) = {"
+b
+"}
+`
+
+	dmm, diagnostics, err := parseTolerant(&testReader{strings.NewReader(input)})
+	require.NoError(t, err)
+	assert.Equal(t, []Diagnostic{{Line: 8, Column: 2, Severity: SeverityWarning, Msg: "undefined key [x]"}}, diagnostics)
+
+	assert.Equal(t, 2, dmm.MaxX)
+	assert.Equal(t, 1, dmm.MaxY)
+	assert.Equal(t, 3, dmm.MaxZ)
+
+	expected := []Key{"a", "b", "b", "x", "x", "b"}
+	var actual []Key
+	for z := 1; z <= dmm.MaxZ; z++ {
+		for x := 1; x <= dmm.MaxX; x++ {
+			actual = append(actual, dmm.Grid.Get(util.Point{X: x, Y: 1, Z: z}))
+		}
+	}
+	assert.Equal(t, expected, actual)
+}
+
 func TestEmpty(t *testing.T) {
 	assert := assert.New(t)
 	dmm, err := parse(&testReader{strings.NewReader("")})
@@ -302,3 +436,85 @@ func TestReadFailure(t *testing.T) {
 		}
 	}
 }
+
+// Makes a map of the provided size in the same way as the editor saves it.
+func makeBenchmarkMap(b *testing.B, maxX, maxY, maxZ int, isTgm bool) []byte {
+	b.Helper()
+
+	const (
+		base52    = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+		keysCount = 5000
+	)
+
+	data := DmmData{
+		IsTgm:      isTgm,
+		LineBreak:  "\n",
+		KeyLength:  3,
+		MaxX:       maxX,
+		MaxY:       maxY,
+		MaxZ:       maxZ,
+		Dictionary: make(DataDictionary, keysCount),
+		Grid:       NewDataGrid(maxX, maxY, maxZ),
+	}
+
+	keys := make([]Key, 0, keysCount)
+	for num := 0; num < keysCount; num++ {
+		key := Key([]byte{base52[num/52/52%52], base52[num/52%52], base52[num%52]})
+		vars := &dmvars.MutableVariables{}
+		vars.Put("name", fmt.Sprintf(`"object %d"`, num))
+		data.Dictionary[key] = Prefabs{
+			dmmprefab.New(dmmprefab.IdNone, "/obj/item", vars.ToImmutable()),
+			dmmprefab.New(dmmprefab.IdNone, "/turf/floor", &dmvars.Variables{}),
+			dmmprefab.New(dmmprefab.IdNone, "/area/station", &dmvars.Variables{}),
+		}
+		keys = append(keys, key)
+	}
+
+	for z := 1; z <= maxZ; z++ {
+		for y := 1; y <= maxY; y++ {
+			for x := 1; x <= maxX; x++ {
+				data.Grid.Set(util.Point{X: x, Y: y, Z: z}, keys[(x*31+y*17+z*7)%keysCount])
+			}
+		}
+	}
+
+	buf := bytes.Buffer{}
+	w := bufio.NewWriter(&buf)
+	if isTgm {
+		data.writeTGM(w, false)
+	} else {
+		data.writeDM(w, false)
+	}
+	require.NoError(b, w.Flush())
+
+	return buf.Bytes()
+}
+
+func benchmarkParse(b *testing.B, maxX, maxY, maxZ int, isTgm bool) {
+	content := makeBenchmarkMap(b, maxX, maxY, maxZ, isTgm)
+
+	b.SetBytes(int64(len(content)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := parse(&testReader{bytes.NewReader(content)}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	b.Run("DM/255x255x1", func(b *testing.B) {
+		benchmarkParse(b, 255, 255, 1, false)
+	})
+	b.Run("DM/255x255x12", func(b *testing.B) {
+		benchmarkParse(b, 255, 255, 12, false)
+	})
+	b.Run("TGM/255x255x1", func(b *testing.B) {
+		benchmarkParse(b, 255, 255, 1, true)
+	})
+	b.Run("TGM/255x255x12", func(b *testing.B) {
+		benchmarkParse(b, 255, 255, 12, true)
+	})
+}
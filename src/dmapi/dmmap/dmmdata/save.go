@@ -0,0 +1,131 @@
+package dmmdata
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"sdmm/util"
+)
+
+// Permissions of a newly created map file. Existing files keep their own permissions.
+const newFilePerm os.FileMode = 0644
+
+// Writes the file atomically: the content is written to a temporary file in the same directory,
+// which is renamed into place only when everything is written successfully.
+// So a failure in the middle of writing never leaves the target file corrupted.
+func writeFileAtomic(path string, write func(w *bufio.Writer)) (err error) {
+	perm := newFilePerm
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("unable to create a temporary file: %w", err)
+	}
+
+	tmpPath := f.Name()
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(tmpPath)
+		}
+	}()
+
+	w := bufio.NewWriter(f)
+	write(w)
+
+	// Write errors are sticky for the bufio.Writer, so they will be returned on flush.
+	if err = w.Flush(); err != nil {
+		return fmt.Errorf("unable to write: %w", err)
+	}
+	if err = f.Sync(); err != nil {
+		return fmt.Errorf("unable to sync: %w", err)
+	}
+	if err = f.Chmod(perm); err != nil {
+		return fmt.Errorf("unable to set permissions: %w", err)
+	}
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("unable to close: %w", err)
+	}
+	if err = os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("unable to replace the file: %w", err)
+	}
+
+	return nil
+}
+
+// Returns grid blocks to write, only if the layout is preserved and the blocks cover the whole grid without overlaps.
+// Otherwise, the grid was changed since parsing (like resized), so the default layout should be used.
+func (d DmmData) layoutBlocks(preserveLayout bool) []GridBlock {
+	if !preserveLayout || len(d.Blocks) == 0 {
+		return nil
+	}
+
+	covered := make([]bool, d.MaxX*d.MaxY*d.MaxZ)
+	coveredCount := 0
+
+	for _, block := range d.Blocks {
+		if block.Inline && block.Height > 1 {
+			return nil
+		}
+		for row := 0; row < block.Height; row++ {
+			for col := 0; col < block.Width; col++ {
+				x, y, z := block.Coord.X+col, block.Coord.Y+row, block.Coord.Z
+				if x < 1 || x > d.MaxX || y < 1 || y > d.MaxY || z < 1 || z > d.MaxZ {
+					return nil
+				}
+				idx := d.MaxX*d.MaxY*(z-1) + d.MaxX*(y-1) + (x - 1)
+				if covered[idx] {
+					return nil
+				}
+				covered[idx] = true
+				coveredCount++
+			}
+		}
+	}
+
+	if coveredCount != len(covered) {
+		return nil
+	}
+	return d.Blocks
+}
+
+// Writes the grid by the provided blocks, so the layout of the parsed file is reproduced.
+func (d DmmData) writeBlocks(w *bufio.Writer, blocks []GridBlock) {
+	write := func(str string) {
+		_, _ = w.WriteString(str)
+	}
+
+	for _, block := range blocks {
+		for i := 0; i < block.EmptyLinesBefore; i++ {
+			write(d.LineBreak)
+		}
+
+		write(fmt.Sprintf("(%d,%d,%d) = {\"", block.Coord.X, block.Coord.Y, block.Coord.Z))
+		if !block.Inline {
+			write(d.LineBreak)
+		}
+
+		emptyRows := block.EmptyRows
+		for row := 0; row < block.Height; row++ {
+			for ; len(emptyRows) != 0 && emptyRows[0] == row; emptyRows = emptyRows[1:] {
+				write(d.LineBreak)
+			}
+
+			// Rows of the block go from top to bottom.
+			y := d.MaxY - (block.Coord.Y + row) + 1
+			for x := block.Coord.X; x < block.Coord.X+block.Width; x++ {
+				write(string(d.Grid.Get(util.Point{X: x, Y: y, Z: block.Coord.Z})))
+			}
+			if !block.Inline {
+				write(d.LineBreak)
+			}
+		}
+
+		write("\"}")
+		write(d.LineBreak)
+	}
+}
@@ -4,24 +4,30 @@ import (
 	"bufio"
 	"fmt"
 	"log"
-	"os"
 	"strings"
 
 	"sdmm/util"
 )
 
-// SaveDM writes DmmData in DM format to a file with the provided path.
-func (d DmmData) SaveDM(path string) {
+// SaveDM writes DmmData in DM format with the default layout to a file with the provided path.
+// The file is replaced only if the whole data is written successfully.
+func (d DmmData) SaveDM(path string) error {
+	return d.saveDM(path, false)
+}
+
+func (d DmmData) saveDM(path string, preserveLayout bool) error {
 	log.Println("[dmmdata] saving dmm data in [DM] format...")
 
-	f, err := os.Create(path)
-	if err != nil {
+	if err := writeFileAtomic(path, func(w *bufio.Writer) { d.writeDM(w, preserveLayout) }); err != nil {
 		log.Printf("[dmmdata] unable to save as [DM] [%s]: %v", d, err)
-		return
+		return fmt.Errorf("unable to save [%s]: %w", path, err)
 	}
-	defer f.Close()
 
-	w := bufio.NewWriter(f)
+	log.Printf("[dmmdata] [%s] saved in [DM] format to: %s", d, path)
+	return nil
+}
+
+func (d DmmData) writeDM(w *bufio.Writer, preserveLayout bool) {
 	write := func(str string) {
 		_, _ = w.WriteString(str)
 	}
@@ -35,6 +41,11 @@ func (d DmmData) SaveDM(path string) {
 
 	log.Println("[dmmdata] writing grid...")
 
+	if blocks := d.layoutBlocks(preserveLayout); blocks != nil {
+		d.writeBlocks(w, blocks)
+		return
+	}
+
 	for z := 1; z <= d.MaxZ; z++ {
 		write(d.LineBreak)
 		write(fmt.Sprintf("(1,1,%d) = {\"", z))
@@ -42,7 +53,7 @@ func (d DmmData) SaveDM(path string) {
 
 		for y := d.MaxY; y >= 1; y-- {
 			for x := 1; x <= d.MaxX; x++ {
-				write(string(d.Grid[util.Point{X: x, Y: y, Z: z}]))
+				write(string(d.Grid.Get(util.Point{X: x, Y: y, Z: z})))
 			}
 			write(d.LineBreak)
 		}
@@ -51,12 +62,6 @@ func (d DmmData) SaveDM(path string) {
 	}
 
 	write(d.LineBreak)
-
-	if err = w.Flush(); err != nil {
-		log.Printf("[dmmdata] unable to write to [%s]: %v", path, err)
-	}
-
-	log.Printf("[dmmdata] [%s] saved in [DM] format to: %s", d, path)
 }
 
 func toDMStr(key Key, prefabs Prefabs) string {
@@ -0,0 +1,180 @@
+package dmmdata
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sdmm/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const saveTestMap = `"a" = (/turf,/area)
+
+(1,1,1) = {"
+aa
+"}
+`
+
+func TestSave_ReplacesFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "map.dmm")
+	require.NoError(t, os.WriteFile(path, []byte(saveTestMap), 0600))
+
+	data, err := New(path)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte("outdated"), 0600))
+	require.NoError(t, data.Save())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, saveTestMap, string(content))
+
+	// Permissions of the replaced file are kept and no temporary files are left.
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestSave_Error(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "map.dmm")
+	require.NoError(t, os.WriteFile(path, []byte(saveTestMap), os.ModePerm))
+
+	data, err := New(path)
+	require.NoError(t, err)
+
+	assert.Error(t, data.SaveDM(filepath.Join(t.TempDir(), "missing", "map.dmm")))
+	assert.Error(t, data.SaveTGM(filepath.Join(t.TempDir(), "missing", "map.dmm")))
+}
+
+// Untouched maps are saved byte by byte as they were parsed, including the layout of their grid blocks.
+func TestSave_RoundTrip(t *testing.T) {
+	goldenPaths, err := filepath.Glob(filepath.Join("testdata", "roundtrip", "*.dmm"))
+	require.NoError(t, err)
+	require.NotEmpty(t, goldenPaths)
+
+	for _, goldenPath := range goldenPaths {
+		golden, err := os.ReadFile(goldenPath)
+		require.NoError(t, err)
+
+		t.Run(filepath.Base(goldenPath), func(t *testing.T) {
+			assert.Equal(t, string(golden), roundTrip(t, golden))
+		})
+		t.Run(filepath.Base(goldenPath)+"/crlf", func(t *testing.T) {
+			crlf := []byte(strings.ReplaceAll(string(golden), "\n", "\r\n"))
+			assert.Equal(t, string(crlf), roundTrip(t, crlf))
+		})
+	}
+}
+
+func TestSave_ChangedLayout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "map.dmm")
+	require.NoError(t, os.WriteFile(path, []byte(`//Saved by another tool
+"a" = (
+/turf,
+/area)
+
+(1,1,1) = {"
+aa
+"}
+`), 0600))
+
+	data, err := New(path)
+	require.NoError(t, err)
+	assert.True(t, data.IsTgm)
+	assert.Equal(t, "//Saved by another tool", data.Header)
+	assert.Equal(t, []GridBlock{{Coord: util.Point{X: 1, Y: 1, Z: 1}, Width: 2, Height: 1, EmptyLinesBefore: 1}}, data.Blocks)
+
+	// The map is resized, so its blocks don't match the grid anymore and the default layout is used.
+	data.MaxY = 2
+	data.Grid = NewDataGrid(2, 2, 1)
+	for y := 1; y <= 2; y++ {
+		for x := 1; x <= 2; x++ {
+			data.Grid.Set(util.Point{X: x, Y: y, Z: 1}, "a")
+		}
+	}
+	require.NoError(t, data.SaveV(true))
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `//Saved by another tool
+"a" = (
+/turf,
+/area)
+
+(1,1,1) = {"
+a
+a
+"}
+(2,1,1) = {"
+a
+a
+"}
+`, string(content))
+}
+
+func roundTrip(t *testing.T, content []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "map.dmm")
+	require.NoError(t, os.WriteFile(path, content, 0600))
+
+	data, err := New(path)
+	require.NoError(t, err)
+	require.NoError(t, data.SaveV(true))
+
+	saved, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(saved)
+}
+
+// Without the preserved layout, the default header and blocks of the format are written.
+func TestSave_DefaultLayout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "map.dmm")
+	require.NoError(t, os.WriteFile(path, []byte(`//Saved by another tool
+"a" = (
+/turf,
+/area)
+
+(1,1,1) = {"a"}
+(2,1,1) = {"a"}
+`), 0600))
+
+	data, err := New(path)
+	require.NoError(t, err)
+	require.NoError(t, data.Save())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, defaultTGMHeader+`
+"a" = (
+/turf,
+/area)
+
+(1,1,1) = {"
+a
+"}
+(2,1,1) = {"
+a
+"}
+`, string(content))
+
+	// The header is never written in the DM format, since it makes the map TGM.
+	require.NoError(t, data.SaveDM(path))
+	content, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `"a" = (/turf,/area)
+
+(1,1,1) = {"
+aa
+"}
+`, string(content))
+}
@@ -4,24 +4,33 @@ import (
 	"bufio"
 	"fmt"
 	"log"
-	"os"
 	"strings"
 
 	"sdmm/util"
 )
 
-// SaveTGM writes DmmData in TGM format to a file with the provided path.
-func (d DmmData) SaveTGM(path string) {
+// yeah, yeah, dmm2tgm.py, sure...
+const defaultTGMHeader = "//MAP CONVERTED BY dmm2tgm.py THIS HEADER COMMENT PREVENTS RECONVERSION, DO NOT REMOVE"
+
+// SaveTGM writes DmmData in TGM format with the default layout to a file with the provided path.
+// The file is replaced only if the whole data is written successfully.
+func (d DmmData) SaveTGM(path string) error {
+	return d.saveTGM(path, false)
+}
+
+func (d DmmData) saveTGM(path string, preserveLayout bool) error {
 	log.Println("[dmmdata] saving dmm data in [TGM] format...")
 
-	f, err := os.Create(path)
-	if err != nil {
+	if err := writeFileAtomic(path, func(w *bufio.Writer) { d.writeTGM(w, preserveLayout) }); err != nil {
 		log.Printf("[dmmdata] unable to save as [TGM] [%s]: %v", d, err)
-		return
+		return fmt.Errorf("unable to save [%s]: %w", path, err)
 	}
-	defer f.Close()
 
-	w := bufio.NewWriter(f)
+	log.Printf("[dmmdata] [%s] saved in [TGM] format to: %s", d, path)
+	return nil
+}
+
+func (d DmmData) writeTGM(w *bufio.Writer, preserveLayout bool) {
 	writeln := func(str ...string) {
 		for _, s := range str {
 			_, _ = w.WriteString(s)
@@ -30,8 +39,11 @@ func (d DmmData) SaveTGM(path string) {
 	}
 
 	// Write TGM header
-	// yeah, yeah, dmm2tgm.py, sure...
-	writeln("//MAP CONVERTED BY dmm2tgm.py THIS HEADER COMMENT PREVENTS RECONVERSION, DO NOT REMOVE")
+	if preserveLayout && d.Header != "" {
+		writeln(d.Header)
+	} else {
+		writeln(defaultTGMHeader)
+	}
 
 	log.Println("[dmmdata] writing prefabs...")
 
@@ -41,6 +53,11 @@ func (d DmmData) SaveTGM(path string) {
 
 	log.Println("[dmmdata] writing grid...")
 
+	if blocks := d.layoutBlocks(preserveLayout); blocks != nil {
+		d.writeBlocks(w, blocks)
+		return
+	}
+
 	for z := 1; z <= d.MaxZ; z++ {
 		writeln()
 
@@ -48,18 +65,12 @@ func (d DmmData) SaveTGM(path string) {
 			writeln(fmt.Sprintf("(%d,1,%d) = {\"", x, z))
 
 			for y := d.MaxY; y >= 1; y-- {
-				writeln(string(d.Grid[util.Point{X: x, Y: y, Z: z}]))
+				writeln(string(d.Grid.Get(util.Point{X: x, Y: y, Z: z})))
 			}
 
 			writeln("\"}")
 		}
 	}
-
-	if err = w.Flush(); err != nil {
-		log.Printf("[dmmdata] unable to write to [%s]: %v", path, err)
-	}
-
-	log.Printf("[dmmdata] [%s] saved in [TGM] format to: %s", d, path)
 }
 
 func toTGMStr(key Key, content Prefabs, lineBreak string) string {
@@ -1,47 +1,73 @@
 package dmminstance
 
 import (
+	"sync"
+	"sync/atomic"
+
 	"sdmm/dmapi/dmmap/dmmdata/dmmprefab"
 	"sdmm/util"
 )
 
+// Instances are created by the UI thread and by background jobs, so the id is allocated atomically.
 var id uint64
 
+// Journal records coords of modified instances.
+type Journal interface {
+	Touch(coord util.Point)
+}
+
 type Instance struct {
-	id     uint64
-	coord  util.Point
+	id    uint64
+	coord util.Point
+
+	// The prefab could be changed by the UI thread, while the instance is read by a background commit.
+	mu     sync.RWMutex
 	prefab *dmmprefab.Prefab
+
+	journal Journal
 }
 
 func (i *Instance) SetPrefab(prefab *dmmprefab.Prefab) {
+	i.mu.Lock()
 	i.prefab = prefab
+	i.mu.Unlock()
+	if i.journal != nil {
+		i.journal.Touch(i.coord)
+	}
+}
+
+// SetJournal sets the journal to record instance modifications.
+func (i *Instance) SetJournal(journal Journal) {
+	i.journal = journal
 }
 
-func (i Instance) Copy() Instance {
+// Copy returns a copy of the instance. The copy is detached from the journal of the original instance.
+func (i *Instance) Copy() Instance {
 	return Instance{
 		id:     i.id,
 		coord:  i.coord,
-		prefab: i.prefab,
+		prefab: i.Prefab(),
 	}
 }
 
-func (i Instance) Id() uint64 {
+func (i *Instance) Id() uint64 {
 	return i.id
 }
 
-func (i Instance) Coord() util.Point {
+func (i *Instance) Coord() util.Point {
 	return i.coord
 }
 
-func (i Instance) Prefab() *dmmprefab.Prefab {
+func (i *Instance) Prefab() *dmmprefab.Prefab {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
 	return i.prefab
 }
 
 func New(coord util.Point, prefab *dmmprefab.Prefab) *Instance {
-	id++
 	return &Instance{
-		id,
-		coord,
-		prefab,
+		id:     atomic.AddUint64(&id, 1),
+		coord:  coord,
+		prefab: prefab,
 	}
 }
@@ -0,0 +1,210 @@
+package dmmap
+
+import (
+	"log"
+	"sync"
+
+	"sdmm/dmapi/dm"
+	"sdmm/dmapi/dmmap/dmminstance"
+	"sdmm/util"
+)
+
+// Index maps prefab IDs, type paths and instance IDs of the map to coords of their tiles.
+//
+// The index is updated by the map journal: every touched tile is reindexed immediately,
+// so the cost of a lookup is proportional to the size of the result.
+// When the whole map is modified (like on the map resize), the index is rebuilt once on the next lookup.
+type Index struct {
+	mu sync.Mutex
+
+	dmm *Dmm
+
+	// True if the whole map should be reindexed on the next lookup.
+	stale bool
+
+	// Content of indexed tiles, so their entries can be removed when tiles are modified.
+	tiles map[util.Point][]indexEntry
+
+	byPrefabId   map[uint64]coordsCount
+	byPath       map[string]coordsCount
+	byInstanceId map[uint64]util.Point
+}
+
+type indexEntry struct {
+	instanceId uint64
+	prefabId   uint64
+	path       string
+}
+
+func newIndex(dmm *Dmm) *Index {
+	idx := &Index{dmm: dmm, stale: true} // The map isn't indexed yet.
+	dmm.journal.index = idx
+	idx.clear()
+	return idx
+}
+
+// CoordsByPrefabId returns coords of tiles with instances of the prefab with the provided ID.
+func (i *Index) CoordsByPrefabId(prefabId uint64) []util.Point {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.rebuildIfStale()
+	return collectCoords(i.byPrefabId[prefabId])
+}
+
+// CoordsByPath returns coords of tiles with instances of the provided type path.
+// If subtypes is true, tiles with instances of subtypes are returned as well.
+func (i *Index) CoordsByPath(path string, subtypes bool) []util.Point {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.rebuildIfStale()
+
+	if !subtypes {
+		return collectCoords(i.byPath[path])
+	}
+
+	// The map has much fewer types than tiles, so it's fine to go through all of them.
+	coords := make(coordsCount)
+	for instancePath, pathCoords := range i.byPath {
+		if dm.IsPath(instancePath, path) {
+			for coord, count := range pathCoords {
+				coords[coord] += count
+			}
+		}
+	}
+	return collectCoords(coords)
+}
+
+// InstanceCoord returns the coord of the instance with the provided ID.
+// The second value is false, if there is no such instance on the map.
+func (i *Index) InstanceCoord(instanceId uint64) (util.Point, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.rebuildIfStale()
+	coord, ok := i.byInstanceId[instanceId]
+	return coord, ok
+}
+
+// Areas returns coords of tiles for every area on the map by the area type path.
+func (i *Index) Areas() map[string][]util.Point {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.rebuildIfStale()
+
+	areas := make(map[string][]util.Point)
+	for path, coords := range i.byPath {
+		if dm.IsPath(path, "/area") {
+			areas[path] = collectCoords(coords)
+		}
+	}
+	return areas
+}
+
+// Reindexes the tile with the provided coord. Called by the map journal, when the tile is touched.
+// The map lock must not be held by the caller, since the tile is read with it.
+func (i *Index) touch(coord util.Point) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	// The whole map will be reindexed anyway.
+	if i.stale {
+		return
+	}
+
+	i.remove(coord)
+	// Modified tiles could be removed with the map resize.
+	if tile, ok := i.dmm.GetTileV(coord); ok {
+		i.add(coord, tile.Instances())
+	}
+}
+
+// Marks the whole map to be reindexed on the next lookup. Called by the map journal.
+func (i *Index) touchAll() {
+	i.mu.Lock()
+	i.stale = true
+	i.mu.Unlock()
+}
+
+func (i *Index) rebuildIfStale() {
+	if !i.stale {
+		return
+	}
+
+	i.stale = false
+	i.clear()
+	for _, tile := range i.dmm.AllTiles() {
+		i.add(tile.Coord, tile.Instances())
+	}
+	log.Printf("[dmmap] index rebuilt [%s]: [%d] tiles", i.dmm.Name, len(i.tiles))
+}
+
+func (i *Index) clear() {
+	i.tiles = make(map[util.Point][]indexEntry)
+	i.byPrefabId = make(map[uint64]coordsCount)
+	i.byPath = make(map[string]coordsCount)
+	i.byInstanceId = make(map[uint64]util.Point)
+}
+
+func (i *Index) add(coord util.Point, instances []*dmminstance.Instance) {
+	entries := make([]indexEntry, 0, len(instances))
+	for _, instance := range instances {
+		prefab := instance.Prefab()
+		entry := indexEntry{instanceId: instance.Id(), prefabId: prefab.Id(), path: prefab.Path()}
+		entries = append(entries, entry)
+
+		if _, ok := i.byPrefabId[entry.prefabId]; !ok {
+			i.byPrefabId[entry.prefabId] = make(coordsCount)
+		}
+		i.byPrefabId[entry.prefabId].add(coord)
+
+		if _, ok := i.byPath[entry.path]; !ok {
+			i.byPath[entry.path] = make(coordsCount)
+		}
+		i.byPath[entry.path].add(coord)
+
+		i.byInstanceId[entry.instanceId] = coord
+	}
+	i.tiles[coord] = entries
+}
+
+func (i *Index) remove(coord util.Point) {
+	for _, entry := range i.tiles[coord] {
+		if i.byPrefabId[entry.prefabId].remove(coord) {
+			delete(i.byPrefabId, entry.prefabId)
+		}
+		if i.byPath[entry.path].remove(coord) {
+			delete(i.byPath, entry.path)
+		}
+		// The instance could be moved to another tile, which is already reindexed.
+		if i.byInstanceId[entry.instanceId] == coord {
+			delete(i.byInstanceId, entry.instanceId)
+		}
+	}
+	delete(i.tiles, coord)
+}
+
+// Counts instances on tiles, since the same prefab could be placed on the tile several times.
+type coordsCount map[util.Point]int
+
+func (c coordsCount) add(coord util.Point) {
+	c[coord]++
+}
+
+// Returns true if there are no coords left.
+func (c coordsCount) remove(coord util.Point) bool {
+	if c[coord]--; c[coord] <= 0 {
+		delete(c, coord)
+	}
+	return len(c) == 0
+}
+
+func collectCoords(coords coordsCount) []util.Point {
+	if len(coords) == 0 {
+		return nil
+	}
+	result := make([]util.Point, 0, len(coords))
+	for coord := range coords {
+		result = append(result, coord)
+	}
+	sortCoords(result)
+	return result
+}
@@ -0,0 +1,160 @@
+package dmmap
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"sdmm/dmapi/dm"
+	"sdmm/dmapi/dmenv"
+	"sdmm/dmapi/dmmap/dmmdata/dmmprefab"
+	"sdmm/dmapi/dmmap/dmminstance"
+	"sdmm/dmapi/dmvars"
+	"sdmm/util"
+)
+
+var indexTestPaths = []string{"/obj", "/obj/item", "/obj/item/tool", "/turf", "/area"}
+
+func indexTestPrefabs(ctx *dmenv.Context) []*dmmprefab.Prefab {
+	return []*dmmprefab.Prefab{
+		ctx.Prefabs.Get("/obj", &dmvars.Variables{}),
+		ctx.Prefabs.Get("/obj/item", &dmvars.Variables{}),
+		ctx.Prefabs.Get("/obj/item", dmvars.Set(&dmvars.Variables{}, "name", `"item"`)),
+		ctx.Prefabs.Get("/obj/item/tool", &dmvars.Variables{}),
+		ctx.Prefabs.Get("/turf/floor", &dmvars.Variables{}),
+		ctx.Prefabs.Get("/area/room", &dmvars.Variables{}),
+	}
+}
+
+// Compares the index with the result of the full scan of the map.
+func assertIndexConsistent(t *testing.T, dmm *Dmm) {
+	t.Helper()
+
+	byPrefabId := make(map[uint64]map[util.Point]bool)
+	byPath := make(map[string]map[util.Point]bool)
+	instanceCoords := make(map[uint64]util.Point)
+
+	for _, tile := range dmm.AllTiles() {
+		for _, instance := range tile.Instances() {
+			prefab := instance.Prefab()
+			if byPrefabId[prefab.Id()] == nil {
+				byPrefabId[prefab.Id()] = make(map[util.Point]bool)
+			}
+			byPrefabId[prefab.Id()][tile.Coord] = true
+			for _, path := range append(indexTestPaths, prefab.Path()) {
+				if !dm.IsPath(prefab.Path(), path) {
+					continue
+				}
+				if byPath[path] == nil {
+					byPath[path] = make(map[util.Point]bool)
+				}
+				byPath[path][tile.Coord] = true
+			}
+			instanceCoords[instance.Id()] = tile.Coord
+		}
+	}
+
+	toCoords := func(set map[util.Point]bool) []util.Point {
+		if len(set) == 0 {
+			return nil
+		}
+		coords := make([]util.Point, 0, len(set))
+		for coord := range set {
+			coords = append(coords, coord)
+		}
+		sortCoords(coords)
+		return coords
+	}
+
+	for _, prefab := range indexTestPrefabs(dmm.Context()) {
+		assert.Equal(t, toCoords(byPrefabId[prefab.Id()]), dmm.Index().CoordsByPrefabId(prefab.Id()), prefab.Path())
+	}
+	for _, path := range indexTestPaths {
+		assert.Equal(t, toCoords(byPath[path]), dmm.Index().CoordsByPath(path, true), path)
+	}
+	for instanceId, coord := range instanceCoords {
+		indexCoord, ok := dmm.Index().InstanceCoord(instanceId)
+		assert.True(t, ok)
+		assert.Equal(t, coord, indexCoord)
+	}
+
+	areas := dmm.Index().Areas()
+	assert.Equal(t, toCoords(byPath["/area/room"]), areas["/area/room"])
+}
+
+func TestIndex_RandomEdits(t *testing.T) {
+	dmm := loadRegionTestDmm(t, regionTestMap)
+	dmm.SetMapSize(6, 6, 2)
+
+	rnd := rand.New(rand.NewSource(1))
+	randomCoord := func() util.Point {
+		return util.Point{X: rnd.Intn(dmm.MaxX) + 1, Y: rnd.Intn(dmm.MaxY) + 1, Z: rnd.Intn(dmm.MaxZ) + 1}
+	}
+	prefabs := indexTestPrefabs(dmm.Context())
+	randomPrefab := func() *dmmprefab.Prefab {
+		return prefabs[rnd.Intn(len(prefabs))]
+	}
+
+	var removedInstances []uint64
+
+	for i := 0; i < 2000; i++ {
+		tile := dmm.GetTile(randomCoord())
+
+		switch rnd.Intn(6) {
+		case 0, 1:
+			tile.InstancesAdd(randomPrefab())
+		case 2:
+			if instances := tile.Instances(); len(instances) != 0 {
+				instance := instances[rnd.Intn(len(instances))]
+				tile.InstancesRemoveByInstance(instance)
+				removedInstances = append(removedInstances, instance.Id())
+			}
+		case 3:
+			if instances := tile.Instances(); len(instances) != 0 {
+				instances[rnd.Intn(len(instances))].SetPrefab(randomPrefab())
+			}
+		case 4:
+			tile.InstancesRemoveByPath("/obj/item")
+		case 5:
+			if rnd.Intn(20) == 0 {
+				dmm.SetMapSizeV(rnd.Intn(4)+4, rnd.Intn(4)+4, rnd.Intn(2)+1, Anchor(rnd.Intn(9)))
+			} else {
+				tile.InstancesSet(append(tile.Instances().Prefabs(), randomPrefab()))
+			}
+		}
+
+		// Lookups are done between edits, so the index is updated incrementally.
+		if i%50 == 0 {
+			assertIndexConsistent(t, dmm)
+		}
+	}
+
+	assertIndexConsistent(t, dmm)
+	for _, instanceId := range removedInstances {
+		assert.False(t, dmm.IsInstanceExist(instanceId))
+	}
+
+	// The copy has its own journal, so its index is updated separately from the original one.
+	dmmCopy := dmm.Copy()
+	assertIndexConsistent(t, dmmCopy)
+	dmmCopy.GetTile(util.Point{X: 1, Y: 1, Z: 1}).InstancesAdd(prefabs[0])
+	assertIndexConsistent(t, dmmCopy)
+	assertIndexConsistent(t, dmm)
+}
+
+func TestIndex_InstancesByPath(t *testing.T) {
+	dmm := loadRegionTestDmm(t, regionTestMap)
+
+	assert.Len(t, dmm.InstancesByPath("/turf/floor", false), 4)
+	assert.Len(t, dmm.InstancesByPath("/turf", false), 0)
+	assert.Len(t, dmm.InstancesByPath("/turf", true), 9)
+
+	chair := dmm.InstancesByPath("/obj/chair", false)[0]
+	assert.Equal(t, util.Point{X: 2, Y: 2, Z: 1}, chair.Coord())
+	assert.Equal(t, []*dmminstance.Instance{chair}, dmm.InstancesByPrefabId(chair.Prefab().Id()))
+
+	dmm.GetTile(chair.Coord()).InstancesRemoveByInstance(chair)
+	assert.Empty(t, dmm.InstancesByPath("/obj", true))
+	assert.False(t, dmm.IsInstanceExist(chair.Id()))
+}
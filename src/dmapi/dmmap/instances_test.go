@@ -0,0 +1,37 @@
+package dmmap
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"sdmm/dmapi/dmmap/dmminstance"
+	"sdmm/util"
+)
+
+func TestInstanceIdConcurrentAllocation(t *testing.T) {
+	const workers, instances = 8, 1000
+
+	ids := make([][]uint64, workers)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < instances; i++ {
+				ids[w] = append(ids[w], dmminstance.New(util.Point{}, nil).Id())
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	unique := make(map[uint64]bool, workers*instances)
+	for _, workerIds := range ids {
+		for _, id := range workerIds {
+			unique[id] = true
+		}
+	}
+	assert.Len(t, unique, workers*instances)
+}
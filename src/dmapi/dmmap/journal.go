@@ -0,0 +1,91 @@
+package dmmap
+
+import (
+	"sort"
+	"sync"
+
+	"sdmm/util"
+)
+
+// Journal collects coords of tiles modified since the last flush.
+// Tiles and their instances write to the journal of the map, when they are modified.
+// It allows finding map changes without a full scan of the map.
+// A nil journal considers the whole map as modified.
+type Journal struct {
+	mu sync.Mutex
+
+	coords map[util.Point]bool
+	all    bool
+
+	// The index of the map, which is updated with every record.
+	index *Index
+}
+
+func newJournal() *Journal {
+	return &Journal{coords: make(map[util.Point]bool)}
+}
+
+// Touch records the tile with the provided coord as modified.
+func (j *Journal) Touch(coord util.Point) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	if !j.all {
+		j.coords[coord] = true
+	}
+	j.mu.Unlock()
+	if j.index != nil {
+		j.index.touch(coord)
+	}
+}
+
+// TouchAll records the whole map as modified.
+func (j *Journal) TouchAll() {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	j.all = true
+	j.coords = make(map[util.Point]bool)
+	j.mu.Unlock()
+	if j.index != nil {
+		j.index.touchAll()
+	}
+}
+
+// Flush returns coords of modified tiles and clears the journal.
+// The second value is true, if the whole map should be considered as modified.
+func (j *Journal) Flush() (coords []util.Point, all bool) {
+	if j == nil {
+		return nil, true
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.all {
+		j.all = false
+		return nil, true
+	}
+
+	coords = make([]util.Point, 0, len(j.coords))
+	for coord := range j.coords {
+		coords = append(coords, coord)
+	}
+	sortCoords(coords)
+	j.coords = make(map[util.Point]bool)
+	return coords, false
+}
+
+// Sorts coords in the order of tiles on the map.
+func sortCoords(coords []util.Point) {
+	sort.Slice(coords, func(i, j int) bool {
+		if coords[i].Z != coords[j].Z {
+			return coords[i].Z < coords[j].Z
+		}
+		if coords[i].Y != coords[j].Y {
+			return coords[i].Y < coords[j].Y
+		}
+		return coords[i].X < coords[j].X
+	})
+}
@@ -0,0 +1,179 @@
+package dmmap
+
+import (
+	"fmt"
+	"log"
+
+	"sdmm/dmapi/dm"
+	"sdmm/dmapi/dmmap/dmmdata"
+	"sdmm/util"
+)
+
+// Extract returns a standalone map data with the content of tiles from the provided area on the provided z-levels.
+// Tiles out of the map are ignored, so the result is limited by the map bounds.
+// The data has no file path, it's expected to be set before the save.
+func (d *Dmm) Extract(area util.Bounds, minZ, maxZ int) (*dmmdata.DmmData, error) {
+	minX, minY := maxInt(int(area.X1), 1), maxInt(int(area.Y1), 1)
+	maxX, maxY := minInt(int(area.X2), d.MaxX), minInt(int(area.Y2), d.MaxY)
+	minZ, maxZ = maxInt(minZ, 1), minInt(maxZ, d.MaxZ)
+
+	if minX > maxX || minY > maxY || minZ > maxZ {
+		return nil, fmt.Errorf("no tiles to extract in area [%v] on z-levels [%d-%d]", area, minZ, maxZ)
+	}
+
+	data := &dmmdata.DmmData{
+		LineBreak:  "\n",
+		MaxX:       maxX - minX + 1,
+		MaxY:       maxY - minY + 1,
+		MaxZ:       maxZ - minZ + 1,
+		Dictionary: make(dmmdata.DataDictionary),
+	}
+	data.Grid = dmmdata.NewDataGrid(data.MaxX, data.MaxY, data.MaxZ)
+
+	// Tiles with the same content share the same key, so the content is collected before keys are created.
+	var contents []dmmdata.Prefabs
+	contentIdxByHash := make(map[uint64][]int)
+	contentIdxByLoc := make([]int, 0, data.MaxX*data.MaxY*data.MaxZ)
+
+	for z := minZ; z <= maxZ; z++ {
+		for y := minY; y <= maxY; y++ {
+			for x := minX; x <= maxX; x++ {
+				prefabs := d.GetTile(util.Point{X: x, Y: y, Z: z}).Instances().Sorted().Prefabs()
+				contentIdxByLoc = append(contentIdxByLoc, findOrAddContent(&contents, contentIdxByHash, prefabs))
+			}
+		}
+	}
+
+	for keyLength := 1; keyLength <= dmmdata.MaxKeyLength; keyLength++ {
+		if len(contents) <= dmmdata.KeyCapacity(keyLength) {
+			data.KeyLength = keyLength
+			break
+		}
+	}
+	if data.KeyLength == 0 {
+		return nil, fmt.Errorf("too many unique tiles to extract: %d (capacity: %d)",
+			len(contents), dmmdata.KeyCapacity(dmmdata.MaxKeyLength))
+	}
+
+	keys := make([]dmmdata.Key, 0, len(contents))
+	for idx, prefabs := range contents {
+		key := dmmdata.KeyFromNum(idx, data.KeyLength)
+		keys = append(keys, key)
+		data.Dictionary[key] = prefabs
+	}
+
+	locIdx := 0
+	for z := 1; z <= data.MaxZ; z++ {
+		for y := 1; y <= data.MaxY; y++ {
+			for x := 1; x <= data.MaxX; x++ {
+				data.Grid.Set(util.Point{X: x, Y: y, Z: z}, keys[contentIdxByLoc[locIdx]])
+				locIdx++
+			}
+		}
+	}
+
+	log.Printf("[dmmap] extracted [%dx%dx%d] tiles with [%d] keys from: %s", data.MaxX, data.MaxY, data.MaxZ, len(keys), d.Name)
+	return data, nil
+}
+
+func findOrAddContent(contents *[]dmmdata.Prefabs, idxByHash map[uint64][]int, prefabs dmmdata.Prefabs) int {
+	hash := prefabs.Hash()
+	for _, idx := range idxByHash[hash] {
+		if (*contents)[idx].Equals(prefabs) {
+			return idx
+		}
+	}
+	idx := len(*contents)
+	*contents = append(*contents, prefabs)
+	idxByHash[hash] = append(idxByHash[hash], idx)
+	return idx
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// InsertOptions describe how the map data is inserted into the map.
+type InsertOptions struct {
+	// SkipAreas keeps areas of the map, instead of areas from the inserted data.
+	SkipAreas bool
+	// SkipTurfs keeps turfs of the map, instead of turfs from the inserted data.
+	SkipTurfs bool
+}
+
+// Insert places the content of the provided map data on the map.
+// The offset is a coord of the map, where the first tile of the data (1,1,1) is placed.
+// Tiles which are out of the map are ignored. Returns coords of tiles the data was placed on.
+func (d *Dmm) Insert(data *dmmdata.DmmData, offset util.Point, opts InsertOptions) []util.Point {
+	// Prefabs are resolved once for every key, since the same key is used by many tiles.
+	prefabsByKey := make(map[dmmdata.Key]dmmdata.Prefabs, len(data.Dictionary))
+	for key, prefabs := range data.Dictionary {
+		prefabsByKey[key] = d.storePrefabs(prefabs)
+	}
+
+	var coords []util.Point
+
+	for z := 1; z <= data.MaxZ; z++ {
+		for y := 1; y <= data.MaxY; y++ {
+			for x := 1; x <= data.MaxX; x++ {
+				loc := util.Point{X: x, Y: y, Z: z}
+				coord := offset.Plus(loc).Minus(util.Point{X: 1, Y: 1, Z: 1})
+
+				tile, ok := d.GetTileV(coord)
+				if !ok {
+					continue
+				}
+
+				tile.InstancesSet(insertedPrefabs(tile.Instances().Prefabs(), prefabsByKey[data.Grid.Get(loc)], opts))
+				tile.InstancesRegenerate()
+
+				coords = append(coords, coord)
+			}
+		}
+	}
+
+	log.Printf("[dmmap] inserted [%dx%dx%d] tiles at [%v] into: %s", data.MaxX, data.MaxY, data.MaxZ, offset, d.Name)
+	return coords
+}
+
+// Returns a tile content, where the current prefabs are replaced with the inserted ones with respect to the options.
+func insertedPrefabs(current, inserted dmmdata.Prefabs, opts InsertOptions) dmmdata.Prefabs {
+	isSkipped := func(path string) bool {
+		return opts.SkipAreas && dm.IsPath(path, "/area") || opts.SkipTurfs && dm.IsPath(path, "/turf")
+	}
+
+	prefabs := make(dmmdata.Prefabs, 0, len(current)+len(inserted))
+	for _, prefab := range current {
+		if isSkipped(prefab.Path()) {
+			prefabs = append(prefabs, prefab)
+		}
+	}
+	for _, prefab := range inserted {
+		if !isSkipped(prefab.Path()) {
+			prefabs = append(prefabs, prefab)
+		}
+	}
+	return prefabs.Sorted()
+}
+
+// Prefabs from the dmmdata don't know about environment objects, so they are linked in the same way as on the map load.
+func (d *Dmm) storePrefabs(prefabs dmmdata.Prefabs) dmmdata.Prefabs {
+	stored := make(dmmdata.Prefabs, 0, len(prefabs))
+	for _, prefab := range prefabs {
+		if vars, ok := d.ctx.Env().ObjectVars(prefab.Path()); ok && !prefab.Vars().HasParent() {
+			prefab.Vars().LinkParent(vars)
+		}
+		stored = append(stored, d.ctx.Prefabs.Put(prefab))
+	}
+	return stored
+}
@@ -0,0 +1,157 @@
+package dmmap
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"sdmm/dmapi/dmenv"
+	"sdmm/dmapi/dmmap/dmmdata"
+	"sdmm/dmapi/dmmap/dmmdata/dmmprefab"
+	"sdmm/dmapi/dmvars"
+	"sdmm/util"
+)
+
+const regionTestMap = `"a" = (/turf/floor,/area/station)
+"b" = (/obj/chair,/turf/floor,/area/station)
+"c" = (/turf/wall,/area/space)
+
+(1,1,1) = {"
+ccc
+cba
+caa
+"}
+`
+
+const regionTestTemplate = `"x" = (/obj/table,/turf/wall,/area/ruin)
+"y" = (/turf/floor,/area/ruin)
+
+(1,1,1) = {"
+xy
+"}
+`
+
+func loadRegionTestDmm(t *testing.T, content string) *Dmm {
+	path := filepath.Join(t.TempDir(), "test.dmm")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	data, err := dmmdata.New(path)
+	require.NoError(t, err)
+
+	dme := &dmenv.Dme{RootDir: filepath.Dir(path), Objects: map[string]*dmenv.Object{}}
+	dmm, _ := New(dme.Context(), data, path)
+	return dmm
+}
+
+func loadRegionTestData(t *testing.T, content string) *dmmdata.DmmData {
+	path := filepath.Join(t.TempDir(), "template.dmm")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	data, err := dmmdata.New(path)
+	require.NoError(t, err)
+	return data
+}
+
+func regionTilePaths(dmm *Dmm, coord util.Point) (paths []string) {
+	for _, instance := range dmm.GetTile(coord).Instances().Sorted() {
+		paths = append(paths, instance.Prefab().Path())
+	}
+	return paths
+}
+
+func dataTilePaths(data *dmmdata.DmmData, loc util.Point) (paths []string) {
+	for _, prefab := range data.Dictionary[data.Grid.Get(loc)] {
+		paths = append(paths, prefab.Path())
+	}
+	return paths
+}
+
+func TestExtract(t *testing.T) {
+	dmm := loadRegionTestDmm(t, regionTestMap)
+
+	// The area is limited by the map bounds.
+	data, err := dmm.Extract(util.Bounds{X1: 2, Y1: 0, X2: 5, Y2: 2}, 1, 1)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, data.MaxX)
+	assert.Equal(t, 2, data.MaxY)
+	assert.Equal(t, 1, data.MaxZ)
+	assert.Equal(t, 1, data.KeyLength)
+	assert.Len(t, data.Dictionary, 2) // Tiles with the same content share the key.
+
+	assert.Equal(t, []string{"/turf/floor", "/area/station"}, dataTilePaths(data, util.Point{X: 1, Y: 1, Z: 1}))
+	assert.Equal(t, []string{"/obj/chair", "/turf/floor", "/area/station"}, dataTilePaths(data, util.Point{X: 1, Y: 2, Z: 1}))
+
+	_, err = dmm.Extract(util.Bounds{X1: 4, Y1: 4, X2: 5, Y2: 5}, 1, 1)
+	assert.Error(t, err)
+}
+
+// Every unique tile content needs its own key, so the key length grows until BYOND is unable to read keys.
+func TestExtractKeyLength(t *testing.T) {
+	dmm := loadRegionTestDmm(t, regionTestMap)
+	dmm.SetMapSize(256, 256, 1)
+	for y := 1; y <= dmm.MaxY; y++ {
+		for x := 1; x <= dmm.MaxX; x++ {
+			prefab := dmmprefab.New(dmmprefab.IdNone, fmt.Sprintf("/obj/tile_%d_%d", x, y), &dmvars.Variables{})
+			tile := dmm.GetTile(util.Point{X: x, Y: y, Z: 1})
+			tile.InstancesSet(dmmdata.Prefabs{dmm.Context().Prefabs.Put(prefab)})
+			tile.InstancesRegenerate()
+		}
+	}
+
+	data, err := dmm.Extract(util.Bounds{X1: 1, Y1: 1, X2: 52, Y2: 1}, 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, data.KeyLength)
+
+	data, err = dmm.Extract(util.Bounds{X1: 1, Y1: 1, X2: 53, Y2: 1}, 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, data.KeyLength)
+
+	data, err = dmm.Extract(util.Bounds{X1: 1, Y1: 1, X2: 256, Y2: 255}, 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 3, data.KeyLength)
+
+	// 256x256 unique tiles are more than 65529 keys available.
+	_, err = dmm.Extract(util.Bounds{X1: 1, Y1: 1, X2: 256, Y2: 256}, 1, 1)
+	assert.Error(t, err)
+}
+
+func TestInsert(t *testing.T) {
+	dmm := loadRegionTestDmm(t, regionTestMap)
+	template := loadRegionTestData(t, regionTestTemplate)
+
+	// The last tile of the template is out of the map.
+	coords := dmm.Insert(template, util.Point{X: 3, Y: 1, Z: 1}, InsertOptions{})
+	assert.Equal(t, []util.Point{{X: 3, Y: 1, Z: 1}}, coords)
+	assert.Equal(t, []string{"/obj/table", "/turf/wall", "/area/ruin"}, regionTilePaths(dmm, util.Point{X: 3, Y: 1, Z: 1}))
+
+	dmm.Insert(template, util.Point{X: 1, Y: 2, Z: 1}, InsertOptions{SkipAreas: true})
+	assert.Equal(t, []string{"/obj/table", "/turf/wall", "/area/space"}, regionTilePaths(dmm, util.Point{X: 1, Y: 2, Z: 1}))
+	assert.Equal(t, []string{"/turf/floor", "/area/station"}, regionTilePaths(dmm, util.Point{X: 2, Y: 2, Z: 1}))
+
+	dmm.Insert(template, util.Point{X: 1, Y: 3, Z: 1}, InsertOptions{SkipAreas: true, SkipTurfs: true})
+	assert.Equal(t, []string{"/obj/table", "/turf/wall", "/area/space"}, regionTilePaths(dmm, util.Point{X: 1, Y: 3, Z: 1}))
+	assert.Equal(t, []string{"/turf/wall", "/area/space"}, regionTilePaths(dmm, util.Point{X: 2, Y: 3, Z: 1}))
+}
+
+func TestExtractInsertRoundTrip(t *testing.T) {
+	src := loadRegionTestDmm(t, regionTestMap)
+	dst := loadRegionTestDmm(t, regionTestMap)
+	dst.SetMapSize(6, 6, 1)
+
+	data, err := src.Extract(util.Bounds{X1: 1, Y1: 1, X2: 3, Y2: 3}, 1, 1)
+	require.NoError(t, err)
+
+	dst.Insert(data, util.Point{X: 4, Y: 4, Z: 1}, InsertOptions{})
+
+	for y := 1; y <= 3; y++ {
+		for x := 1; x <= 3; x++ {
+			coord := util.Point{X: x, Y: y, Z: 1}
+			assert.Equal(t, regionTilePaths(src, coord), regionTilePaths(dst, coord.Plus(util.Point{X: 3, Y: 3})))
+		}
+	}
+}
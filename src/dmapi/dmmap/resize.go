@@ -0,0 +1,132 @@
+package dmmap
+
+import (
+	"log"
+
+	"sdmm/dmapi/dmmap/dmmdata"
+	"sdmm/util"
+)
+
+// Anchor is a side of the map, which content is kept in place when the map is resized.
+// Anchors are declared in the reading order, as they are seen in the editor (the north is at the top).
+type Anchor int
+
+const (
+	AnchorNorthWest Anchor = iota
+	AnchorNorth
+	AnchorNorthEast
+	AnchorWest
+	AnchorCenter
+	AnchorEast
+	AnchorSouthWest
+	AnchorSouth
+	AnchorSouthEast
+)
+
+// Returns the shift of the map content along the X and Y axes, when the map is resized.
+func (a Anchor) shift(oldMaxX, oldMaxY, newMaxX, newMaxY int) (int, int) {
+	column, row := int(a)%3, int(a)/3
+	// Columns go from the west to the east, while rows go from the north to the south.
+	return (newMaxX - oldMaxX) * column / 2, (newMaxY - oldMaxY) * (2 - row) / 2
+}
+
+// SetMapSizeV changes the size of the map. The content of the map is placed inside new bounds with respect
+// to the provided anchor, so the map could be grown or cut on any side. The Z-axis is grown or cut at the top.
+// New tiles are filled with base prefabs of the environment context.
+func (d *Dmm) SetMapSizeV(maxX, maxY, maxZ int, anchor Anchor) {
+	shiftX, shiftY := anchor.shift(d.MaxX, d.MaxY, maxX, maxY)
+
+	log.Printf("[dmmap] set map size [%s]: [%dx%dx%d], shift: [%d,%d]", d.Name, maxX, maxY, maxZ, shiftX, shiftY)
+
+	d.resize(maxX, maxY, maxZ, func(coord util.Point) util.Point {
+		return util.Point{X: coord.X - shiftX, Y: coord.Y - shiftY, Z: coord.Z}
+	})
+}
+
+// InsertLevel inserts a new z-level at the provided position, so the level and all levels above it are moved up.
+// The position is expected to be in the range from 1 to MaxZ+1. Tiles of the new level are filled with base prefabs.
+func (d *Dmm) InsertLevel(z int) {
+	if z < 1 || z > d.MaxZ+1 {
+		log.Printf("[dmmap] unable to insert level [%d] into [%s]: out of range", z, d.Name)
+		return
+	}
+
+	log.Printf("[dmmap] insert level [%d] into: %s", z, d.Name)
+
+	d.resize(d.MaxX, d.MaxY, d.MaxZ+1, func(coord util.Point) util.Point {
+		switch {
+		case coord.Z < z:
+			return coord
+		case coord.Z > z:
+			return util.Point{X: coord.X, Y: coord.Y, Z: coord.Z - 1}
+		}
+		return util.Point{} // The inserted level has no content.
+	})
+}
+
+// DeleteLevel removes the provided z-level, so all levels above it are moved down.
+// The map always has at least one z-level, so the last one can't be removed.
+func (d *Dmm) DeleteLevel(z int) {
+	if z < 1 || z > d.MaxZ || d.MaxZ == 1 {
+		log.Printf("[dmmap] unable to delete level [%d] from [%s]: out of range", z, d.Name)
+		return
+	}
+
+	log.Printf("[dmmap] delete level [%d] from: %s", z, d.Name)
+
+	d.resize(d.MaxX, d.MaxY, d.MaxZ-1, func(coord util.Point) util.Point {
+		if coord.Z < z {
+			return coord
+		}
+		return util.Point{X: coord.X, Y: coord.Y, Z: coord.Z + 1}
+	})
+}
+
+// Rebuilds tiles of the map with the provided size. The source function returns a coord of the current map,
+// which content is placed on the provided coord of the resized map. Tiles without a source are filled with base prefabs.
+func (d *Dmm) resize(maxX, maxY, maxZ int, source func(coord util.Point) util.Point) {
+	d.mu.Lock()
+
+	newTiles := make([]*Tile, maxX*maxY*maxZ)
+
+	for z := 1; z <= maxZ; z++ {
+		for y := 1; y <= maxY; y++ {
+			for x := 1; x <= maxX; x++ {
+				coord := util.Point{X: x, Y: y, Z: z}
+				tileIndex := tileIndex(maxX, maxY, x, y, z)
+
+				srcCoord := source(coord)
+
+				var tile *Tile
+				if !d.hasTile(srcCoord) {
+					// Fill an empty tile with basic prefabs.
+					tile = &Tile{
+						Coord:     coord,
+						instances: InstancesFromPrefabs(coord, dmmdata.Prefabs{d.ctx.BaseTurf, d.ctx.BaseArea}),
+					}
+				} else if srcCoord == coord {
+					tile = d.getTile(coord)
+				} else {
+					// Instances know their coords, so moved tiles are created from scratch.
+					tile = &Tile{
+						Coord:     coord,
+						instances: InstancesFromPrefabs(coord, d.getTile(srcCoord).instances.Prefabs()),
+					}
+				}
+
+				tile.attach(d)
+				newTiles[tileIndex] = tile
+			}
+		}
+	}
+
+	d.Tiles = newTiles
+	d.MaxX = maxX
+	d.MaxY = maxY
+	d.MaxZ = maxZ
+
+	d.mu.Unlock()
+
+	// The journal updates the index, which reads tiles with the map lock.
+	d.journal.TouchAll()
+}
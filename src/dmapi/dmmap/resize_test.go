@@ -0,0 +1,56 @@
+package dmmap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"sdmm/util"
+)
+
+func TestSetMapSizeV(t *testing.T) {
+	dmm := loadRegionTestDmm(t, regionTestMap)
+
+	// The chair is placed in the center of the map.
+	chair := util.Point{X: 2, Y: 2, Z: 1}
+	assert.Equal(t, []string{"/obj/chair", "/turf/floor", "/area/station"}, regionTilePaths(dmm, chair))
+
+	// Grow to the west and the south.
+	dmm.SetMapSizeV(5, 5, 1, AnchorNorthEast)
+	assert.Equal(t, []string{"/obj/chair", "/turf/floor", "/area/station"}, regionTilePaths(dmm, util.Point{X: 4, Y: 4, Z: 1}))
+	assert.Equal(t, []string{"/turf", "/area"}, regionTilePaths(dmm, util.Point{X: 1, Y: 1, Z: 1}))
+	assert.Equal(t, util.Point{X: 4, Y: 4, Z: 1}, dmm.GetTile(util.Point{X: 4, Y: 4, Z: 1}).Instances()[0].Coord())
+
+	// Cut around the center.
+	dmm.SetMapSizeV(1, 1, 1, AnchorCenter)
+	assert.Equal(t, []string{"/turf/wall", "/area/space"}, regionTilePaths(dmm, util.Point{X: 1, Y: 1, Z: 1}))
+
+	dmm = loadRegionTestDmm(t, regionTestMap)
+	dmm.SetMapSizeV(4, 4, 2, AnchorSouthWest)
+	assert.Equal(t, []string{"/obj/chair", "/turf/floor", "/area/station"}, regionTilePaths(dmm, chair))
+	assert.Equal(t, []string{"/turf", "/area"}, regionTilePaths(dmm, util.Point{X: 4, Y: 4, Z: 1}))
+	assert.Equal(t, []string{"/turf", "/area"}, regionTilePaths(dmm, util.Point{X: 1, Y: 1, Z: 2}))
+}
+
+func TestInsertDeleteLevel(t *testing.T) {
+	dmm := loadRegionTestDmm(t, regionTestMap)
+	dmm.SetMapSize(3, 3, 2)
+	dmm.GetTile(util.Point{X: 1, Y: 1, Z: 2}).InstancesSet(nil)
+
+	dmm.InsertLevel(2)
+	assert.Equal(t, 3, dmm.MaxZ)
+	assert.Equal(t, []string{"/turf/wall", "/area/space"}, regionTilePaths(dmm, util.Point{X: 1, Y: 1, Z: 1}))
+	assert.Equal(t, []string{"/turf", "/area"}, regionTilePaths(dmm, util.Point{X: 1, Y: 1, Z: 2}))
+	assert.Empty(t, regionTilePaths(dmm, util.Point{X: 1, Y: 1, Z: 3}))
+
+	dmm.DeleteLevel(1)
+	assert.Equal(t, 2, dmm.MaxZ)
+	assert.Equal(t, []string{"/turf", "/area"}, regionTilePaths(dmm, util.Point{X: 1, Y: 1, Z: 1}))
+	assert.Empty(t, regionTilePaths(dmm, util.Point{X: 1, Y: 1, Z: 2}))
+	assert.Equal(t, util.Point{X: 1, Y: 1, Z: 1}, dmm.GetTile(util.Point{X: 1, Y: 1, Z: 1}).Instances()[0].Coord())
+
+	// The last level can't be deleted.
+	dmm.DeleteLevel(2)
+	dmm.DeleteLevel(1)
+	assert.Equal(t, 1, dmm.MaxZ)
+}
@@ -1,42 +1,76 @@
 package dmmap
 
 import (
+	"sync"
+
 	"sdmm/dmapi/dm"
+	"sdmm/dmapi/dmenv"
 	"sdmm/dmapi/dmmap/dmmdata"
 	"sdmm/dmapi/dmmap/dmmdata/dmmprefab"
 	"sdmm/dmapi/dmmap/dmminstance"
 	"sdmm/util"
 )
 
+// Tile stores instances placed on the map coord.
+//
+// Tiles of the map share the map lock, since they are read by the background snapshot commit,
+// while the UI thread modifies them. Instances are stored as a copy-on-write slice: a slice returned
+// by the Instances method is never modified by the tile, so it's safe to use it without the lock.
 type Tile struct {
 	Coord     util.Point
 	instances Instances
+
+	ctx     *dmenv.Context
+	mu      *sync.RWMutex
+	journal *Journal
+}
+
+// Copy returns a deep copy of the tile. The copy is detached from the map journal and the map lock,
+// but it keeps the environment context of the map.
+func (t *Tile) Copy() Tile {
+	t.rLock()
+	defer t.rUnlock()
+	return t.copy()
 }
 
-func (t Tile) Copy() Tile {
+func (t *Tile) copy() Tile {
 	return Tile{
-		t.Coord,
-		t.instances.DeepCopy(),
+		Coord:     t.Coord,
+		instances: t.instances.DeepCopy(),
+		ctx:       t.ctx,
 	}
 }
 
 func (t *Tile) Set(instances Instances) {
+	t.lock()
 	t.instances = instances
+	t.attachInstances()
+	t.unlock()
+	t.journal.Touch(t.Coord)
 }
 
-func (t Tile) Instances() Instances {
+func (t *Tile) Instances() Instances {
+	t.rLock()
+	defer t.rUnlock()
 	return t.instances
 }
 
 func (t *Tile) InstancesSet(prefabs dmmdata.Prefabs) {
-	t.instances = InstancesFromPrefabs(t.Coord, prefabs)
+	t.Set(InstancesFromPrefabs(t.Coord, prefabs))
 }
 
 func (t *Tile) InstancesAdd(prefab *dmmprefab.Prefab) {
-	t.instances = append(t.instances, dmminstance.New(t.Coord, prefab))
+	instance := dmminstance.New(t.Coord, prefab)
+	instance.SetJournal(t.journal)
+	t.lock()
+	// Append never modifies elements visible to slices returned before, so it's safe for copy-on-write.
+	t.instances = append(t.instances, instance)
+	t.unlock()
+	t.journal.Touch(t.Coord)
 }
 
 func (t *Tile) InstancesRemoveByPath(pathToRemove string) {
+	t.lock()
 	instances := make(Instances, 0, len(t.instances))
 	for _, instance := range t.instances {
 		if !dm.IsPath(instance.Prefab().Path(), pathToRemove) {
@@ -44,21 +78,29 @@ func (t *Tile) InstancesRemoveByPath(pathToRemove string) {
 		}
 	}
 	t.instances = instances
+	t.unlock()
+	t.journal.Touch(t.Coord)
 }
 
 func (t *Tile) InstancesRemoveByInstance(i *dmminstance.Instance) {
+	t.lock()
 	for idx, instance := range t.instances {
 		if instance.Id() == i.Id() {
-			t.instances = append(t.instances[:idx], t.instances[idx+1:]...)
+			instances := make(Instances, 0, len(t.instances)-1)
+			instances = append(instances, t.instances[:idx]...)
+			t.instances = append(instances, t.instances[idx+1:]...)
+			t.unlock()
+			t.journal.Touch(t.Coord)
 			return
 		}
 	}
+	t.unlock()
 }
 
 // InstancesRegenerate adds missing base prefabs, if there are some of them.
 func (t *Tile) InstancesRegenerate() {
 	var hasArea, hasTurf bool
-	for _, instance := range t.instances {
+	for _, instance := range t.Instances() {
 		if dm.IsPath(instance.Prefab().Path(), "/area") {
 			hasArea = true
 		} else if dm.IsPath(instance.Prefab().Path(), "/turf") {
@@ -66,9 +108,49 @@ func (t *Tile) InstancesRegenerate() {
 		}
 	}
 	if !hasArea {
-		t.InstancesAdd(BaseArea)
+		t.InstancesAdd(t.ctx.BaseArea)
 	}
 	if !hasTurf {
-		t.InstancesAdd(BaseTurf)
+		t.InstancesAdd(t.ctx.BaseTurf)
+	}
+}
+
+// Attaches the tile and its instances to the journal, the lock and the context of the provided map.
+func (t *Tile) attach(dmm *Dmm) {
+	t.ctx = dmm.ctx
+	t.mu = &dmm.mu
+	t.journal = dmm.journal
+	t.attachInstances()
+}
+
+func (t *Tile) attachInstances() {
+	for _, instance := range t.instances {
+		instance.SetJournal(t.journal)
+	}
+}
+
+// Detached tiles aren't shared, so they have no lock.
+
+func (t *Tile) lock() {
+	if t.mu != nil {
+		t.mu.Lock()
+	}
+}
+
+func (t *Tile) unlock() {
+	if t.mu != nil {
+		t.mu.Unlock()
+	}
+}
+
+func (t *Tile) rLock() {
+	if t.mu != nil {
+		t.mu.RLock()
+	}
+}
+
+func (t *Tile) rUnlock() {
+	if t.mu != nil {
+		t.mu.RUnlock()
 	}
 }
@@ -0,0 +1,192 @@
+package dmmap
+
+import (
+	"strconv"
+
+	"sdmm/dmapi/dm"
+	"sdmm/dmapi/dmmap/dmmdata"
+	"sdmm/dmapi/dmmap/dmmdata/dmmprefab"
+	"sdmm/dmapi/dmvars"
+	"sdmm/util"
+	"sdmm/util/slice"
+)
+
+// Transform is a rotation or a reflection of a tiles group.
+// Rotations are done clockwise, as the map is seen in the editor.
+type Transform int
+
+const (
+	TransformRotate90 Transform = iota
+	TransformRotate180
+	TransformRotate270
+	TransformFlipHorizontal
+	TransformFlipVertical
+)
+
+var cardinalDirs = []int{dm.DirNorth, dm.DirSouth, dm.DirEast, dm.DirWest}
+
+func (t Transform) String() string {
+	switch t {
+	case TransformRotate90:
+		return "Rotate Clockwise"
+	case TransformRotate180:
+		return "Rotate 180 Degrees"
+	case TransformRotate270:
+		return "Rotate Counterclockwise"
+	case TransformFlipHorizontal:
+		return "Flip Horizontally"
+	case TransformFlipVertical:
+		return "Flip Vertically"
+	}
+	return "Unknown Transform"
+}
+
+// Size returns the size of the transformed area with the provided size.
+func (t Transform) Size(width, height int) (int, int) {
+	if t == TransformRotate90 || t == TransformRotate270 {
+		return height, width
+	}
+	return width, height
+}
+
+// Returns the transformed vector. The Y axis points to the north.
+func (t Transform) vector(x, y int) (int, int) {
+	switch t {
+	case TransformRotate90:
+		return y, -x
+	case TransformRotate180:
+		return -x, -y
+	case TransformRotate270:
+		return -y, x
+	case TransformFlipHorizontal:
+		return -x, y
+	case TransformFlipVertical:
+		return x, -y
+	}
+	return x, y
+}
+
+// Returns the transformed direction. Values which aren't a combination of cardinal directions are kept "as is".
+func (t Transform) dir(dir int) int {
+	if dir&^(dm.DirNorth|dm.DirSouth|dm.DirEast|dm.DirWest) != 0 {
+		return dir
+	}
+
+	var result int
+	for _, cardinal := range cardinalDirs {
+		if dir&cardinal != 0 {
+			result |= t.cardinalDir(cardinal)
+		}
+	}
+	return result
+}
+
+func (t Transform) cardinalDir(dir int) int {
+	x, y := t.vector(dirVector(dir))
+	switch {
+	case y > 0:
+		return dm.DirNorth
+	case y < 0:
+		return dm.DirSouth
+	case x > 0:
+		return dm.DirEast
+	default:
+		return dm.DirWest
+	}
+}
+
+func dirVector(dir int) (int, int) {
+	switch dir {
+	case dm.DirNorth:
+		return 0, 1
+	case dm.DirSouth:
+		return 0, -1
+	case dm.DirEast:
+		return 1, 0
+	}
+	return -1, 0
+}
+
+// TransformTiles returns copies of the provided tiles rotated or flipped inside their bounds.
+// The bottom-left corner of the bounds is kept in place, while the size of the bounds is changed with Transform.Size.
+//
+// Instances are transformed as well: "dir" values are remapped, "pixel_x" and "pixel_y" offsets are rotated or mirrored.
+// Prefabs of transformed instances are new, so they should be stored in the prefab storage to be placed on the map.
+func TransformTiles(tiles []Tile, transform Transform) []Tile {
+	if len(tiles) == 0 {
+		return nil
+	}
+
+	minX, minY := tiles[0].Coord.X, tiles[0].Coord.Y
+	maxX, maxY := minX, minY
+	for _, tile := range tiles[1:] {
+		minX, minY = minInt(minX, tile.Coord.X), minInt(minY, tile.Coord.Y)
+		maxX, maxY = maxInt(maxX, tile.Coord.X), maxInt(maxY, tile.Coord.Y)
+	}
+
+	// Transformed coords are moved back to the bottom-left corner of the initial bounds.
+	cornerX, cornerY := transform.vector(maxX-minX, maxY-minY)
+	shiftX, shiftY := minX-minInt(cornerX, 0), minY-minInt(cornerY, 0)
+
+	transformed := make([]Tile, 0, len(tiles))
+	for _, tile := range tiles {
+		x, y := transform.vector(tile.Coord.X-minX, tile.Coord.Y-minY)
+		coord := util.Point{X: x + shiftX, Y: y + shiftY, Z: tile.Coord.Z}
+
+		prefabs := make(dmmdata.Prefabs, 0, len(tile.Instances()))
+		for _, instance := range tile.Instances() {
+			prefabs = append(prefabs, transformPrefab(instance.Prefab(), transform))
+		}
+
+		transformed = append(transformed, Tile{Coord: coord, instances: InstancesFromPrefabs(coord, prefabs)})
+	}
+	return transformed
+}
+
+func transformPrefab(prefab *dmmprefab.Prefab, transform Transform) *dmmprefab.Prefab {
+	// Areas are the same for every tile, so they can't be transformed at all.
+	if dm.IsPath(prefab.Path(), "/area") {
+		return prefab
+	}
+
+	// Turfs are transformed only with explicitly set variables.
+	// Otherwise, every plain turf would receive the variable with the direction of the transform.
+	explicitOnly := dm.IsPath(prefab.Path(), "/turf")
+
+	vars := prefab.Vars()
+	origVars := vars
+
+	if !explicitOnly || slice.StrContains(vars.Iterate(), "dir") {
+		if dir := vars.IntV("dir", dm.DirDefault); transform.dir(dir) != dir {
+			vars = setTransformedVar(vars, "dir", transform.dir(dir))
+		}
+	}
+
+	hasPixelX, hasPixelY := slice.StrContains(vars.Iterate(), "pixel_x"), slice.StrContains(vars.Iterate(), "pixel_y")
+	if !explicitOnly || hasPixelX || hasPixelY {
+		pixelX, pixelY := vars.IntV("pixel_x", 0), vars.IntV("pixel_y", 0)
+		newPixelX, newPixelY := transform.vector(pixelX, pixelY)
+		if newPixelX != pixelX {
+			vars = setTransformedVar(vars, "pixel_x", newPixelX)
+		}
+		if newPixelY != pixelY {
+			vars = setTransformedVar(vars, "pixel_y", newPixelY)
+		}
+	}
+
+	if vars == origVars {
+		return prefab
+	}
+	return dmmprefab.New(dmmprefab.IdNone, prefab.Path(), vars)
+}
+
+// Returns variables with the provided value. When the value is equal to the inherited one,
+// the variable is removed instead, so prefabs don't get variables with default values.
+func setTransformedVar(vars *dmvars.Variables, name string, value int) *dmvars.Variables {
+	if vars.HasParent() {
+		if parentValue, ok := vars.Parent().Int(name); ok && parentValue == value {
+			return dmvars.Delete(vars, name)
+		}
+	}
+	return dmvars.Set(vars, name, strconv.Itoa(value))
+}
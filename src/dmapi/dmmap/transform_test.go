@@ -0,0 +1,126 @@
+package dmmap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"sdmm/dmapi/dm"
+	"sdmm/dmapi/dmmap/dmmdata/dmmprefab"
+	"sdmm/dmapi/dmvars"
+	"sdmm/util"
+)
+
+func transformTestTile(x, y int, prefabs ...*dmmprefab.Prefab) Tile {
+	coord := util.Point{X: x, Y: y, Z: 1}
+	return Tile{Coord: coord, instances: InstancesFromPrefabs(coord, prefabs)}
+}
+
+func transformTestPrefab(path string, vars ...string) *dmmprefab.Prefab {
+	v := &dmvars.Variables{}
+	for idx := 0; idx < len(vars); idx += 2 {
+		v = dmvars.Set(v, vars[idx], vars[idx+1])
+	}
+	return dmmprefab.New(dmmprefab.IdNone, path, v)
+}
+
+func transformedCoords(tiles []Tile) (coords []util.Point) {
+	for _, tile := range tiles {
+		coords = append(coords, tile.Coord)
+	}
+	return coords
+}
+
+func TestTransformDir(t *testing.T) {
+	assert.Equal(t, dm.DirEast, TransformRotate90.dir(dm.DirNorth))
+	assert.Equal(t, dm.DirSouthwest, TransformRotate90.dir(dm.DirSoutheast))
+	assert.Equal(t, dm.DirSouth, TransformRotate180.dir(dm.DirNorth))
+	assert.Equal(t, dm.DirNortheast, TransformRotate180.dir(dm.DirSouthwest))
+	assert.Equal(t, dm.DirWest, TransformRotate270.dir(dm.DirNorth))
+	assert.Equal(t, dm.DirNorthwest, TransformRotate270.dir(dm.DirNortheast))
+	assert.Equal(t, dm.DirWest, TransformFlipHorizontal.dir(dm.DirEast))
+	assert.Equal(t, dm.DirNorth, TransformFlipHorizontal.dir(dm.DirNorth))
+	assert.Equal(t, dm.DirSoutheast, TransformFlipVertical.dir(dm.DirNortheast))
+
+	// UP and DOWN aren't changed.
+	assert.Equal(t, 16, TransformRotate90.dir(16))
+	assert.Equal(t, 0, TransformRotate90.dir(0))
+}
+
+func TestTransformTiles_Coords(t *testing.T) {
+	// A 3x2 area with the bottom-left corner at (2,5).
+	var tiles []Tile
+	for y := 5; y <= 6; y++ {
+		for x := 2; x <= 4; x++ {
+			tiles = append(tiles, transformTestTile(x, y))
+		}
+	}
+
+	// The bottom-left tile of the rotated area is the bottom-right one of the initial area.
+	rotated := TransformTiles(tiles, TransformRotate90)
+	assert.Equal(t, []util.Point{
+		{X: 2, Y: 7, Z: 1}, {X: 2, Y: 6, Z: 1}, {X: 2, Y: 5, Z: 1},
+		{X: 3, Y: 7, Z: 1}, {X: 3, Y: 6, Z: 1}, {X: 3, Y: 5, Z: 1},
+	}, transformedCoords(rotated))
+
+	assert.Equal(t, []util.Point{
+		{X: 4, Y: 6, Z: 1}, {X: 3, Y: 6, Z: 1}, {X: 2, Y: 6, Z: 1},
+		{X: 4, Y: 5, Z: 1}, {X: 3, Y: 5, Z: 1}, {X: 2, Y: 5, Z: 1},
+	}, transformedCoords(TransformTiles(tiles, TransformRotate180)))
+
+	assert.Equal(t, []util.Point{
+		{X: 3, Y: 5, Z: 1}, {X: 3, Y: 6, Z: 1}, {X: 3, Y: 7, Z: 1},
+		{X: 2, Y: 5, Z: 1}, {X: 2, Y: 6, Z: 1}, {X: 2, Y: 7, Z: 1},
+	}, transformedCoords(TransformTiles(tiles, TransformRotate270)))
+
+	assert.Equal(t, []util.Point{
+		{X: 4, Y: 5, Z: 1}, {X: 3, Y: 5, Z: 1}, {X: 2, Y: 5, Z: 1},
+		{X: 4, Y: 6, Z: 1}, {X: 3, Y: 6, Z: 1}, {X: 2, Y: 6, Z: 1},
+	}, transformedCoords(TransformTiles(tiles, TransformFlipHorizontal)))
+
+	assert.Equal(t, []util.Point{
+		{X: 2, Y: 6, Z: 1}, {X: 3, Y: 6, Z: 1}, {X: 4, Y: 6, Z: 1},
+		{X: 2, Y: 5, Z: 1}, {X: 3, Y: 5, Z: 1}, {X: 4, Y: 5, Z: 1},
+	}, transformedCoords(TransformTiles(tiles, TransformFlipVertical)))
+
+	// Four rotations return tiles to their initial places.
+	for i := 0; i < 3; i++ {
+		rotated = TransformTiles(rotated, TransformRotate90)
+	}
+	assert.Equal(t, transformedCoords(tiles), transformedCoords(rotated))
+}
+
+func TestTransformTiles_Vars(t *testing.T) {
+	objVars := dmvars.Set(&dmvars.Variables{}, "dir", "2")
+	obj := transformTestPrefab("/obj/sign", "pixel_x", "32")
+	obj.Vars().LinkParent(objVars)
+
+	tiles := []Tile{transformTestTile(1, 1,
+		obj,
+		transformTestPrefab("/obj/chair", "dir", "1", "pixel_y", "-4"),
+		transformTestPrefab("/turf/floor"),
+		transformTestPrefab("/turf/decal", "dir", "4"),
+		transformTestPrefab("/area/room"),
+	)}
+
+	prefabs := TransformTiles(tiles, TransformRotate90)[0].Instances().Prefabs()
+
+	// The inherited dir is rotated as well.
+	assert.Equal(t, "8", prefabs[0].Vars().ValueV("dir", ""))
+	assert.Equal(t, "-32", prefabs[0].Vars().ValueV("pixel_y", ""))
+	assert.Equal(t, "0", prefabs[0].Vars().ValueV("pixel_x", ""))
+
+	assert.Equal(t, "4", prefabs[1].Vars().ValueV("dir", ""))
+	assert.Equal(t, "-4", prefabs[1].Vars().ValueV("pixel_x", ""))
+
+	// Turfs and areas without explicit variables are kept "as is".
+	assert.True(t, prefabs[2] == tiles[0].Instances()[2].Prefab())
+	assert.Equal(t, "2", prefabs[3].Vars().ValueV("dir", ""))
+	assert.True(t, prefabs[4] == tiles[0].Instances()[4].Prefab())
+
+	// A value equal to the inherited one isn't stored in the prefab.
+	prefabs = TransformTiles([]Tile{transformTestTile(1, 1, prefabs[0])}, TransformRotate270)[0].Instances().Prefabs()
+	assert.NotContains(t, prefabs[0].Vars().Iterate(), "dir")
+	assert.Equal(t, "2", prefabs[0].Vars().ValueV("dir", ""))
+	assert.Equal(t, "32", prefabs[0].Vars().ValueV("pixel_x", ""))
+}
@@ -5,6 +5,7 @@ import (
 	"sort"
 
 	"sdmm/dmapi/dm"
+	"sdmm/dmapi/dmenv"
 	"sdmm/dmapi/dmmap"
 	"sdmm/dmapi/dmmap/dmmdata"
 	"sdmm/util"
@@ -13,6 +14,9 @@ import (
 type PasteData struct {
 	Filter dm.PathsFilter
 	Buffer []dmmap.Tile
+
+	// Context of the environment the tiles were copied from.
+	Context *dmenv.Context
 }
 
 // Clipboard is a global storage for tiles to provide a copy/paste experience.
@@ -37,6 +41,7 @@ func (c *Clipboard) Copy(pathsFilter *dm.PathsFilter, dmm *dmmap.Dmm, tiles []ut
 	log.Printf("[dmmclip] copy tiles to the clipboard buffer: %v", tiles)
 
 	c.pasteData.Filter = pathsFilter.Copy()
+	c.pasteData.Context = dmm.Context()
 	c.pasteData.Buffer = make([]dmmap.Tile, 0, len(tiles))
 
 	for _, pos := range tiles {
@@ -58,6 +63,23 @@ func (c *Clipboard) Copy(pathsFilter *dm.PathsFilter, dmm *dmmap.Dmm, tiles []ut
 		c.pasteData.Buffer = append(c.pasteData.Buffer, tile)
 	}
 
+	c.sortBuffer()
+}
+
+// Transform rotates or flips tiles in the clipboard buffer, so they will be pasted transformed.
+func (c *Clipboard) Transform(transform dmmap.Transform) {
+	if len(c.pasteData.Buffer) == 0 {
+		return
+	}
+
+	log.Println("[dmmclip] transform the clipboard buffer:", transform)
+
+	c.pasteData.Buffer = dmmap.TransformTiles(c.pasteData.Buffer, transform)
+	c.sortBuffer()
+}
+
+// The paste is done from the first tile of the buffer, so it should be the bottom-left one.
+func (c *Clipboard) sortBuffer() {
 	sort.SliceStable(c.pasteData.Buffer, func(i, j int) bool {
 		return c.pasteData.Buffer[i].Coord.Y < c.pasteData.Buffer[j].Coord.Y
 	})
@@ -66,6 +88,44 @@ func (c *Clipboard) Copy(pathsFilter *dm.PathsFilter, dmm *dmmap.Dmm, tiles []ut
 	})
 }
 
+// Remap returns tiles of the buffer with prefabs taken from the provided context.
+// Tiles could be copied from a map of another environment, so their types are looked up by path.
+// Types unknown to the provided context are skipped and returned as the second value.
+func (p PasteData) Remap(ctx *dmenv.Context) ([]dmmap.Tile, []string) {
+	if p.Context == ctx {
+		return p.Buffer, nil
+	}
+
+	log.Println("[dmmclip] remap the clipboard buffer to the environment:", ctx.Env().RootFile)
+
+	missing := make(map[string]bool)
+	buffer := make([]dmmap.Tile, 0, len(p.Buffer))
+
+	for _, tileCopy := range p.Buffer {
+		tile := tileCopy.Copy()
+
+		var prefabs dmmdata.Prefabs
+		for _, prefab := range tile.Instances().Prefabs() {
+			if remapped, ok := ctx.Remap(prefab); ok {
+				prefabs = append(prefabs, remapped)
+			} else {
+				missing[prefab.Path()] = true
+			}
+		}
+
+		tile.InstancesSet(prefabs)
+		buffer = append(buffer, tile)
+	}
+
+	missingPaths := make([]string, 0, len(missing))
+	for path := range missing {
+		missingPaths = append(missingPaths, path)
+	}
+	sort.Strings(missingPaths)
+
+	return buffer, missingPaths
+}
+
 func (c *Clipboard) Buffer() PasteData {
 	return c.pasteData
 }
@@ -0,0 +1,188 @@
+package dmmmerge
+
+import (
+	"errors"
+	"log"
+	"strconv"
+
+	"sdmm/dmapi/dmmap/dmmdata"
+	"sdmm/dmapi/dmmap/dmmdata/dmmprefab"
+	"sdmm/dmapi/dmvars"
+	"sdmm/util"
+)
+
+// DefaultMarkerPath is a type path of the prefab used to mark conflicted tiles by default.
+const DefaultMarkerPath = "/obj/merge_conflict_marker"
+
+// ErrSizeConflict is returned when the map size was changed differently on both sides.
+var ErrSizeConflict = errors.New("map size changed on both sides")
+
+type Config struct {
+	// Marker is a prefab added on top of conflicted tiles.
+	// It lets to find and resolve conflicts in the editor. No marker is added, if it's nil.
+	Marker *dmmprefab.Prefab
+}
+
+// DefaultConfig returns a config with a marker made from the DefaultMarkerPath.
+func DefaultConfig() Config {
+	return Config{
+		Marker: dmmprefab.New(dmmprefab.IdNone, DefaultMarkerPath, &dmvars.Variables{}),
+	}
+}
+
+// Conflict is a tile which was changed on both sides in different ways.
+type Conflict struct {
+	Loc util.Point
+
+	Base, Ours, Theirs dmmdata.Prefabs
+
+	// Dropped is true when the tile is out of the merged map bounds, so it's not in the result at all.
+	// This happens when one side has shrunk the map, while the other one has modified the tile.
+	Dropped bool
+}
+
+type Result struct {
+	// Data is the merged map.
+	// Keys of the data are not real map keys, so it should be saved with the dmmsave.SaveData,
+	// which will allocate proper keys for it.
+	Data *dmmdata.DmmData
+
+	Conflicts []Conflict
+}
+
+// Merge does a three-way merge of maps tile by tile.
+// A tile changed only on one side is taken from that side. A tile changed on both sides in different ways
+// is a conflict: the result will have our version of the tile with the marker prefab on top of it.
+// The result inherits the format of our map.
+func Merge(base, ours, theirs *dmmdata.DmmData, cfg Config) (*Result, error) {
+	log.Printf("[dmmmerge] merging: base [%s], ours [%s], theirs [%s]", base.Filepath, ours.Filepath, theirs.Filepath)
+
+	maxX, maxY, maxZ, err := mergeSize(base, ours, theirs)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &dmmdata.DmmData{
+		Filepath:   ours.Filepath,
+		IsTgm:      ours.IsTgm,
+		LineBreak:  ours.LineBreak,
+		KeyLength:  ours.KeyLength,
+		MaxX:       maxX,
+		MaxY:       maxY,
+		MaxZ:       maxZ,
+		Dictionary: make(dmmdata.DataDictionary),
+		Grid:       dmmdata.NewDataGrid(maxX, maxY, maxZ),
+	}
+
+	result := &Result{Data: data}
+	keys := make(map[uint64]dmmdata.Key)
+
+	setContent := func(loc util.Point, prefabs dmmdata.Prefabs) {
+		hash := prefabs.Hash()
+		key, ok := keys[hash]
+		if !ok {
+			key = dmmdata.Key(strconv.Itoa(len(keys)))
+			keys[hash] = key
+			data.Dictionary[key] = prefabs
+		}
+		data.Grid.Set(loc, key)
+	}
+
+	// Go through the union of all maps bounds, so tiles dropped with a conflict are reported as well.
+	unionX := max(base.MaxX, max(ours.MaxX, theirs.MaxX))
+	unionY := max(base.MaxY, max(ours.MaxY, theirs.MaxY))
+	unionZ := max(base.MaxZ, max(ours.MaxZ, theirs.MaxZ))
+
+	for z := 1; z <= unionZ; z++ {
+		for y := 1; y <= unionY; y++ {
+			for x := 1; x <= unionX; x++ {
+				loc := util.Point{X: x, Y: y, Z: z}
+				inBounds := x <= maxX && y <= maxY && z <= maxZ
+
+				b, o, t := tileContent(base, loc), tileContent(ours, loc), tileContent(theirs, loc)
+
+				merged, ok := mergeTile(b, o, t)
+				if !ok {
+					result.Conflicts = append(result.Conflicts, Conflict{
+						Loc:     loc,
+						Base:    b,
+						Ours:    o,
+						Theirs:  t,
+						Dropped: !inBounds,
+					})
+					merged = o
+					if inBounds && cfg.Marker != nil {
+						merged = append(dmmdata.Prefabs{cfg.Marker}, o...)
+					}
+				}
+
+				if inBounds {
+					setContent(loc, merged)
+				}
+			}
+		}
+	}
+
+	log.Println("[dmmmerge] merge finished, conflicts:", len(result.Conflicts))
+
+	return result, nil
+}
+
+// Returns a merged version of the tile. The second value is false, if there is a conflict.
+func mergeTile(base, ours, theirs dmmdata.Prefabs) (dmmdata.Prefabs, bool) {
+	switch {
+	case equals(ours, theirs):
+		return ours, true
+	case equals(ours, base):
+		return theirs, true
+	case equals(theirs, base):
+		return ours, true
+	}
+	return nil, false
+}
+
+// Like the dmmdata.Prefabs.Equals, but respects the absence of the tile.
+func equals(a, b dmmdata.Prefabs) bool {
+	if (a == nil) != (b == nil) {
+		return false
+	}
+	return a.Equals(b)
+}
+
+// Returns a content of the tile, or nil if the location is out of the map bounds.
+func tileContent(data *dmmdata.DmmData, loc util.Point) dmmdata.Prefabs {
+	if loc.X > data.MaxX || loc.Y > data.MaxY || loc.Z > data.MaxZ {
+		return nil
+	}
+	if prefabs := data.Dictionary[data.Grid.Get(loc)]; prefabs != nil {
+		return prefabs
+	}
+	return dmmdata.Prefabs{}
+}
+
+func mergeSize(base, ours, theirs *dmmdata.DmmData) (maxX, maxY, maxZ int, err error) {
+	sizeOf := func(data *dmmdata.DmmData) util.Point {
+		return util.Point{X: data.MaxX, Y: data.MaxY, Z: data.MaxZ}
+	}
+
+	b, o, t := sizeOf(base), sizeOf(ours), sizeOf(theirs)
+
+	var size util.Point
+	switch {
+	case o == t, t == b:
+		size = o
+	case o == b:
+		size = t
+	default:
+		return 0, 0, 0, ErrSizeConflict
+	}
+
+	return size.X, size.Y, size.Z, nil
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
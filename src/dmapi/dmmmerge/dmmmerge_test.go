@@ -0,0 +1,94 @@
+package dmmmerge
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"sdmm/dmapi/dmmap/dmmdata"
+	"sdmm/util"
+)
+
+const dictionary = `"a" = (/turf,/area)
+"b" = (/obj,/turf,/area)
+"c" = (/mob,/turf,/area)
+`
+
+func parseMap(t *testing.T, grid string) *dmmdata.DmmData {
+	path := filepath.Join(t.TempDir(), "map.dmm")
+	require.NoError(t, os.WriteFile(path, []byte(dictionary+"\n(1,1,1) = {\"\n"+grid+"\"}\n"), 0644))
+	data, err := dmmdata.New(path)
+	require.NoError(t, err)
+	return data
+}
+
+func tilePaths(data *dmmdata.DmmData, x, y int) []string {
+	var paths []string
+	for _, prefab := range data.Dictionary[data.Grid.Get(util.Point{X: x, Y: y, Z: 1})] {
+		paths = append(paths, prefab.Path())
+	}
+	return paths
+}
+
+func TestMerge(t *testing.T) {
+	base := parseMap(t, "aaa\naaa\n")
+	ours := parseMap(t, "baa\naac\n")
+	theirs := parseMap(t, "aba\naab\n")
+
+	result, err := Merge(base, ours, theirs, DefaultConfig())
+	require.NoError(t, err)
+
+	// Changes of both sides are applied.
+	assert.Equal(t, []string{"/obj", "/turf", "/area"}, tilePaths(result.Data, 1, 2))
+	assert.Equal(t, []string{"/obj", "/turf", "/area"}, tilePaths(result.Data, 2, 2))
+	assert.Equal(t, []string{"/turf", "/area"}, tilePaths(result.Data, 3, 2))
+
+	// The bottom right tile was changed on both sides.
+	require.Len(t, result.Conflicts, 1)
+	assert.Equal(t, util.Point{X: 3, Y: 1, Z: 1}, result.Conflicts[0].Loc)
+	assert.False(t, result.Conflicts[0].Dropped)
+	assert.Equal(t, []string{DefaultMarkerPath, "/mob", "/turf", "/area"}, tilePaths(result.Data, 3, 1))
+}
+
+func TestMerge_SameChange(t *testing.T) {
+	base := parseMap(t, "aa\n")
+	ours := parseMap(t, "ba\n")
+	theirs := parseMap(t, "ba\n")
+
+	result, err := Merge(base, ours, theirs, DefaultConfig())
+	require.NoError(t, err)
+
+	assert.Empty(t, result.Conflicts)
+	assert.Equal(t, []string{"/obj", "/turf", "/area"}, tilePaths(result.Data, 1, 1))
+}
+
+func TestMerge_Size(t *testing.T) {
+	base := parseMap(t, "aa\n")
+	ours := parseMap(t, "aaa\n")
+	theirs := parseMap(t, "ab\n")
+
+	result, err := Merge(base, ours, theirs, DefaultConfig())
+	require.NoError(t, err)
+
+	assert.Empty(t, result.Conflicts)
+	assert.Equal(t, 3, result.Data.MaxX)
+	assert.Equal(t, []string{"/obj", "/turf", "/area"}, tilePaths(result.Data, 2, 1))
+
+	// Theirs has shrunk the map, while ours has modified the removed tile.
+	ours = parseMap(t, "aaa\n")
+	theirs = parseMap(t, "a\n")
+
+	result, err = Merge(parseMap(t, "aab\n"), ours, theirs, DefaultConfig())
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, result.Data.MaxX)
+	require.Len(t, result.Conflicts, 1)
+	assert.True(t, result.Conflicts[0].Dropped)
+
+	// Both sides have changed the size differently.
+	_, err = Merge(base, parseMap(t, "aaa\n"), parseMap(t, "a\n"), DefaultConfig())
+	assert.Equal(t, ErrSizeConflict, err)
+}
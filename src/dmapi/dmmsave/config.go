@@ -12,4 +12,12 @@ type Config struct {
 	Format Format
 
 	SanitizeVariables bool
+
+	// DeterministicKeys makes new keys to be allocated in a stable order (the lowest free key first).
+	// Identical maps are always saved with identical keys then.
+	DeterministicKeys bool
+
+	// PreserveLayout keeps the header and the grid blocks layout of the initial map, when it's saved in the same format.
+	// So maps made by other tools don't change their shape on every save.
+	PreserveLayout bool
 }
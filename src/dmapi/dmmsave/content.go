@@ -0,0 +1,39 @@
+package dmmsave
+
+import (
+	"sdmm/dmapi/dmmap"
+	"sdmm/dmapi/dmmap/dmmdata"
+	"sdmm/util"
+)
+
+// content is a source of tiles to save.
+// It lets the save process work with the editor map and with the raw map data the same way.
+type content interface {
+	size() (maxX, maxY, maxZ int)
+	prefabs(loc util.Point) dmmdata.Prefabs
+}
+
+type dmmContent struct {
+	dmm *dmmap.Dmm
+}
+
+func (c dmmContent) size() (int, int, int) {
+	return c.dmm.MaxX, c.dmm.MaxY, c.dmm.MaxZ
+}
+
+func (c dmmContent) prefabs(loc util.Point) dmmdata.Prefabs {
+	return c.dmm.GetTile(loc).Instances().Sorted().Prefabs()
+}
+
+// dataContent keeps prefabs in the same order as they are in the data, so they are saved "as is".
+type dataContent struct {
+	data *dmmdata.DmmData
+}
+
+func (c dataContent) size() (int, int, int) {
+	return c.data.MaxX, c.data.MaxY, c.data.MaxZ
+}
+
+func (c dataContent) prefabs(loc util.Point) dmmdata.Prefabs {
+	return c.data.Dictionary[c.data.Grid.Get(loc)]
+}
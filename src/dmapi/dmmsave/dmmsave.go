@@ -1,39 +1,68 @@
 package dmmsave
 
 import (
+	"fmt"
 	"log"
 
 	"sdmm/dmapi/dmenv"
+	"sdmm/dmapi/dmmap/dmmdata"
 
 	"sdmm/dmapi/dmmap"
-	"sdmm/util"
 )
 
-func Save(dme *dmenv.Dme, dmm *dmmap.Dmm, cfg Config) {
-	SaveV(dme, dmm, dmm.Path.Absolute, cfg)
+func Save(ctx *dmenv.Context, dmm *dmmap.Dmm, cfg Config) error {
+	return SaveV(ctx, dmm, dmm.Path.Absolute, cfg)
 }
 
-func SaveV(dme *dmenv.Dme, dmm *dmmap.Dmm, path string, cfg Config) {
+// SaveV saves the map by the provided path.
+// The file by the path is replaced only when the map is saved successfully.
+// Variables of the map are sanitized with objects of the provided environment context.
+func SaveV(ctx *dmenv.Context, dmm *dmmap.Dmm, path string, cfg Config) error {
 	log.Printf("[dmmsave] save started [%s]...", path)
 
-	sp, err := makeSaveProcess(cfg, dme, dmm, path)
+	// The map could be opened with problems in its content, so they are ignored here.
+	// The initial data is needed only to reuse its keys.
+	initial, _, err := dmmdata.NewTolerant(dmm.Backup)
 	if err != nil {
-		log.Println("[dmmsave] unable to start save process")
-		util.ShowErrorDialog("Unable to start save process")
-		return
+		log.Println("[dmmsave] unable to read map backup:", dmm.Backup)
+		return fmt.Errorf("unable to read map backup: %w", err)
 	}
 
+	// Copy the dmm to avoid unneeded modifications.
+	dmmCopy := dmm.Copy()
+
 	if cfg.SanitizeVariables {
-		sp.sanitizeVariables()
+		sanitizeVariables(ctx.Env(), dmmCopy)
 	}
 
-	sp.handleReusedKeys()
-	if err = sp.handleLocationsWithoutKeys(); err != nil {
+	sp := makeSaveProcess(cfg, dmmContent{dmmCopy}, initial, path)
+	if err = sp.run(); err != nil {
 		log.Println("[dmmsave] unable to handle locations without keys:", err)
-		util.ShowErrorDialog("Unable to save the map: " + err.Error())
-		return
+		return err
+	}
+	if err = sp.output.SaveV(sp.cfg.PreserveLayout); err != nil {
+		return err
 	}
-	sp.output.Save()
 
 	log.Println("[dmmsave] save finished")
+	return nil
+}
+
+// SaveData saves the raw map data without an environment.
+// Keys of the initial data are reused in the same way as they are for the editor map.
+// Since there is no environment, the Config.SanitizeVariables option is ignored.
+func SaveData(initial, data *dmmdata.DmmData, path string, cfg Config) error {
+	log.Printf("[dmmsave] data save started [%s]...", path)
+
+	sp := makeSaveProcess(cfg, dataContent{data}, initial, path)
+	if err := sp.run(); err != nil {
+		log.Println("[dmmsave] unable to handle locations without keys:", err)
+		return err
+	}
+	if err := sp.output.SaveV(sp.cfg.PreserveLayout); err != nil {
+		return err
+	}
+
+	log.Println("[dmmsave] data save finished")
+	return nil
 }
@@ -0,0 +1,282 @@
+package dmmsave
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"sdmm/dmapi/dmenv"
+	"sdmm/dmapi/dmmap"
+	"sdmm/dmapi/dmmap/dmmdata"
+	"sdmm/dmapi/dmmap/dmmdata/dmmprefab"
+	"sdmm/dmapi/dmvars"
+	"sdmm/util"
+)
+
+const initialMap = `"a" = (/turf,/area)
+"b" = (/obj,/turf,/area)
+"c" = (/obj{name = "c"},/turf,/area)
+
+(1,1,1) = {"
+abca
+aaaa
+bbaa
+"}
+`
+
+func writeMap(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func loadDmm(t *testing.T, path string) (*dmenv.Dme, *dmmap.Dmm) {
+	data, err := dmmdata.New(path)
+	require.NoError(t, err)
+
+	dme := &dmenv.Dme{
+		RootDir: filepath.Dir(path),
+		Objects: map[string]*dmenv.Object{
+			"/turf": {Path: "/turf", Vars: &dmvars.Variables{}},
+			"/area": {Path: "/area", Vars: &dmvars.Variables{}},
+			"/obj":  {Path: "/obj", Vars: &dmvars.Variables{}},
+		},
+	}
+
+	dmm, unknownPrefabs := dmmap.New(dme.Context(), data, path)
+	require.Empty(t, unknownPrefabs)
+
+	return dme, dmm
+}
+
+// Fills every tile of the map with a unique content, so every tile requires its own key.
+func editDmm(dmm *dmmap.Dmm) {
+	for idx, tile := range dmm.Tiles {
+		vars := dmvars.Set(&dmvars.Variables{}, "name", strconv.Quote(strconv.Itoa(idx)))
+		tile.InstancesSet(dmmdata.Prefabs{
+			dmmprefab.New(dmmprefab.IdNone, "/obj", vars),
+			dmmprefab.New(dmmprefab.IdNone, "/turf", &dmvars.Variables{}),
+			dmmprefab.New(dmmprefab.IdNone, "/area", &dmvars.Variables{}),
+		})
+	}
+}
+
+func saveTwice(t *testing.T, save func(path string)) (first, second []byte) {
+	dir := t.TempDir()
+
+	save(filepath.Join(dir, "first.dmm"))
+	save(filepath.Join(dir, "second.dmm"))
+
+	first, err := os.ReadFile(filepath.Join(dir, "first.dmm"))
+	require.NoError(t, err)
+	second, err = os.ReadFile(filepath.Join(dir, "second.dmm"))
+	require.NoError(t, err)
+
+	return first, second
+}
+
+func TestSaveV_DeterministicKeys(t *testing.T) {
+	path := writeMap(t, t.TempDir(), "map.dmm", initialMap)
+	dme, dmm := loadDmm(t, path)
+	editDmm(dmm)
+
+	cfg := Config{Format: FormatDM, DeterministicKeys: true}
+	first, second := saveTwice(t, func(path string) {
+		require.NoError(t, SaveV(dme.Context(), dmm, path, cfg))
+	})
+
+	assert.NotEmpty(t, first)
+	assert.Equal(t, string(first), string(second))
+}
+
+func TestSaveV_DeterministicKeysAreLowestFree(t *testing.T) {
+	dir := t.TempDir()
+	path := writeMap(t, dir, "map.dmm", initialMap)
+	dme, dmm := loadDmm(t, path)
+	editDmm(dmm)
+
+	output := filepath.Join(dir, "output.dmm")
+	require.NoError(t, SaveV(dme.Context(), dmm, output, Config{Format: FormatDM, DeterministicKeys: true}))
+
+	data, err := dmmdata.New(output)
+	require.NoError(t, err)
+
+	// 12 unique tiles: three initial keys are reused, the rest are the lowest free keys.
+	expected := []dmmdata.Key{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l"}
+	assert.Equal(t, expected, data.Keys())
+
+	// Initial keys stay on their initial locations, the rest of the tiles get new keys in the grid order.
+	assert.Equal(t, dmmdata.Key("b"), data.Grid.Get(util.Point{X: 1, Y: 1, Z: 1}))
+	assert.Equal(t, dmmdata.Key("d"), data.Grid.Get(util.Point{X: 2, Y: 1, Z: 1}))
+	assert.Equal(t, dmmdata.Key("a"), data.Grid.Get(util.Point{X: 3, Y: 1, Z: 1}))
+	assert.Equal(t, dmmdata.Key("e"), data.Grid.Get(util.Point{X: 4, Y: 1, Z: 1}))
+	assert.Equal(t, dmmdata.Key("c"), data.Grid.Get(util.Point{X: 3, Y: 3, Z: 1}))
+}
+
+// Without deterministic keys, keys could be different, but the content of the map is the same.
+func TestSaveV_RandomKeys(t *testing.T) {
+	dir := t.TempDir()
+	path := writeMap(t, dir, "map.dmm", initialMap)
+	dme, dmm := loadDmm(t, path)
+	editDmm(dmm)
+
+	output := filepath.Join(dir, "output.dmm")
+	require.NoError(t, SaveV(dme.Context(), dmm, output, Config{Format: FormatDM}))
+
+	_, saved := loadDmm(t, output)
+	for idx, tile := range dmm.Tiles {
+		assert.Equal(t, tile.Instances().Prefabs().Hash(), saved.Tiles[idx].Instances().Prefabs().Hash())
+	}
+
+	// Unchanged content keeps its keys.
+	path = writeMap(t, dir, "unchanged.dmm", initialMap)
+	dme, dmm = loadDmm(t, path)
+	require.NoError(t, SaveV(dme.Context(), dmm, output, Config{Format: FormatDM}))
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, initialMap, string(data))
+}
+
+func TestSaveV_DeterministicKeysUnchangedMap(t *testing.T) {
+	path := writeMap(t, t.TempDir(), "map.dmm", initialMap)
+	dme, dmm := loadDmm(t, path)
+
+	cfg := Config{Format: FormatDM, DeterministicKeys: true}
+	first, second := saveTwice(t, func(path string) {
+		require.NoError(t, SaveV(dme.Context(), dmm, path, cfg))
+	})
+
+	assert.Equal(t, initialMap, string(first))
+	assert.Equal(t, string(first), string(second))
+}
+
+func TestSaveData_DeterministicKeys(t *testing.T) {
+	dir := t.TempDir()
+
+	initial, err := dmmdata.New(writeMap(t, dir, "initial.dmm", initialMap))
+	require.NoError(t, err)
+
+	// The same content under different keys and a new content, which requires a new key.
+	data, err := dmmdata.New(writeMap(t, dir, "data.dmm", `"x" = (/turf,/area)
+"y" = (/obj,/turf,/area)
+"z" = (/obj{name = "z"},/turf,/area)
+
+(1,1,1) = {"
+zzzz
+yyxx
+xyxy
+"}
+`))
+	require.NoError(t, err)
+
+	cfg := Config{Format: FormatDM, DeterministicKeys: true}
+	first, second := saveTwice(t, func(path string) {
+		require.NoError(t, SaveData(initial, data, path, cfg))
+	})
+
+	assert.Equal(t, `"a" = (/turf,/area)
+"b" = (/obj,/turf,/area)
+"c" = (/obj{name = "z"},/turf,/area)
+
+(1,1,1) = {"
+cccc
+bbaa
+abab
+"}
+`, string(first))
+	assert.Equal(t, string(first), string(second))
+}
+
+func TestSaveData_ExtractedRegion(t *testing.T) {
+	dir := t.TempDir()
+	_, dmm := loadDmm(t, writeMap(t, dir, "map.dmm", initialMap))
+
+	data, err := dmm.Extract(util.Bounds{X1: 2, Y1: 1, X2: 3, Y2: 2}, 1, 1)
+	require.NoError(t, err)
+
+	// Extracted data is a standalone map, so it's used as the initial data for itself.
+	path := filepath.Join(dir, "region.dmm")
+	require.NoError(t, SaveData(data, data, path, Config{Format: FormatDM, DeterministicKeys: true}))
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `"a" = (/obj,/turf,/area)
+"b" = (/turf,/area)
+
+(1,1,1) = {"
+bb
+ab
+"}
+`, string(content))
+}
+
+func TestSaveV_UnknownPrefabs(t *testing.T) {
+	const unknownMap = `"a" = (/turf,/area)
+"b" = (/obj/unknown{dir = 4; name = "unknown"},/turf,/area)
+
+(1,1,1) = {"
+ab
+"}
+`
+
+	path := writeMap(t, t.TempDir(), "map.dmm", unknownMap)
+	data, err := dmmdata.New(path)
+	require.NoError(t, err)
+
+	dme, _ := loadDmm(t, writeMap(t, t.TempDir(), "known.dmm", initialMap))
+	dmm, unknownPrefabs := dmmap.New(dme.Context(), data, path)
+	require.Contains(t, unknownPrefabs, "/obj/unknown")
+
+	// Unknown instances are kept on the map.
+	tile := dmm.GetTile(util.Point{X: 2, Y: 1, Z: 1})
+	require.Len(t, tile.Instances(), 3)
+
+	output := filepath.Join(t.TempDir(), "output.dmm")
+	require.NoError(t, SaveV(dme.Context(), dmm, output, Config{Format: FormatDM, SanitizeVariables: true, DeterministicKeys: true}))
+
+	content, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, unknownMap, string(content))
+}
+
+func TestSaveV_PreserveLayout(t *testing.T) {
+	const blocksMap = `//Saved by another tool
+"a" = (
+/turf,
+/area)
+"b" = (
+/obj,
+/turf,
+/area)
+
+(1,1,1) = {"
+ab
+"}
+(1,2,1) = {"ba"}
+(3,1,1) = {"
+a
+b
+"}
+`
+
+	dir := t.TempDir()
+	path := writeMap(t, dir, "map.dmm", blocksMap)
+	dme, dmm := loadDmm(t, path)
+
+	output := filepath.Join(dir, "output.dmm")
+	require.NoError(t, SaveV(dme.Context(), dmm, output, Config{DeterministicKeys: true, PreserveLayout: true}))
+	content, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, blocksMap, string(content))
+
+	// The layout of the TGM map isn't applied to the DM format.
+	require.NoError(t, SaveV(dme.Context(), dmm, output, Config{Format: FormatDM, DeterministicKeys: true, PreserveLayout: true}))
+	data, err := dmmdata.New(output)
+	require.NoError(t, err)
+	assert.Empty(t, data.Header)
+	assert.Equal(t, []dmmdata.GridBlock{{Coord: util.Point{X: 1, Y: 1, Z: 1}, Width: 3, Height: 2, EmptyLinesBefore: 1}}, data.Blocks)
+}
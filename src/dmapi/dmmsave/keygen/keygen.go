@@ -2,15 +2,12 @@ package keygen
 
 import (
 	"log"
-	"math"
 	"math/rand"
 
 	"sdmm/dmapi/dmmap/dmmdata"
 )
 
 const (
-	base float64 = 52.0
-
 	// We can only have three tiers of keys: https://secure.byond.com/forum/?post=2340796#comment23770802
 	tier1limit = 51    // a-Z
 	tier2limit = 2703  // aa-ZZ
@@ -24,13 +21,6 @@ var (
 	realTier3limit = realTier2limit + tier3limit + 1
 
 	keys = make([]dmmdata.Key, 0, realTier3limit+1)
-
-	base52keys = []rune{
-		'a', 'b', 'c', 'd', 'e', 'f', 'g', 'h', 'i', 'j', 'k', 'l', 'm',
-		'n', 'o', 'p', 'q', 'r', 's', 't', 'u', 'v', 'w', 'x', 'y', 'z',
-		'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M',
-		'N', 'O', 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z',
-	}
 )
 
 // Generate all possible keys.
@@ -42,45 +32,34 @@ func init() {
 	generateKeysRange(tier2limit+1, tier3limit, 3) // baa-ymi
 }
 
-func generateKeysRange(min, max, length int) {
-	for i := min; i <= max; i++ {
-		keys = append(keys, num2key(i, length))
-	}
-}
-
-func num2key(num, keyLength int) dmmdata.Key {
-	currentNum := num
-	var result []rune
+// MaxKeyLength is the length of keys for the last tier.
+const MaxKeyLength = dmmdata.MaxKeyLength
 
-	for {
-		result = append([]rune{base52keys[currentNum%int(base)]}, result...)
-		currentNum = int(math.Floor(float64(currentNum) / base))
-		if currentNum <= 0 {
-			break
-		}
-	}
-
-	lengthDiff := keyLength - len(result)
+// Capacity returns how many keys are available for the provided key length.
+func Capacity(keyLength int) int {
+	return dmmdata.KeyCapacity(keyLength)
+}
 
-	if lengthDiff != 0 {
-		for i := 0; i < lengthDiff; i++ {
-			result = append([]rune{'a'}, result...)
-		}
+func generateKeysRange(min, max, length int) {
+	for i := min; i <= max; i++ {
+		keys = append(keys, dmmdata.KeyFromNum(i, length))
 	}
-
-	return dmmdata.Key(result)
 }
 
 type KeyGen struct {
 	data *dmmdata.DmmData
 
+	// When true, the lowest free key is always picked instead of a random one.
+	deterministic bool
+
 	keysPool []int
 	freeKeys int
 }
 
-func New(data *dmmdata.DmmData) *KeyGen {
+func New(data *dmmdata.DmmData, deterministic bool) *KeyGen {
 	return &KeyGen{
-		data: data,
+		data:          data,
+		deterministic: deterministic,
 	}
 }
 
@@ -89,7 +68,7 @@ func (k *KeyGen) DropKeysPool() {
 	log.Println("[keygen] keys pool dropped")
 }
 
-// CreateKey generates a random key.
+// CreateKey generates a random key, or the lowest free key in the deterministic mode.
 // Returns two values: a new key and a new key length.
 // The second one will come only in the case, when there is no free keys in the keys pool with the current size.
 func (k *KeyGen) CreateKey() (dmmdata.Key, int) {
@@ -109,12 +88,16 @@ func (k *KeyGen) CreateKey() (dmmdata.Key, int) {
 		}
 	}
 
-	// Pick a random key from the pool.
-	randomIdx := rand.Intn(len(k.keysPool))
-	randomKey := k.keysPool[randomIdx]
-	k.keysPool = append(k.keysPool[:randomIdx], k.keysPool[randomIdx+1:]...)
+	// The pool is sorted, so the first key is the lowest one.
+	idx := 0
+	if !k.deterministic {
+		idx = rand.Intn(len(k.keysPool))
+	}
+
+	key := k.keysPool[idx]
+	k.keysPool = append(k.keysPool[:idx], k.keysPool[idx+1:]...)
 
-	return keys[randomKey], 0
+	return keys[key], 0
 }
 
 func createKeysPool(data *dmmdata.DmmData) (keysPool []int, freeKeys int) {
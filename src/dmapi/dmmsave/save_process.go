@@ -2,10 +2,12 @@ package dmmsave
 
 import (
 	"log"
+	"sort"
 
 	"sdmm/dmapi/dmenv"
 	"sdmm/dmapi/dmmap/dmmdata/dmmprefab"
 	"sdmm/dmapi/dmvars"
+	"sdmm/dmapi/dmvars/dmvalue"
 
 	"sdmm/dmapi/dmmap"
 	"sdmm/dmapi/dmmap/dmmdata"
@@ -15,35 +17,33 @@ import (
 
 type saveProcess struct {
 	cfg        Config
-	dme        *dmenv.Dme
-	dmm        *dmmap.Dmm
+	content    content
 	initial    *dmmdata.DmmData
 	output     *dmmdata.DmmData
 	keygen     *keygen.KeyGen
 	unusedKeys map[dmmdata.Key]bool
 }
 
-func makeSaveProcess(cfg Config, dme *dmenv.Dme, dmm *dmmap.Dmm, path string) (*saveProcess, error) {
-	// Copy the dmm to avoid unneeded modifications.
-	dmmCopy := dmm.Copy()
-	dmm = &dmmCopy
-
-	initial, err := dmmdata.New(dmm.Backup)
-	if err != nil {
-		log.Println("[dmmsave] unable to read map backup:", dmm.Backup)
-		return nil, err
-	}
+func makeSaveProcess(cfg Config, content content, initial *dmmdata.DmmData, path string) *saveProcess {
+	maxX, maxY, maxZ := content.size()
 
 	output := &dmmdata.DmmData{
 		Filepath:   path,
 		IsTgm:      detectIsTgm(cfg.Format, initial.IsTgm),
 		LineBreak:  initial.LineBreak,
 		KeyLength:  initial.KeyLength,
-		MaxX:       dmm.MaxX,
-		MaxY:       dmm.MaxY,
-		MaxZ:       dmm.MaxZ,
+		MaxX:       maxX,
+		MaxY:       maxY,
+		MaxZ:       maxZ,
 		Dictionary: make(dmmdata.DataDictionary),
-		Grid:       make(dmmdata.DataGrid),
+		Grid:       dmmdata.NewDataGrid(maxX, maxY, maxZ),
+	}
+
+	// The layout belongs to the format of the initial map. It's written only with the Config.PreserveLayout option,
+	// and only if blocks still match the grid, so the resized map is saved in the default layout.
+	if output.IsTgm == initial.IsTgm {
+		output.Header = initial.Header
+		output.Blocks = initial.Blocks
 	}
 
 	// Collect unused keys in map.
@@ -55,13 +55,12 @@ func makeSaveProcess(cfg Config, dme *dmenv.Dme, dmm *dmmap.Dmm, path string) (*
 
 	return &saveProcess{
 		cfg,
-		dme,
-		dmm,
+		content,
 		initial,
 		output,
-		keygen.New(output),
+		keygen.New(output, cfg.DeterministicKeys),
 		unusedKeys,
-	}, nil
+	}
 }
 
 func detectIsTgm(saveFormat Format, isInitialTGM bool) bool {
@@ -75,24 +74,47 @@ func detectIsTgm(saveFormat Format, isInitialTGM bool) bool {
 	}
 }
 
-func (sp *saveProcess) sanitizeVariables() {
+// Fills the output data with keys and their content.
+func (sp *saveProcess) run() error {
+	sp.handleReusedKeys()
+	return sp.handleLocationsWithoutKeys()
+}
+
+// Calls the provided function for every location of the saved map.
+func (sp *saveProcess) forEachLocation(fn func(loc util.Point)) {
+	maxX, maxY, maxZ := sp.content.size()
+	for z := 1; z <= maxZ; z++ {
+		for y := 1; y <= maxY; y++ {
+			for x := 1; x <= maxX; x++ {
+				fn(util.Point{X: x, Y: y, Z: z})
+			}
+		}
+	}
+}
+
+func sanitizeVariables(dme *dmenv.Dme, dmm *dmmap.Dmm) {
 	log.Println("[dmmsave] sanitizing variables...")
 
-	for _, tile := range sp.dmm.Tiles {
+	for _, tile := range dmm.Tiles {
 		for _, instance := range tile.Instances() {
 			prefab := instance.Prefab()
 			if prefab.Vars().Len() == 0 {
 				continue
 			}
 
-			obj := sp.dme.Objects[prefab.Path()]
+			// Prefabs of unknown types are saved "as is".
+			obj, ok := dme.Objects[prefab.Path()]
+			if !ok {
+				continue
+			}
+
 			vars := prefab.Vars()
 
 			for _, varName := range prefab.Vars().Iterate() {
 				origValue, _ := obj.Vars.Value(varName)
 				prefValue, _ := prefab.Vars().Value(varName)
 
-				if origValue == prefValue {
+				if dmvalue.Equal(origValue, prefValue) {
 					log.Println("[dmmsave] delete variable:", varName)
 					vars = dmvars.Delete(vars, varName)
 				}
@@ -112,17 +134,27 @@ func (sp *saveProcess) handleReusedKeys() {
 
 	// Cache the initial content, since we know it won't change.
 	keyByPrefabs := make(map[uint64]dmmdata.Key, len(sp.initial.Dictionary))
-	for key, prefabs := range sp.initial.Dictionary {
-		keyByPrefabs[prefabs.Hash()] = key
+	if sp.cfg.DeterministicKeys {
+		// Keys are visited in their order, so the lowest key wins when the content is duplicated.
+		for _, key := range sp.initial.Keys() {
+			prefabsHash := sp.initial.Dictionary[key].Hash()
+			if _, ok := keyByPrefabs[prefabsHash]; !ok {
+				keyByPrefabs[prefabsHash] = key
+			}
+		}
+	} else {
+		for key, prefabs := range sp.initial.Dictionary {
+			keyByPrefabs[prefabs.Hash()] = key
+		}
 	}
 
-	for _, tile := range sp.dmm.Tiles {
-		prefabs := tile.Instances().Sorted().Prefabs()
-		if initialKey, ok := findKeyByTileContent(sp.initial, keyByPrefabs, prefabs); ok {
-			sp.setOutputKeyContent(tile.Coord, initialKey, prefabs)
+	sp.forEachLocation(func(loc util.Point) {
+		prefabs := sp.content.prefabs(loc)
+		if initialKey, ok := sp.findKeyByTileContent(sp.initial, keyByPrefabs, prefabs); ok {
+			sp.setOutputKeyContent(loc, initialKey, prefabs)
 			delete(sp.unusedKeys, initialKey)
 		}
-	}
+	})
 
 	log.Println("[dmmsave] remaining count of unused keys:", len(sp.unusedKeys))
 }
@@ -136,13 +168,13 @@ func (sp *saveProcess) handleLocationsWithoutKeys() error {
 
 	log.Println("[dmmsave] count of locations without keys:", len(locsWithoutKey))
 
-	sp.tryToReuseKeysByTheirInitialLocation(locsWithoutKey)
+	locsWithoutKey = sp.tryToReuseKeysByTheirInitialLocation(locsWithoutKey)
 
 	switch sp.fillLocations(locsWithoutKey) {
 	case errorRegenerateKeys:
 		sp.keygen.DropKeysPool()
 		sp.output.Dictionary = make(dmmdata.DataDictionary)
-		sp.output.Grid = make(dmmdata.DataGrid)
+		sp.output.Grid = dmmdata.NewDataGrid(sp.output.MaxX, sp.output.MaxY, sp.output.MaxZ)
 		sp.unusedKeys = nil
 		return sp.handleLocationsWithoutKeys()
 	case errorKeysLimitExceeded:
@@ -152,71 +184,96 @@ func (sp *saveProcess) handleLocationsWithoutKeys() error {
 	return nil
 }
 
-func (sp *saveProcess) findLocationsWithoutKey() map[util.Point]bool {
-	locsWithoutKey := make(map[util.Point]bool)
+// Returns locations without keys in the grid order.
+func (sp *saveProcess) findLocationsWithoutKey() []util.Point {
+	var locsWithoutKey []util.Point
 
-	for z := 1; z <= sp.dmm.MaxZ; z++ {
-		for y := 1; y <= sp.dmm.MaxY; y++ {
-			for x := 1; x <= sp.dmm.MaxX; x++ {
-				loc := util.Point{X: x, Y: y, Z: z}
-				if _, ok := sp.output.Grid[loc]; !ok {
-					locsWithoutKey[loc] = true
-				}
-			}
+	sp.forEachLocation(func(loc util.Point) {
+		if sp.output.Grid.Get(loc) == "" {
+			locsWithoutKey = append(locsWithoutKey, loc)
 		}
-	}
+	})
 
 	return locsWithoutKey
 }
 
 // Try to find the most appropriate place of all unused keys.
 // Appropriate means that the initial map has the same key by the same location.
-func (sp *saveProcess) tryToReuseKeysByTheirInitialLocation(locsWithoutKey map[util.Point]bool) {
+// Returns locations which are still without keys.
+func (sp *saveProcess) tryToReuseKeysByTheirInitialLocation(locsWithoutKey []util.Point) []util.Point {
 	if len(sp.unusedKeys) == 0 {
-		return
+		return locsWithoutKey
 	}
 
 	log.Println("[dmmsave] trying to match unused keys with its previous location...")
 
-	// Copy to modify the original map safely during its iteration.
-	unusedKeysCpy := make(map[dmmdata.Key]bool)
-	for key := range sp.unusedKeys {
-		unusedKeysCpy[key] = true
+	locs := make(map[util.Point]bool, len(locsWithoutKey))
+	for _, loc := range locsWithoutKey {
+		locs[loc] = true
 	}
 
 	// Content can be the same for different locations, so we will remember an unusedKey we applied to locs.
 	keyByPrefabs := make(map[uint64]dmmdata.Key)
 
-	for unusedKey := range unusedKeysCpy {
-		for loc := range locsWithoutKey {
-			prefabs := sp.dmm.GetTile(loc).Instances().Sorted().Prefabs()
+	for _, unusedKey := range sp.orderedUnusedKeys() {
+		for _, loc := range locsWithoutKey {
+			if !locs[loc] {
+				continue
+			}
+
+			prefabs := sp.content.prefabs(loc)
 			prefabsHash := prefabs.Hash()
 
 			// If the key was already applied to the content in a previous iteration.
 			if cachedKey, ok := keyByPrefabs[prefabsHash]; ok {
-				sp.output.Grid[loc] = cachedKey
+				sp.output.Grid.Set(loc, cachedKey)
 				continue
 			}
 
-			if sp.initial.Grid[loc] == unusedKey {
+			if sp.initial.Grid.Get(loc) == unusedKey {
 				keyByPrefabs[prefabsHash] = unusedKey
 
 				sp.setOutputKeyContent(loc, unusedKey, prefabs)
 
 				delete(sp.unusedKeys, unusedKey)
-				delete(locsWithoutKey, loc)
+				delete(locs, loc)
 
 				break
 			}
 		}
 	}
 
+	// Keep the grid order of remaining locations.
+	remainingLocs := make([]util.Point, 0, len(locs))
+	for _, loc := range locsWithoutKey {
+		if locs[loc] {
+			remainingLocs = append(remainingLocs, loc)
+		}
+	}
+
 	log.Println("[dmmsave] remaining count of unused keys:", len(sp.unusedKeys))
-	log.Println("[dmmsave] count of locations without keys:", len(locsWithoutKey))
+	log.Println("[dmmsave] count of locations without keys:", len(remainingLocs))
+
+	return remainingLocs
+}
+
+// Returns unused keys from the lowest one with deterministic keys.
+// Otherwise, keys are in the order of the unused keys map, so the result may differ from save to save.
+func (sp *saveProcess) orderedUnusedKeys() []dmmdata.Key {
+	unusedKeys := make([]dmmdata.Key, 0, len(sp.unusedKeys))
+	for key := range sp.unusedKeys {
+		unusedKeys = append(unusedKeys, key)
+	}
+	if sp.cfg.DeterministicKeys {
+		sort.Slice(unusedKeys, func(i, j int) bool {
+			return unusedKeys[i].ToNum() < unusedKeys[j].ToNum()
+		})
+	}
+	return unusedKeys
 }
 
 // File all locations without keys with the key and the content.
-func (sp *saveProcess) fillLocations(locsWithoutKey map[util.Point]bool) error {
+func (sp *saveProcess) fillLocations(locsWithoutKey []util.Point) error {
 	log.Println("[dmmsave] handling remaining locations...")
 
 	// For logs.
@@ -227,19 +284,18 @@ func (sp *saveProcess) fillLocations(locsWithoutKey map[util.Point]bool) error {
 
 	keyByPrefabs := make(map[uint64]dmmdata.Key)
 
-	for loc := range locsWithoutKey {
-		prefabs := sp.dmm.GetTile(loc).Instances().Sorted().Prefabs()
+	unusedKeys := sp.orderedUnusedKeys()
+
+	for _, loc := range locsWithoutKey {
+		prefabs := sp.content.prefabs(loc)
 
 		var key dmmdata.Key
-		if reusableKey, ok := findKeyByTileContent(sp.output, keyByPrefabs, prefabs); ok {
+		if reusableKey, ok := sp.findKeyByTileContent(sp.output, keyByPrefabs, prefabs); ok {
 			key = reusableKey
-		} else if len(sp.unusedKeys) != 0 {
-			for unusedKey := range sp.unusedKeys { // Pick up the first available key.
-				key = unusedKey
-				delete(sp.unusedKeys, unusedKey)
-				reusedKeys = append(reusedKeys, key)
-				break
-			}
+		} else if len(unusedKeys) != 0 {
+			key, unusedKeys = unusedKeys[0], unusedKeys[1:]
+			delete(sp.unusedKeys, key)
+			reusedKeys = append(reusedKeys, key)
 		} else {
 			var newSize int
 			if key, newSize = sp.keygen.CreateKey(); newSize != 0 {
@@ -264,11 +320,13 @@ func (sp *saveProcess) fillLocations(locsWithoutKey map[util.Point]bool) error {
 }
 
 func (sp *saveProcess) setOutputKeyContent(loc util.Point, key dmmdata.Key, prefabs dmmdata.Prefabs) {
-	sp.output.Grid[loc] = key
+	sp.output.Grid.Set(loc, key)
 	sp.output.Dictionary[key] = prefabs
 }
 
-func findKeyByTileContent(
+// Returns a key with the same content.
+// With deterministic keys the lowest one is returned, if there are several of them. Otherwise, the first found.
+func (sp *saveProcess) findKeyByTileContent(
 	data *dmmdata.DmmData,
 	keyByPrefabs map[uint64]dmmdata.Key,
 	prefabs dmmdata.Prefabs,
@@ -279,12 +337,30 @@ func findKeyByTileContent(
 		return key, true
 	}
 
+	if !sp.cfg.DeterministicKeys {
+		for key, dataContent := range data.Dictionary {
+			if prefabs.Equals(dataContent) {
+				keyByPrefabs[contentHash] = key
+				return key, true
+			}
+		}
+		return "", false
+	}
+
+	var (
+		foundKey dmmdata.Key
+		found    bool
+	)
+
 	for key, dataContent := range data.Dictionary {
-		if prefabs.Equals(dataContent) {
-			keyByPrefabs[contentHash] = key
-			return key, true
+		if prefabs.Equals(dataContent) && (!found || key.ToNum() < foundKey.ToNum()) {
+			foundKey, found = key, true
 		}
 	}
 
-	return "", false
+	if found {
+		keyByPrefabs[contentHash] = foundKey
+	}
+
+	return foundKey, found
 }
@@ -3,18 +3,27 @@ package dmmsnap
 import (
 	"log"
 	"strings"
+	"sync"
+	"time"
 
 	"sdmm/dmapi/dmmap"
 	"sdmm/dmapi/dmmap/dmmdata"
 	"sdmm/util"
 )
 
-type dmmPatch []tilePatch
+type dmmPatch struct {
+	name  string
+	time  time.Time
+	tiles []tilePatch
+}
 
 // DmmSnap is a structure to store map states in the different moments of time.
 // It stores patches between different map states, so UNDO/REDO operations simplified to the "apply patch operation".
-// TODO: Possible to implement local VCS history.
+//
+// Commits are usually done in a background goroutine, so all snapshot operations are serialized.
 type DmmSnap struct {
+	mu sync.Mutex
+
 	// Initial map is a state before changes. Basically, it's a copy of the current map, which is modified.
 	// When we commit changes with Commit method we compare and collect differences between unmodifiable and
 	// modifiable states. Those differences are collected into patches.
@@ -23,6 +32,10 @@ type DmmSnap struct {
 	stateId int
 
 	patches []dmmPatch
+
+	// Patches can't be persisted when the snapshot was fully synced during the session,
+	// since the map was changed without patches (e.g. the map size was changed).
+	unpersistable bool
 }
 
 func New(current *dmmap.Dmm) *DmmSnap {
@@ -32,10 +45,16 @@ func New(current *dmmap.Dmm) *DmmSnap {
 }
 
 func (d *DmmSnap) Sync() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.unpersistable = true
 	d.syncInitialWithCurrent()
 }
 
+// Initial returns the initial map state. Since it's modified by commits, it should be only copied.
 func (d *DmmSnap) Initial() *dmmap.Dmm {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	return d.initial
 }
 
@@ -44,17 +63,39 @@ func (d *DmmSnap) Current() *dmmap.Dmm {
 }
 
 // Commit creates a patch with the map changes between two snapshot states.
+// The name is a readable description of changes, which is kept with the patch.
 // stateId is an integer value, which can be used in the future to iterate snapshot to the specific state.
-func (d *DmmSnap) Commit() (int, []util.Point) {
+func (d *DmmSnap) Commit(name string) (int, []util.Point) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	log.Println("[snapshot] committing snapshot state...")
 
 	var tilePatches []tilePatch
 
-	// Iterate through the current tiles state.
-	for _, currentTile := range d.current.Tiles {
-		initialTile := d.initial.GetTile(currentTile.Coord)
+	// Only tiles modified since the previous commit are compared.
+	coords, all := d.current.Journal().Flush()
+	if all {
+		log.Println("[snapshot] whole map is modified, comparing all tiles...")
+		tiles := d.current.AllTiles()
+		coords = make([]util.Point, 0, len(tiles))
+		for _, tile := range tiles {
+			coords = append(coords, tile.Coord)
+		}
+	}
+
+	for _, coord := range coords {
+		// The map size could be changed after the flush. The next sync will handle such tiles.
+		currTile, ok := d.current.GetTileV(coord)
+		if !ok {
+			continue
+		}
+		initialTile, ok := d.initial.GetTileV(coord)
+		if !ok {
+			continue
+		}
 
-		currInstances := currentTile.Instances()
+		currInstances := currTile.Instances()
 		initialInstances := initialTile.Instances()
 
 		// If tiles contents have different length, then they are different for sure.
@@ -70,7 +111,7 @@ func (d *DmmSnap) Commit() (int, []util.Point) {
 		}
 
 		tilePatches = append(tilePatches, tilePatch{
-			coord:    currentTile.Coord,
+			coord:    coord,
 			backward: initialInstances.Prefabs(),
 			forward:  currInstances.Prefabs(),
 		})
@@ -83,7 +124,7 @@ func (d *DmmSnap) Commit() (int, []util.Point) {
 		log.Println("[snapshot] collected snapshot patches count:", len(tilePatches))
 
 		// Drop all patches, if their stateId is more than the current one.
-		d.patches = append(d.patches[:d.stateId], tilePatches)
+		d.patches = append(d.patches[:d.stateId], dmmPatch{name: name, time: time.Now(), tiles: tilePatches})
 		// Apply created patch to the initial state, so it will be synced with the current one.
 		d.patchState(d.stateId, true, patchInitial)
 		// Update stateId to a new value.
@@ -103,6 +144,9 @@ func (d *DmmSnap) Commit() (int, []util.Point) {
 
 // GoTo will update DmmSnap state by applying patches.
 func (d *DmmSnap) GoTo(stateId int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	log.Println("[snapshot] changing snapshot state to:", stateId)
 	d.goTo(stateId, patchFull)
 }
@@ -144,7 +188,7 @@ func (p patchType) String() string {
 
 func (d *DmmSnap) patchState(stateId int, isForward bool, patchType patchType) {
 	log.Printf("[snapshot] patching:[%d], forward:[%t], type:[%s]", stateId, isForward, patchType)
-	for _, patch := range d.patches[stateId] {
+	for _, patch := range d.patches[stateId].tiles {
 		var prefabs dmmdata.Prefabs
 		if isForward {
 			prefabs = patch.forward
@@ -168,20 +212,11 @@ func (d *DmmSnap) patchState(stateId int, isForward bool, patchType patchType) {
 func (d *DmmSnap) syncInitialWithCurrent() {
 	log.Println("[snapshot] syncing initial state with the current...")
 
-	d.initial = &dmmap.Dmm{
-		Name: d.current.Name,
-		Path: d.current.Path,
-		MaxX: d.current.MaxX,
-		MaxY: d.current.MaxY,
-		MaxZ: d.current.MaxZ,
-	}
+	// States are equal after the sync, so there is nothing to compare on the next commit.
+	d.current.Journal().Flush()
 
 	// Do a full copy of tiles from the current map state to the initial.
-	d.initial.Tiles = make([]*dmmap.Tile, 0, len(d.current.Tiles))
-	for _, tile := range d.current.Tiles {
-		tileCopy := tile.Copy()
-		d.initial.Tiles = append(d.initial.Tiles, &tileCopy)
-	}
+	d.initial = d.current.Copy()
 
 	log.Println("[snapshot] initial state synced with the current")
 }
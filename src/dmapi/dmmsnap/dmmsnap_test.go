@@ -0,0 +1,299 @@
+package dmmsnap
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"sdmm/dmapi/dmenv"
+	"sdmm/dmapi/dmmap"
+	"sdmm/dmapi/dmmap/dmmdata"
+	"sdmm/dmapi/dmmap/dmmdata/dmmprefab"
+	"sdmm/dmapi/dmvars"
+	"sdmm/util"
+)
+
+const testMap = `"a" = (/turf,/area)
+
+(1,1,1) = {"
+aa
+aa
+"}
+`
+
+func loadDmm(t testing.TB) *dmmap.Dmm {
+	return loadDmmContent(t, testMap)
+}
+
+func loadDmmContent(t testing.TB, content string) *dmmap.Dmm {
+	path := filepath.Join(t.TempDir(), "test.dmm")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	data, err := dmmdata.New(path)
+	require.NoError(t, err)
+
+	dme := &dmenv.Dme{
+		RootDir: filepath.Dir(path),
+		Objects: map[string]*dmenv.Object{
+			"/turf": {Path: "/turf", Vars: &dmvars.Variables{}},
+			"/area": {Path: "/area", Vars: &dmvars.Variables{}},
+			"/obj":  {Path: "/obj", Vars: &dmvars.Variables{}},
+		},
+	}
+
+	dmm, _ := dmmap.New(dme.Context(), data, path)
+	return dmm
+}
+
+// Makes a square map filled with the same tile.
+func makeMap(size int) string {
+	var sb strings.Builder
+	sb.WriteString("\"a\" = (/turf,/area)\n\n(1,1,1) = {\"\n")
+	row := strings.Repeat("a", size) + "\n"
+	for y := 0; y < size; y++ {
+		sb.WriteString(row)
+	}
+	sb.WriteString("\"}\n")
+	return sb.String()
+}
+
+func objPrefab(name string) *dmmprefab.Prefab {
+	return dmmprefab.New(dmmprefab.IdNone, "/obj", dmvars.Set(&dmvars.Variables{}, "name", `"`+name+`"`))
+}
+
+func addObj(dmm *dmmap.Dmm, coord util.Point, name string) {
+	dmm.GetTile(coord).InstancesAdd(objPrefab(name))
+}
+
+func tileNames(dmm *dmmap.Dmm, coord util.Point) (names []string) {
+	for _, instance := range dmm.GetTile(coord).Instances() {
+		names = append(names, instance.Prefab().Vars().ValueV("name", instance.Prefab().Path()))
+	}
+	return names
+}
+
+func TestCommitJournal(t *testing.T) {
+	dmm := loadDmm(t)
+	snap := New(dmm)
+
+	// Nothing is modified.
+	_, tilesToUpdate := snap.Commit("Nothing")
+	assert.Empty(t, tilesToUpdate)
+
+	// Modification through the tile.
+	addObj(dmm, util.Point{X: 2, Y: 1, Z: 1}, "tile")
+	_, tilesToUpdate = snap.Commit("Tile")
+	assert.Equal(t, []util.Point{{X: 2, Y: 1, Z: 1}}, tilesToUpdate)
+
+	// Modification through the instance.
+	dmm.GetTile(util.Point{X: 1, Y: 2, Z: 1}).Instances()[0].SetPrefab(objPrefab("instance"))
+	_, tilesToUpdate = snap.Commit("Instance")
+	assert.Equal(t, []util.Point{{X: 1, Y: 2, Z: 1}}, tilesToUpdate)
+
+	// Copies are detached from the map.
+	tileCopy := dmm.GetTile(util.Point{X: 1, Y: 1, Z: 1}).Copy()
+	tileCopy.InstancesAdd(objPrefab("copy"))
+	_, tilesToUpdate = snap.Commit("Copy")
+	assert.Empty(t, tilesToUpdate)
+
+	// Undo/redo doesn't produce new changes.
+	snap.GoTo(1)
+	_, tilesToUpdate = snap.Commit("Undo")
+	assert.Empty(t, tilesToUpdate)
+}
+
+func TestCommitAfterMapSizeChange(t *testing.T) {
+	dmm := loadDmm(t)
+	snap := New(dmm)
+
+	dmm.SetMapSize(3, 2, 1)
+	snap.Sync()
+
+	addObj(dmm, util.Point{X: 3, Y: 2, Z: 1}, "new")
+	_, tilesToUpdate := snap.Commit("New Tile")
+	assert.Equal(t, []util.Point{{X: 3, Y: 2, Z: 1}}, tilesToUpdate)
+}
+
+// Edits are done by the UI thread, while commits are done in background goroutines.
+// Should be run with the race detector to make sense.
+func TestCommitConcurrentEdits(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	const mapSize, edits, committers = 2, 1000, 4
+
+	dmm := loadDmmContent(t, makeMap(mapSize))
+	snap := New(dmm)
+	initialContent := mapContent(dmm)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+
+	for i := 0; i < committers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					snap.Commit("Background")
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < edits; i++ {
+		coord := util.Point{X: i%mapSize + 1, Y: i/mapSize%mapSize + 1, Z: 1}
+		tile := dmm.GetTile(coord)
+		switch i % 3 {
+		case 0:
+			addObj(dmm, coord, strconv.Itoa(i))
+		case 1:
+			tile.Instances()[0].SetPrefab(dmm.Context().Prefabs.Get("/obj", dmvars.Set(&dmvars.Variables{}, "name", strconv.Itoa(i))))
+		case 2:
+			tile.InstancesRemoveByPath("/obj")
+			tile.InstancesSet(dmmdata.Prefabs{objPrefab(strconv.Itoa(i)), dmm.Context().BaseTurf, dmm.Context().BaseArea})
+		}
+
+		// Same as the editor does.
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			snap.Commit("Edit")
+		}()
+	}
+
+	close(done)
+	wg.Wait()
+
+	stateId, _ := snap.Commit("Final")
+	editedContent := mapContent(dmm)
+
+	// Patches should describe the whole way between the initial and the edited content.
+	snap.GoTo(0)
+	assert.Equal(t, initialContent, mapContent(dmm))
+	snap.GoTo(stateId)
+	assert.Equal(t, editedContent, mapContent(dmm))
+
+	_, tilesToUpdate := snap.Commit("Nothing")
+	assert.Empty(t, tilesToUpdate)
+}
+
+// The index of the map should be consistent with the map content after undo and redo.
+func TestIndexAfterUndoRedo(t *testing.T) {
+	dmm := loadDmmContent(t, makeMap(4))
+	snap := New(dmm)
+
+	prefabs := []*dmmprefab.Prefab{dmm.Context().Prefabs.Initial("/turf"), dmm.Context().Prefabs.Initial("/area")}
+	for i := 0; i < 4; i++ {
+		vars := dmvars.Set(&dmvars.Variables{}, "name", strconv.Quote(strconv.Itoa(i)))
+		prefabs = append(prefabs, dmm.Context().Prefabs.Get("/obj", vars))
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+
+	states := []map[uint64][]util.Point{scanPrefabCoords(dmm)}
+	for i := 0; i < 20; i++ {
+		for n := 0; n < 5; n++ {
+			tile := dmm.GetTile(util.Point{X: rnd.Intn(4) + 1, Y: rnd.Intn(4) + 1, Z: 1})
+			if instances := tile.Instances(); rnd.Intn(3) == 0 && len(instances) != 0 {
+				tile.InstancesRemoveByInstance(instances[rnd.Intn(len(instances))])
+			} else {
+				tile.InstancesAdd(prefabs[rnd.Intn(len(prefabs))])
+			}
+		}
+		snap.Commit("Edit")
+		states = append(states, scanPrefabCoords(dmm))
+		assertIndex(t, dmm, prefabs, states[len(states)-1])
+	}
+
+	// Undo step by step.
+	for stateId := len(states) - 1; stateId >= 0; stateId-- {
+		snap.GoTo(stateId)
+		assertIndex(t, dmm, prefabs, states[stateId])
+	}
+
+	// Redo step by step.
+	for stateId := range states {
+		snap.GoTo(stateId)
+		assertIndex(t, dmm, prefabs, states[stateId])
+	}
+}
+
+func scanPrefabCoords(dmm *dmmap.Dmm) map[uint64][]util.Point {
+	coords := make(map[uint64][]util.Point)
+	for _, tile := range dmm.AllTiles() {
+		for _, instance := range tile.Instances() {
+			prefabCoords := coords[instance.Prefab().Id()]
+			if len(prefabCoords) == 0 || prefabCoords[len(prefabCoords)-1] != tile.Coord {
+				coords[instance.Prefab().Id()] = append(prefabCoords, tile.Coord)
+			}
+		}
+	}
+	return coords
+}
+
+func assertIndex(t *testing.T, dmm *dmmap.Dmm, prefabs []*dmmprefab.Prefab, expected map[uint64][]util.Point) {
+	t.Helper()
+
+	// The map is scanned tile by tile, so the order of coords is the same as in the index.
+	assert.Equal(t, expected, scanPrefabCoords(dmm))
+	for _, prefab := range prefabs {
+		assert.Equal(t, expected[prefab.Id()], dmm.Index().CoordsByPrefabId(prefab.Id()))
+	}
+
+	for _, tile := range dmm.AllTiles() {
+		for _, instance := range tile.Instances() {
+			coord, ok := dmm.Index().InstanceCoord(instance.Id())
+			assert.True(t, ok)
+			assert.Equal(t, tile.Coord, coord)
+		}
+	}
+}
+
+func mapContent(dmm *dmmap.Dmm) (content [][]string) {
+	for _, tile := range dmm.AllTiles() {
+		content = append(content, tileNames(dmm, tile.Coord))
+	}
+	return content
+}
+
+// Commit cost should depend on the number of modified tiles, not on the map size.
+func BenchmarkCommit(b *testing.B) {
+	// Commits are logged, which would affect the result.
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	for _, mapSize := range []int{32, 256} {
+		for _, editSize := range []int{1, 32} {
+			b.Run(fmt.Sprintf("map=%dx%d/edit=%d", mapSize, mapSize, editSize), func(b *testing.B) {
+				dmm := loadDmmContent(b, makeMap(mapSize))
+				snap := New(dmm)
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					b.StopTimer()
+					prefabs := dmmdata.Prefabs{objPrefab(strconv.Itoa(i)), dmm.Context().Prefabs.Initial("/turf"), dmm.Context().Prefabs.Initial("/area")}
+					for n := 0; n < editSize; n++ {
+						dmm.GetTile(util.Point{X: n + 1, Y: 1, Z: 1}).InstancesSet(prefabs)
+					}
+					b.StartTimer()
+
+					snap.Commit("Benchmark")
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package dmmsnap
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"sdmm/dmapi/dmenv"
+	"sdmm/dmapi/dmmap/dmmdata"
+	"sdmm/dmapi/dmvars"
+	"sdmm/util"
+)
+
+// History is a serializable form of the snapshot patches.
+// It's used to keep the undo/redo stack of the map between the editor sessions.
+type History struct {
+	// StateId is the snapshot state of the map content, for which the history was taken.
+	StateId int
+	Patches []Patch
+}
+
+// Patch is a serializable form of changes made by a single commit.
+type Patch struct {
+	Name  string
+	Time  time.Time
+	Tiles []TilePatch
+}
+
+// TilePatch stores the tile content before and after the commit.
+type TilePatch struct {
+	X, Y, Z int
+
+	Backward []Prefab
+	Forward  []Prefab
+}
+
+// Prefab stores a prefab path with its own variables. Variables are stored in the declaration order.
+type Prefab struct {
+	Path string
+	Vars []Var
+}
+
+type Var struct {
+	Name  string
+	Value string
+}
+
+// History returns the serializable form of the snapshot patches.
+// The second value is false, if patches of the snapshot can't be persisted.
+// That happens when the snapshot was fully synced, so patches don't describe the whole map history.
+func (d *DmmSnap) History() (History, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.unpersistable {
+		return History{}, false
+	}
+
+	history := History{
+		StateId: d.stateId,
+		Patches: make([]Patch, 0, len(d.patches)),
+	}
+
+	for _, patch := range d.patches {
+		tiles := make([]TilePatch, 0, len(patch.tiles))
+		for _, tile := range patch.tiles {
+			tiles = append(tiles, TilePatch{
+				X:        tile.coord.X,
+				Y:        tile.coord.Y,
+				Z:        tile.coord.Z,
+				Backward: toHistoryPrefabs(tile.backward),
+				Forward:  toHistoryPrefabs(tile.forward),
+			})
+		}
+		history.Patches = append(history.Patches, Patch{Name: patch.name, Time: patch.time, Tiles: tiles})
+	}
+
+	return history, true
+}
+
+// RestoreHistory replaces patches of the snapshot with patches from the provided history.
+// The current map state is considered as the state with the history stateId.
+// It's possible to restore history only for a snapshot without commits.
+func (d *DmmSnap) RestoreHistory(history History) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.patches) != 0 {
+		return fmt.Errorf("unable to restore history for a snapshot with commits")
+	}
+	if history.StateId < 0 || history.StateId > len(history.Patches) {
+		return fmt.Errorf("invalid history state: %d", history.StateId)
+	}
+
+	ctx := d.current.Context()
+
+	patches := make([]dmmPatch, 0, len(history.Patches))
+	for _, patch := range history.Patches {
+		tiles := make([]tilePatch, 0, len(patch.Tiles))
+		for _, tile := range patch.Tiles {
+			coord := util.Point{X: tile.X, Y: tile.Y, Z: tile.Z}
+			if !d.current.HasTile(coord) {
+				return fmt.Errorf("invalid history tile: %v", coord)
+			}
+			tiles = append(tiles, tilePatch{
+				coord:    coord,
+				backward: fromHistoryPrefabs(ctx, tile.Backward),
+				forward:  fromHistoryPrefabs(ctx, tile.Forward),
+			})
+		}
+		patches = append(patches, dmmPatch{name: patch.Name, time: patch.Time, tiles: tiles})
+	}
+
+	d.patches = patches
+	d.stateId = history.StateId
+
+	log.Printf("[snapshot] history restored; patches:[%d], state:[%d]", len(d.patches), d.stateId)
+	return nil
+}
+
+// PatchName returns the name of the commit, which has created the provided state.
+func (d *DmmSnap) PatchName(stateId int) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.patches[stateId-1].name
+}
+
+// PatchTime returns the moment of the commit, which has created the provided state.
+func (d *DmmSnap) PatchTime(stateId int) time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.patches[stateId-1].time
+}
+
+// PatchCoords returns coords of tiles modified by the commit, which has created the provided state.
+func (d *DmmSnap) PatchCoords(stateId int) []util.Point {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	tiles := d.patches[stateId-1].tiles
+	coords := make([]util.Point, 0, len(tiles))
+	for _, tile := range tiles {
+		coords = append(coords, tile.coord)
+	}
+	return coords
+}
+
+func toHistoryPrefabs(prefabs dmmdata.Prefabs) []Prefab {
+	result := make([]Prefab, 0, len(prefabs))
+	for _, prefab := range prefabs {
+		vars := make([]Var, 0, prefab.Vars().Len())
+		for _, name := range prefab.Vars().Iterate() {
+			value, _ := prefab.Vars().Value(name)
+			vars = append(vars, Var{Name: name, Value: value})
+		}
+		result = append(result, Prefab{Path: prefab.Path(), Vars: vars})
+	}
+	return result
+}
+
+// Prefabs are linked with the environment objects in the same way as prefabs read from the map.
+func fromHistoryPrefabs(ctx *dmenv.Context, prefabs []Prefab) dmmdata.Prefabs {
+	result := make(dmmdata.Prefabs, 0, len(prefabs))
+	for _, prefab := range prefabs {
+		vars := &dmvars.MutableVariables{}
+		for _, v := range prefab.Vars {
+			vars.Put(v.Name, v.Value)
+		}
+
+		immutableVars := vars.ToImmutable()
+		if objectVars, ok := ctx.Env().ObjectVars(prefab.Path); ok {
+			immutableVars.LinkParent(objectVars)
+		}
+
+		result = append(result, ctx.Prefabs.Get(prefab.Path, immutableVars))
+	}
+	return result
+}
@@ -0,0 +1,75 @@
+package dmmsnap
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"sdmm/util"
+)
+
+func TestHistoryRoundTrip(t *testing.T) {
+	coord := util.Point{X: 1, Y: 2, Z: 1}
+
+	dmm := loadDmm(t)
+	snap := New(dmm)
+
+	addObj(dmm, coord, "first")
+	stateId, _ := snap.Commit("Add First")
+	assert.Equal(t, 1, stateId)
+
+	addObj(dmm, coord, "second")
+	stateId, _ = snap.Commit("Add Second")
+	assert.Equal(t, 2, stateId)
+
+	snap.GoTo(1) // The second commit is only available to redo.
+
+	history, ok := snap.History()
+	require.True(t, ok)
+
+	// The history should survive serialization.
+	data, err := json.Marshal(history)
+	require.NoError(t, err)
+	var restored History
+	require.NoError(t, json.Unmarshal(data, &restored))
+	restoredData, err := json.Marshal(restored)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(data), string(restoredData))
+
+	// Emulate reopening of the map saved in the first state.
+	reopened := loadDmm(t)
+	addObj(reopened, coord, "first")
+
+	reopenedSnap := New(reopened)
+	require.NoError(t, reopenedSnap.RestoreHistory(restored))
+	assert.Equal(t, "Add First", reopenedSnap.PatchName(1))
+	assert.Equal(t, "Add Second", reopenedSnap.PatchName(2))
+	assert.True(t, history.Patches[1].Time.Equal(reopenedSnap.PatchTime(2)))
+	assert.Equal(t, []util.Point{coord}, reopenedSnap.PatchCoords(2))
+
+	reopenedSnap.GoTo(0)
+	assert.Equal(t, []string{"/turf", "/area"}, tileNames(reopened, coord))
+
+	reopenedSnap.GoTo(2)
+	assert.Equal(t, []string{"/turf", "/area", `"first"`, `"second"`}, tileNames(reopened, coord))
+}
+
+func TestHistoryNotPersistableAfterSync(t *testing.T) {
+	snap := New(loadDmm(t))
+	snap.Sync()
+
+	_, ok := snap.History()
+	assert.False(t, ok)
+}
+
+func TestRestoreHistoryInvalid(t *testing.T) {
+	snap := New(loadDmm(t))
+
+	assert.Error(t, snap.RestoreHistory(History{StateId: 1}))
+	assert.Error(t, snap.RestoreHistory(History{
+		StateId: 1,
+		Patches: []Patch{{Name: "Out Of Bounds", Tiles: []TilePatch{{X: 3, Y: 1, Z: 1}}}},
+	}))
+}
@@ -0,0 +1,203 @@
+package dmmstats
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"sdmm/dmapi/dm"
+	"sdmm/dmapi/dmmap"
+	"sdmm/dmapi/dmmsave/keygen"
+)
+
+// Stats is a report about the content of the map.
+type Stats struct {
+	Map string `json:"map"`
+
+	MaxX int `json:"max_x"`
+	MaxY int `json:"max_y"`
+	MaxZ int `json:"max_z"`
+
+	Tiles     int `json:"tiles"`
+	Instances int `json:"instances"`
+
+	// UniquePrefabs is a number of different prefabs (a type path with variables) on the map.
+	UniquePrefabs int `json:"unique_prefabs"`
+	// VarEditedInstances is a number of instances with variables set on the map.
+	VarEditedInstances int `json:"var_edited_instances"`
+
+	Keys Keys `json:"keys"`
+
+	// Paths are sorted by the type path.
+	Paths []PathCount `json:"paths"`
+	// Areas are sorted by the area type path.
+	Areas []Area `json:"areas"`
+}
+
+// PathCount is a number of instances of the type path.
+type PathCount struct {
+	Path string `json:"path"`
+	// Count is a number of instances of the exact type.
+	Count int `json:"count"`
+	// Total is a number of instances of the type and all its subtypes.
+	Total int `json:"total"`
+}
+
+// Keys shows how many keys are needed to save the map, compared with limits of the key tiers.
+type Keys struct {
+	// Count is a number of unique tile contents. Every one of them needs its own key.
+	Count int `json:"count"`
+	// KeyLength is the smallest key length which has enough keys for the map.
+	KeyLength int `json:"key_length"`
+	// Capacity is a number of keys available for the key length.
+	Capacity int `json:"capacity"`
+	// Exceeded is true when there are more unique tiles than the last tier has keys. Such map can't be saved.
+	Exceeded bool `json:"exceeded"`
+}
+
+// Area is a breakdown of tiles with the area type.
+type Area struct {
+	Path  string `json:"path"`
+	Tiles int    `json:"tiles"`
+	// Instances is a number of instances on tiles of the area, without the area itself.
+	Instances int         `json:"instances"`
+	Paths     []PathCount `json:"paths"`
+}
+
+// Collect gathers statistics of the provided map.
+// Tiles are read with the map lock, so it's safe to call from any goroutine.
+func Collect(dmm *dmmap.Dmm) *Stats {
+	start := time.Now()
+
+	stats := &Stats{
+		Map:  dmm.Name,
+		MaxX: dmm.MaxX,
+		MaxY: dmm.MaxY,
+		MaxZ: dmm.MaxZ,
+	}
+
+	pathsCount := make(map[string]int)
+	prefabs := make(map[uint64]bool)
+	contents := make(map[uint64]bool)
+
+	areas := make(map[string]*Area)
+	areasPathsCount := make(map[string]map[string]int)
+
+	for _, tile := range dmm.AllTiles() {
+		instances := tile.Instances()
+
+		stats.Tiles++
+		stats.Instances += len(instances)
+		contents[instances.Sorted().Prefabs().Hash()] = true
+
+		var areaPath string
+		for _, instance := range instances {
+			prefab := instance.Prefab()
+
+			pathsCount[prefab.Path()]++
+			prefabs[prefab.Id()] = true
+
+			if prefab.Vars() != nil && prefab.Vars().Len() != 0 {
+				stats.VarEditedInstances++
+			}
+
+			if areaPath == "" && dm.IsPath(prefab.Path(), "/area") {
+				areaPath = prefab.Path()
+			}
+		}
+
+		// Tiles without an area are possible only on damaged maps.
+		if areaPath == "" {
+			continue
+		}
+
+		area, ok := areas[areaPath]
+		if !ok {
+			area = &Area{Path: areaPath}
+			areas[areaPath] = area
+			areasPathsCount[areaPath] = make(map[string]int)
+		}
+
+		area.Tiles++
+		for _, instance := range instances {
+			if path := instance.Prefab().Path(); !dm.IsPath(path, "/area") {
+				area.Instances++
+				areasPathsCount[areaPath][path]++
+			}
+		}
+	}
+
+	stats.UniquePrefabs = len(prefabs)
+	stats.Keys = keysStats(len(contents))
+	stats.Paths = rollUp(pathsCount)
+
+	stats.Areas = make([]Area, 0, len(areas))
+	for path, area := range areas {
+		area.Paths = rollUp(areasPathsCount[path])
+		stats.Areas = append(stats.Areas, *area)
+	}
+	sort.Slice(stats.Areas, func(i, j int) bool {
+		return stats.Areas[i].Path < stats.Areas[j].Path
+	})
+
+	log.Printf("[dmmstats] stats collected [%s] in [%d] ms", dmm.Name, time.Since(start).Milliseconds())
+
+	return stats
+}
+
+func keysStats(count int) Keys {
+	keys := Keys{Count: count}
+	for keyLength := 1; keyLength <= keygen.MaxKeyLength; keyLength++ {
+		if count <= keygen.Capacity(keyLength) {
+			keys.KeyLength = keyLength
+			break
+		}
+	}
+	if keys.KeyLength == 0 {
+		keys.KeyLength = keygen.MaxKeyLength
+		keys.Exceeded = true
+	}
+	keys.Capacity = keygen.Capacity(keys.KeyLength)
+	return keys
+}
+
+// Counts of exact types are added to all their parent types, so /obj/item has counts of /obj/item/weapon too.
+func rollUp(pathsCount map[string]int) []PathCount {
+	counts := make(map[string]*PathCount)
+
+	countOf := func(path string) *PathCount {
+		count, ok := counts[path]
+		if !ok {
+			count = &PathCount{Path: path}
+			counts[path] = count
+		}
+		return count
+	}
+
+	for path, count := range pathsCount {
+		countOf(path).Count += count
+		for parent := path; parent != ""; parent = parentPath(parent) {
+			countOf(parent).Total += count
+		}
+	}
+
+	result := make([]PathCount, 0, len(counts))
+	for _, count := range counts {
+		result = append(result, *count)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+
+	return result
+}
+
+// Returns an empty string for the root type.
+// Example: /obj/item/weapon -> /obj/item.
+func parentPath(path string) string {
+	if idx := strings.LastIndex(path, "/"); idx > 0 {
+		return path[:idx]
+	}
+	return ""
+}
@@ -0,0 +1,77 @@
+package dmmstats
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"sdmm/dmapi/dmenv"
+	"sdmm/dmapi/dmmap"
+	"sdmm/dmapi/dmmap/dmmdata"
+)
+
+const testMap = `"a" = (/turf,/area/hall)
+"b" = (/obj/item,/turf,/area/hall)
+"c" = (/obj/item/weapon{name = "c"},/obj/item,/turf/floor,/area/room)
+"d" = (/obj/item/weapon,/turf/floor,/area/room)
+
+(1,1,1) = {"
+abc
+aad
+"}
+`
+
+func loadDmm(t *testing.T) *dmmap.Dmm {
+	path := filepath.Join(t.TempDir(), "map.dmm")
+	require.NoError(t, os.WriteFile(path, []byte(testMap), 0644))
+	data, err := dmmdata.New(path)
+	require.NoError(t, err)
+	dmm, _ := dmmap.New(dmenv.NewEmpty(path).Context(), data, path)
+	return dmm
+}
+
+func TestCollect(t *testing.T) {
+	stats := Collect(loadDmm(t))
+
+	assert.Equal(t, 6, stats.Tiles)
+	assert.Equal(t, 16, stats.Instances)
+	assert.Equal(t, 7, stats.UniquePrefabs)
+	assert.Equal(t, 1, stats.VarEditedInstances)
+	assert.Equal(t, Keys{Count: 4, KeyLength: 1, Capacity: 52}, stats.Keys)
+
+	assert.Equal(t, []PathCount{
+		{Path: "/area", Count: 0, Total: 6},
+		{Path: "/area/hall", Count: 4, Total: 4},
+		{Path: "/area/room", Count: 2, Total: 2},
+		{Path: "/obj", Count: 0, Total: 4},
+		{Path: "/obj/item", Count: 2, Total: 4},
+		{Path: "/obj/item/weapon", Count: 2, Total: 2},
+		{Path: "/turf", Count: 4, Total: 6},
+		{Path: "/turf/floor", Count: 2, Total: 2},
+	}, stats.Paths)
+
+	require.Len(t, stats.Areas, 2)
+	assert.Equal(t, "/area/hall", stats.Areas[0].Path)
+	assert.Equal(t, 4, stats.Areas[0].Tiles)
+	assert.Equal(t, 5, stats.Areas[0].Instances)
+	assert.Equal(t, "/area/room", stats.Areas[1].Path)
+	assert.Equal(t, 2, stats.Areas[1].Tiles)
+	assert.Equal(t, 5, stats.Areas[1].Instances)
+	assert.Equal(t, []PathCount{
+		{Path: "/obj", Count: 0, Total: 3},
+		{Path: "/obj/item", Count: 1, Total: 3},
+		{Path: "/obj/item/weapon", Count: 2, Total: 2},
+		{Path: "/turf", Count: 0, Total: 2},
+		{Path: "/turf/floor", Count: 2, Total: 2},
+	}, stats.Areas[1].Paths)
+}
+
+func TestKeysStats(t *testing.T) {
+	assert.Equal(t, Keys{Count: 52, KeyLength: 1, Capacity: 52}, keysStats(52))
+	assert.Equal(t, Keys{Count: 53, KeyLength: 2, Capacity: 2704}, keysStats(53))
+	assert.Equal(t, Keys{Count: 2705, KeyLength: 3, Capacity: 65529}, keysStats(2705))
+	assert.Equal(t, Keys{Count: 70000, KeyLength: 3, Capacity: 65529, Exceeded: true}, keysStats(70000))
+}
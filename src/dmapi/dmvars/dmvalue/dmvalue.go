@@ -0,0 +1,310 @@
+// Package dmvalue provides a typed model of DM constant values, which are used as variables values on maps.
+// Values are parsed from their raw form with the Parse function and formatted back to the canonical form
+// with the Value.String method.
+package dmvalue
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+type Kind int
+
+const (
+	KindNull Kind = iota
+	KindNumber
+	KindString
+	KindResource
+	KindPath
+	KindList
+	KindExpression
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindNull:
+		return "null"
+	case KindNumber:
+		return "number"
+	case KindString:
+		return "string"
+	case KindResource:
+		return "resource"
+	case KindPath:
+		return "path"
+	case KindList:
+		return "list"
+	case KindExpression:
+		return "expression"
+	}
+	return "unknown"
+}
+
+// Value is a parsed DM constant.
+type Value interface {
+	Kind() Kind
+	// String returns the value in its canonical form.
+	String() string
+}
+
+type Null struct{}
+
+func (Null) Kind() Kind {
+	return KindNull
+}
+
+func (Null) String() string {
+	return "null"
+}
+
+type Number float64
+
+func (Number) Kind() Kind {
+	return KindNumber
+}
+
+func (n Number) String() string {
+	return formatNumber(float64(n))
+}
+
+// Int returns the number with its fractional part discarded.
+// Infinite numbers are clamped to the int range.
+func (n Number) Int() int {
+	switch f := float64(n); {
+	case math.IsNaN(f):
+		return 0
+	case f >= math.MaxInt:
+		return math.MaxInt
+	case f <= math.MinInt:
+		return math.MinInt
+	}
+	return int(n)
+}
+
+// String is a DM string. It stores the string as it's written between quotes, so escapes and text macros are kept.
+type String struct {
+	raw string
+}
+
+// NewString creates a string value from the provided text by escaping it.
+func NewString(text string) String {
+	sb := strings.Builder{}
+	for _, c := range text {
+		switch c {
+		case '"', '\\', '[', ']':
+			sb.WriteRune('\\')
+			sb.WriteRune(c)
+		case '\n':
+			sb.WriteString("\\n")
+		case '\t':
+			sb.WriteString("\\t")
+		default:
+			sb.WriteRune(c)
+		}
+	}
+	return String{sb.String()}
+}
+
+func (String) Kind() Kind {
+	return KindString
+}
+
+func (s String) String() string {
+	return "\"" + s.raw + "\""
+}
+
+// Raw returns the string as it's written between quotes.
+func (s String) Raw() string {
+	return s.raw
+}
+
+// Decoded returns the text of the string as it's shown in the game.
+// Escapes are decoded and the \improper and \proper macros are removed, while other text macros are kept.
+func (s String) Decoded() string {
+	return unescape(s.raw)
+}
+
+// Resource is a file path written in single quotes, like 'icons/obj/item.dmi'.
+type Resource string
+
+func (Resource) Kind() Kind {
+	return KindResource
+}
+
+func (r Resource) String() string {
+	return "'" + string(r) + "'"
+}
+
+type Path string
+
+func (Path) Kind() Kind {
+	return KindPath
+}
+
+func (p Path) String() string {
+	return string(p)
+}
+
+// Expression is a value which is not a constant literal, like newlist(/obj) or icon('icon.dmi').
+// It's kept as it's written, since only BYOND is able to evaluate it.
+type Expression string
+
+func (Expression) Kind() Kind {
+	return KindExpression
+}
+
+func (e Expression) String() string {
+	return string(e)
+}
+
+// List is a DM list. Entries of an associative list have both a key and a value.
+type List []ListEntry
+
+type ListEntry struct {
+	Key Value
+	// Value is nil for entries without an associated value.
+	Value Value
+}
+
+func (List) Kind() Kind {
+	return KindList
+}
+
+func (l List) String() string {
+	sb := strings.Builder{}
+	sb.WriteString("list(")
+	for idx, entry := range l {
+		if idx != 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(entry.Key.String())
+		if entry.Value != nil {
+			sb.WriteString(" = ")
+			sb.WriteString(entry.Value.String())
+		}
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+
+// IsAssoc returns true if there is at least one entry with an associated value.
+func (l List) IsAssoc() bool {
+	for _, entry := range l {
+		if entry.Value != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Format returns the canonical form of the raw value.
+func Format(raw string) (string, error) {
+	value, err := Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	return value.String(), nil
+}
+
+// Equal compares two raw values by their canonical form, so values like "1" and "1.0" are equal.
+// Malformed values are compared as they are.
+func Equal(a, b string) bool {
+	if a == b {
+		return true
+	}
+	aValue, aErr := Parse(a)
+	bValue, bErr := Parse(b)
+	if aErr != nil || bErr != nil {
+		return false
+	}
+	return aValue.String() == bValue.String()
+}
+
+// BYOND writes infinite numbers in that way.
+const (
+	positiveInf = "1.#INF"
+	negativeInf = "-1.#INF"
+	nan         = "1.#IND"
+)
+
+func formatNumber(n float64) string {
+	switch {
+	case math.IsInf(n, 1):
+		return positiveInf
+	case math.IsInf(n, -1):
+		return negativeInf
+	case math.IsNaN(n):
+		return nan
+	case n == math.Trunc(n) && math.Abs(n) < 1e15:
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(n, 'g', -1, 64)
+}
+
+// Text macros are checked before escapes, since "\the" is a macro, not a tab with "he".
+var (
+	// Macros which are removed from the text, since they only affect how the text is shown in the game.
+	removedTextMacros = []string{"improper", "proper"}
+	// Macros which are kept in the text as they are.
+	keptTextMacros = []string{
+		"the", "The", "an", "An", "a", "A",
+		"himself", "herself", "him", "his", "His", "hers", "Hers", "he", "He", "she", "She",
+		"icon", "ref", "roman", "Roman", "th", "s",
+	}
+)
+
+func unescape(raw string) string {
+	if !strings.ContainsRune(raw, '\\') {
+		return raw
+	}
+
+	sb := strings.Builder{}
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		if c != '\\' || i == len(raw)-1 {
+			sb.WriteByte(c)
+			continue
+		}
+
+		i++
+
+		if macro, ok := textMacro(raw[i:], removedTextMacros); ok {
+			i += len(macro) - 1
+			// Skip the space after the macro, so there is no leading space in the text.
+			if i+1 < len(raw) && raw[i+1] == ' ' {
+				i++
+			}
+			continue
+		}
+		if macro, ok := textMacro(raw[i:], keptTextMacros); ok {
+			sb.WriteByte('\\')
+			sb.WriteString(macro)
+			i += len(macro) - 1
+			continue
+		}
+
+		switch raw[i] {
+		case 'n':
+			sb.WriteByte('\n')
+		case 't':
+			sb.WriteByte('\t')
+		case '"', '\\', '[', ']', '<', '>', '\'':
+			sb.WriteByte(raw[i])
+		default:
+			// Keep unknown escapes as they are.
+			sb.WriteByte('\\')
+			sb.WriteByte(raw[i])
+		}
+	}
+	return sb.String()
+}
+
+func textMacro(s string, macros []string) (string, bool) {
+	for _, macro := range macros {
+		if strings.HasPrefix(s, macro) && (len(s) == len(macro) || !isIdentChar(s[len(macro)])) {
+			return macro, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,186 @@
+package dmvalue
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		raw       string
+		kind      Kind
+		canonical string
+	}{
+		{"null", KindNull, "null"},
+		{"  null ", KindNull, "null"},
+		{"4", KindNumber, "4"},
+		{"-16", KindNumber, "-16"},
+		{"1.50", KindNumber, "1.5"},
+		{"1e+006", KindNumber, "1000000"},
+		{"1.#INF", KindNumber, "1.#INF"},
+		{"-1.#INF", KindNumber, "-1.#INF"},
+		{`"text"`, KindString, `"text"`},
+		{`"say \"hi\" \[x]"`, KindString, `"say \"hi\" \[x]"`},
+		{"{\"multi\nline \"quoted\"\"}", KindString, `"multi\nline \"quoted\""`},
+		{"'icons/obj/item.dmi'", KindResource, "'icons/obj/item.dmi'"},
+		{"/obj/item", KindPath, "/obj/item"},
+		{"list()", KindList, "list()"},
+		{"list(1,2 , \"a\")", KindList, `list(1, 2, "a")`},
+		{`list("a"=1,b = list(/obj), 'x.dmi' = null)`, KindList, `list("a" = 1, "b" = list(/obj), 'x.dmi' = null)`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.raw, func(t *testing.T) {
+			value, err := Parse(test.raw)
+			require.NoError(t, err)
+			assert.Equal(t, test.kind, value.Kind())
+			assert.Equal(t, test.canonical, value.String())
+
+			// The canonical form is stable.
+			formatted, err := Format(value.String())
+			require.NoError(t, err)
+			assert.Equal(t, test.canonical, formatted)
+		})
+	}
+}
+
+func TestParse_Malformed(t *testing.T) {
+	for _, raw := range []string{
+		"",
+		"  ",
+		`"unterminated`,
+		"'unterminated",
+		"list(1, 2",
+		"icon('x')) + 1",
+		`newlist("a)`,
+		"{x = 1}",
+		"{",
+		"{\"unterminated",
+		"list(1 2)",
+		"4 5",
+		"4px",
+		"NORTH EAST",
+		"1 +",
+		"icon(",
+		"rgb(1,)",
+		"new NORTH",
+		"/obj{name}",
+		"/obj{name = 1",
+		"list(x[1)",
+		"@",
+	} {
+		t.Run(raw, func(t *testing.T) {
+			_, err := Parse(raw)
+			assert.IsType(t, &SyntaxError{}, err)
+		})
+	}
+}
+
+func TestParse_Expression(t *testing.T) {
+	for _, raw := range []string{
+		"NORTH",
+		"newlist(/obj/item, /obj/item{name = \"x\"})",
+		"matrix(1, 0, 0, 0, 1, 0)",
+		"icon('icons/obj/item.dmi')",
+		"rgb(255, 0, 0)",
+		"new /obj()",
+		"/obj{name = 1}",
+		"/obj{name = \"x\"; dir = 4}",
+		`"a" + {"b"}`,
+		"list(x[1])",
+		"NORTH|EAST",
+		"-(1 << 2)",
+		"!TRUE",
+		`list("a" = NORTH, b = -SOUTH)`,
+		"matrix(a = 1) * 2",
+	} {
+		t.Run(raw, func(t *testing.T) {
+			value, err := Parse(" " + raw + " ")
+			require.NoError(t, err)
+			assert.Equal(t, KindExpression, value.Kind())
+			assert.Equal(t, raw, value.String())
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	value, err := Parse(`list("a" = 1, /obj)`)
+	require.NoError(t, err)
+
+	list := value.(List)
+	require.Len(t, list, 2)
+	assert.True(t, list.IsAssoc())
+	assert.Equal(t, NewString("a"), list[0].Key)
+	assert.Equal(t, Number(1), list[0].Value)
+	assert.Equal(t, Path("/obj"), list[1].Key)
+	assert.Nil(t, list[1].Value)
+}
+
+func TestString(t *testing.T) {
+	value, err := Parse(`"\improper Say \"hi\"\nnow \the"`)
+	require.NoError(t, err)
+	assert.Equal(t, `\improper Say \"hi\"\nnow \the`, value.(String).Raw())
+	assert.Equal(t, "Say \"hi\"\nnow \\the", value.(String).Decoded())
+
+	s := NewString("a \"quoted\" [text]\n")
+	assert.Equal(t, `"a \"quoted\" \[text\]\n"`, s.String())
+	assert.Equal(t, "a \"quoted\" [text]\n", s.Decoded())
+}
+
+func TestParseText(t *testing.T) {
+	text, err := ParseText(`"icon_state"`)
+	require.NoError(t, err)
+	assert.Equal(t, "icon_state", text)
+
+	text, err = ParseText("'icons/obj/item.dmi'")
+	require.NoError(t, err)
+	assert.Equal(t, "icons/obj/item.dmi", text)
+
+	text, err = ParseText("{\"multi\"}")
+	require.NoError(t, err)
+	assert.Equal(t, "multi", text)
+
+	// Escapes and text macros are kept.
+	text, err = ParseText(`"\improper say \"hi\""`)
+	require.NoError(t, err)
+	assert.Equal(t, `\improper say \"hi\"`, text)
+
+	_, err = ParseText("null")
+	assert.Error(t, err)
+	_, err = ParseText("{x}")
+	assert.Error(t, err)
+	_, err = ParseText("icon('x.dmi')")
+	assert.Error(t, err)
+	_, err = ParseText("12")
+	assert.Error(t, err)
+}
+
+func TestParseNumber(t *testing.T) {
+	n, err := ParseNumber("-4.5")
+	require.NoError(t, err)
+	assert.Equal(t, -4.5, n)
+	assert.Equal(t, -4, Number(n).Int())
+
+	n, err = ParseNumber("1.#INF")
+	require.NoError(t, err)
+	assert.True(t, math.IsInf(n, 1))
+	assert.Equal(t, math.MaxInt, Number(n).Int())
+
+	_, err = ParseNumber(`"4"`)
+	assert.Error(t, err)
+	_, err = ParseNumber("4px")
+	assert.Error(t, err)
+}
+
+func TestEqual(t *testing.T) {
+	assert.True(t, Equal("1", "1.0"))
+	assert.True(t, Equal("list(1,2)", "list(1, 2)"))
+	assert.False(t, Equal("1", `"1"`))
+	assert.False(t, Equal("NORTH", "1"))
+	assert.True(t, Equal("NORTH", "NORTH"))
+	assert.True(t, Equal("icon('x')", " icon('x') "))
+	assert.False(t, Equal("icon('x')", "'x'"))
+}
@@ -0,0 +1,569 @@
+package dmvalue
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SyntaxError is returned when a raw value can't be parsed.
+type SyntaxError struct {
+	Value string
+	// Offset is a byte offset in the value where the error has occurred.
+	Offset int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("malformed value %q at %d: %s", e.Value, e.Offset, e.Msg)
+}
+
+// Parse parses the raw value. Surrounding spaces are ignored.
+// Values which are not literals, but valid DM expressions, are returned as an Expression.
+func Parse(raw string) (Value, error) {
+	p := parser{raw: raw}
+	p.skipSpaces()
+
+	value, err := p.parseValue()
+	if err == nil {
+		p.skipSpaces()
+		if p.eof() {
+			return value, nil
+		}
+	}
+
+	return parseExpression(raw)
+}
+
+// Expressions are only validated, since they are evaluated by BYOND.
+// Supported are constants, calls, paths with var overrides, indexes, unary and binary operators,
+// like NORTH|EAST, newlist(/obj{name = "x"}), icon('x.dmi') or -(1 << 2).
+func parseExpression(raw string) (Value, error) {
+	p := parser{raw: strings.TrimSpace(raw)}
+	if err := p.parseExpr(); err != nil {
+		return nil, err
+	}
+	if !p.eof() {
+		return nil, p.errorf("unexpected %q", p.peek())
+	}
+	return Expression(p.raw), nil
+}
+
+// ParseText returns a text of the string or the resource value as it's written between quotes, so escapes are kept.
+// Unlike the Parse function, it doesn't allocate a value.
+func ParseText(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if len(raw) > 0 && (raw[0] == '"' || raw[0] == '\'' || strings.HasPrefix(raw, "{\"")) {
+		p := parser{raw: raw}
+		var (
+			text string
+			err  error
+		)
+		switch raw[0] {
+		case '\'':
+			text, err = p.parseResource()
+		default:
+			var s String
+			if s, err = p.parseString(); err == nil {
+				text = s.Raw()
+			}
+		}
+		if err != nil {
+			return "", err
+		}
+		if !p.eof() {
+			return "", p.errorf("unexpected %q after the value", p.raw[p.pos])
+		}
+		return text, nil
+	}
+	return "", &SyntaxError{Value: raw, Msg: "not a string or a resource"}
+}
+
+// ParseNumber returns a number of the raw value.
+// Unlike the Parse function, it doesn't allocate a value.
+func ParseNumber(raw string) (float64, error) {
+	raw = strings.TrimSpace(raw)
+	p := parser{raw: raw}
+	if p.eof() || !isNumberStart(raw[0]) {
+		return 0, &SyntaxError{Value: raw, Msg: "not a number"}
+	}
+	n, err := p.parseNumber()
+	if err != nil {
+		return 0, err
+	}
+	if !p.eof() {
+		return 0, p.errorf("unexpected %q after the value", p.raw[p.pos])
+	}
+	return n, nil
+}
+
+type parser struct {
+	raw string
+	pos int
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return &SyntaxError{Value: p.raw, Offset: p.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.raw)
+}
+
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.raw[p.pos]
+}
+
+func (p *parser) peekNext() byte {
+	if p.pos+1 >= len(p.raw) {
+		return 0
+	}
+	return p.raw[p.pos+1]
+}
+
+func (p *parser) hasPrefix(prefix string) bool {
+	return strings.HasPrefix(p.raw[p.pos:], prefix)
+}
+
+func (p *parser) skipSpaces() {
+	for !p.eof() {
+		switch p.raw[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) parseValue() (Value, error) {
+	if p.eof() {
+		return nil, p.errorf("value expected")
+	}
+
+	c := p.peek()
+	switch {
+	case c == '"' || p.hasPrefix("{\""):
+		return p.parseString()
+	case c == '\'':
+		resource, err := p.parseResource()
+		return Resource(resource), err
+	case c == '/':
+		return p.parsePath()
+	case isNumberStart(c):
+		n, err := p.parseNumber()
+		return Number(n), err
+	case isIdentStart(c):
+		start := p.pos
+		switch ident := p.parseIdent(); ident {
+		case "null":
+			return Null{}, nil
+		case "list":
+			return p.parseList()
+		default:
+			p.pos = start
+			return nil, p.errorf("unsupported identifier %q", ident)
+		}
+	}
+
+	return nil, p.errorf("unexpected %q", c)
+}
+
+func (p *parser) parseString() (String, error) {
+	start := p.pos
+
+	// Multiline strings like {"text"} are converted to regular strings.
+	if p.hasPrefix("{\"") {
+		end := strings.Index(p.raw[p.pos+2:], "\"}")
+		if end == -1 {
+			return String{}, p.errorf("unterminated string")
+		}
+		content := p.raw[p.pos+2 : p.pos+2+end]
+		p.pos += 2 + end + 2
+		return String{escapeMultiline(content)}, nil
+	}
+
+	p.pos++ // opening quote
+	for !p.eof() {
+		switch p.raw[p.pos] {
+		case '\\':
+			p.pos += 2
+		case '"':
+			p.pos++
+			return String{p.raw[start+1 : p.pos-1]}, nil
+		default:
+			p.pos++
+		}
+	}
+
+	p.pos = start
+	return String{}, p.errorf("unterminated string")
+}
+
+// Escapes quotes and line breaks which are allowed in multiline strings, but not in the regular ones.
+func escapeMultiline(content string) string {
+	sb := strings.Builder{}
+	for i := 0; i < len(content); i++ {
+		switch c := content[i]; c {
+		case '\\':
+			sb.WriteByte(c)
+			if i+1 < len(content) {
+				i++
+				sb.WriteByte(content[i])
+			}
+		case '"':
+			sb.WriteString("\\\"")
+		case '\n':
+			sb.WriteString("\\n")
+		case '\r':
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+func (p *parser) parseResource() (string, error) {
+	start := p.pos
+	end := strings.IndexByte(p.raw[p.pos+1:], '\'')
+	if end == -1 {
+		return "", p.errorf("unterminated resource")
+	}
+	p.pos += 1 + end + 1
+	return p.raw[start+1 : p.pos-1], nil
+}
+
+func (p *parser) parsePath() (Value, error) {
+	start := p.pos
+	for !p.eof() && (isIdentChar(p.peek()) || p.peek() == '/') {
+		p.pos++
+	}
+	if c := p.peek(); c == '{' || c == '(' {
+		return nil, p.errorf("unsupported %q after the path", c)
+	}
+	return Path(p.raw[start:p.pos]), nil
+}
+
+func (p *parser) parseNumber() (float64, error) {
+	start := p.pos
+
+	if p.peek() == '-' || p.peek() == '+' {
+		p.pos++
+	}
+
+	// BYOND style of infinite numbers.
+	if p.hasPrefix("1.#INF") || p.hasPrefix("1.#IND") {
+		p.pos += len(positiveInf)
+		switch {
+		case strings.HasSuffix(p.raw[:p.pos], "IND"):
+			return math.NaN(), nil
+		case p.raw[start] == '-':
+			return math.Inf(-1), nil
+		}
+		return math.Inf(1), nil
+	}
+
+	for !p.eof() {
+		c := p.peek()
+		isExp := c == 'e' || c == 'E'
+		isExpSign := (c == '+' || c == '-') && p.pos > start && (p.raw[p.pos-1] == 'e' || p.raw[p.pos-1] == 'E')
+		if !isDigit(c) && c != '.' && !isExp && !isExpSign {
+			break
+		}
+		p.pos++
+	}
+
+	n, err := strconv.ParseFloat(p.raw[start:p.pos], 64)
+	if err != nil {
+		p.pos = start
+		return 0, p.errorf("invalid number")
+	}
+	return n, nil
+}
+
+func (p *parser) parseIdent() string {
+	start := p.pos
+	for !p.eof() && isIdentChar(p.peek()) {
+		p.pos++
+	}
+	return p.raw[start:p.pos]
+}
+
+func (p *parser) parseList() (Value, error) {
+	p.skipSpaces()
+	if p.peek() != '(' {
+		return nil, p.errorf("'(' expected after list")
+	}
+	p.pos++
+
+	list := List{}
+
+	p.skipSpaces()
+	if p.peek() == ')' {
+		p.pos++
+		return list, nil
+	}
+
+	for {
+		p.skipSpaces()
+
+		entry, err := p.parseListEntry()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, entry)
+
+		p.skipSpaces()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ')':
+			p.pos++
+			return list, nil
+		default:
+			if p.eof() {
+				return nil, p.errorf("unterminated list")
+			}
+			return nil, p.errorf("',' or ')' expected in list")
+		}
+	}
+}
+
+func (p *parser) parseListEntry() (entry ListEntry, err error) {
+	// Keys of associative lists could be written as identifiers: list(key = value).
+	if isIdentStart(p.peek()) {
+		start := p.pos
+		ident := p.parseIdent()
+		p.skipSpaces()
+		if p.peek() == '=' && ident != "null" && ident != "list" {
+			entry.Key = NewString(ident)
+		} else {
+			p.pos = start
+		}
+	}
+
+	if entry.Key == nil {
+		if entry.Key, err = p.parseValue(); err != nil {
+			return entry, err
+		}
+		p.skipSpaces()
+	}
+
+	if p.peek() == '=' {
+		p.pos++
+		p.skipSpaces()
+		if entry.Value, err = p.parseValue(); err != nil {
+			return entry, err
+		}
+	}
+
+	return entry, nil
+}
+
+// Two-character operators go first, so they are not taken for one-character ones.
+var binaryOperators = []string{
+	"**", "<<", ">>", "==", "!=", "<=", ">=", "&&", "||",
+	"+", "-", "*", "/", "%", "|", "&", "^", "<", ">",
+}
+
+func (p *parser) parseExpr() error {
+	for {
+		p.skipSpaces()
+		if err := p.parseOperand(); err != nil {
+			return err
+		}
+		p.skipSpaces()
+
+		operator, ok := p.binaryOperator()
+		if !ok {
+			return nil
+		}
+		p.pos += len(operator)
+	}
+}
+
+func (p *parser) binaryOperator() (string, bool) {
+	for _, operator := range binaryOperators {
+		if p.hasPrefix(operator) {
+			return operator, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) parseOperand() (err error) {
+	// Unary operators. Negative numbers are parsed as numbers.
+	for c := p.peek(); c == '!' || c == '~' || (c == '-' && !isNumberStart(p.peekNext())); c = p.peek() {
+		p.pos++
+		p.skipSpaces()
+	}
+
+	if p.eof() {
+		return p.errorf("value expected")
+	}
+
+	switch c := p.peek(); {
+	case c == '(':
+		p.pos++
+		if err = p.parseExpr(); err != nil {
+			return err
+		}
+		if err = p.expect(')'); err != nil {
+			return err
+		}
+	case c == '"' || p.hasPrefix("{\""):
+		_, err = p.parseString()
+	case c == '\'':
+		_, err = p.parseResource()
+	case c == '/':
+		err = p.parsePathExpr()
+	case isNumberStart(c):
+		_, err = p.parseNumber()
+	case isIdentStart(c):
+		err = p.parseIdentExpr()
+	default:
+		return p.errorf("unexpected %q", c)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Indexes, like list[1].
+	for p.skipSpaces(); p.peek() == '['; p.skipSpaces() {
+		p.pos++
+		if err = p.parseExpr(); err != nil {
+			return err
+		}
+		if err = p.expect(']'); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Identifiers are constants or calls, like NORTH or icon('x.dmi'). The "new" keyword is followed by a path.
+func (p *parser) parseIdentExpr() error {
+	ident := p.parseIdent()
+	p.skipSpaces()
+	if ident == "new" {
+		if p.peek() != '/' {
+			return p.errorf("path expected after new")
+		}
+		return p.parsePathExpr()
+	}
+	if p.peek() == '(' {
+		return p.parseArgs()
+	}
+	return nil
+}
+
+// Paths could have var overrides and arguments, like /obj{name = "x"; dir = 4}.
+func (p *parser) parsePathExpr() error {
+	for !p.eof() && (isIdentChar(p.peek()) || p.peek() == '/') {
+		p.pos++
+	}
+	p.skipSpaces()
+
+	if p.peek() == '{' {
+		p.pos++
+		for {
+			p.skipSpaces()
+			if p.peek() == '}' {
+				p.pos++
+				break
+			}
+			if !isIdentStart(p.peek()) {
+				return p.errorf("var name expected in the path overrides")
+			}
+			p.parseIdent()
+			p.skipSpaces()
+			if err := p.expect('='); err != nil {
+				return err
+			}
+			if err := p.parseExpr(); err != nil {
+				return err
+			}
+			p.skipSpaces()
+			if p.peek() == ';' {
+				p.pos++
+			} else if p.peek() != '}' {
+				return p.errorf("';' or '}' expected in the path overrides")
+			}
+		}
+		p.skipSpaces()
+	}
+
+	if p.peek() == '(' {
+		return p.parseArgs()
+	}
+	return nil
+}
+
+// Arguments could be named or associated, like list("a" = 1) or matrix(a = 1).
+func (p *parser) parseArgs() error {
+	p.pos++ // opening bracket
+
+	p.skipSpaces()
+	if p.peek() == ')' {
+		p.pos++
+		return nil
+	}
+
+	for {
+		if err := p.parseExpr(); err != nil {
+			return err
+		}
+		if p.peek() == '=' {
+			p.pos++
+			if err := p.parseExpr(); err != nil {
+				return err
+			}
+		}
+
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ')':
+			p.pos++
+			return nil
+		default:
+			if p.eof() {
+				return p.errorf("unterminated arguments")
+			}
+			return p.errorf("',' or ')' expected in arguments")
+		}
+	}
+}
+
+func (p *parser) expect(c byte) error {
+	p.skipSpaces()
+	if p.peek() != c {
+		if p.eof() {
+			return p.errorf("%q expected", c)
+		}
+		return p.errorf("%q expected instead of %q", c, p.peek())
+	}
+	p.pos++
+	return nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isNumberStart(c byte) bool {
+	return isDigit(c) || c == '-' || c == '+' || c == '.'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || isDigit(c)
+}
@@ -2,8 +2,8 @@ package dmvars
 
 import (
 	"log"
-	"strconv"
 
+	"sdmm/dmapi/dmvars/dmvalue"
 	"sdmm/util/slice"
 )
 
@@ -97,6 +97,17 @@ func (v *Variables) LinkParent(parent *Variables) {
 	v.parent = parent
 }
 
+// RelinkParent replaces the old parent of variables with the new one.
+// Unlike the LinkParent it's used when parents are replaced on purpose, like on the environment reload.
+// Variables with another parent are not touched, so the returned value is false for them.
+func (v *Variables) RelinkParent(oldParent, newParent *Variables) bool {
+	if v.parent != oldParent {
+		return false
+	}
+	v.parent = newParent
+	return true
+}
+
 func (v *Variables) Iterate() []string {
 	return v.names
 }
@@ -124,12 +135,22 @@ func (v *Variables) ValueV(name string, defaultValue string) string {
 	return defaultValue
 }
 
+// Typed returns the parsed value of the variable.
+// The second value is false, if there is no such variable or its value is malformed.
+func (v *Variables) Typed(name string) (dmvalue.Value, bool) {
+	if value, ok := v.Value(name); ok {
+		if typed, err := dmvalue.Parse(value); err == nil {
+			return typed, true
+		}
+	}
+	return nil, false
+}
+
+// Text returns the text of a string or a resource value.
 func (v *Variables) Text(name string) (string, bool) {
-	if value, ok := v.Value(name); ok && value != NullValue {
-		if len(value) > 1 {
-			return value[1 : len(value)-1], true
-		} else {
-			return value, true
+	if value, ok := v.Value(name); ok {
+		if text, err := dmvalue.ParseText(value); err == nil {
+			return text, true
 		}
 	}
 	return "", false
@@ -143,8 +164,8 @@ func (v *Variables) TextV(name string, defaultValue string) string {
 }
 
 func (v *Variables) Float(name string) (float32, bool) {
-	if value, ok := v.Value(name); ok && value != NullValue {
-		if n, err := strconv.ParseFloat(value, 32); err == nil {
+	if value, ok := v.Value(name); ok {
+		if n, err := dmvalue.ParseNumber(value); err == nil {
 			return float32(n), true
 		}
 	}
@@ -158,10 +179,11 @@ func (v *Variables) FloatV(name string, defaultValue float32) float32 {
 	return defaultValue
 }
 
+// Int returns the number value with its fractional part discarded.
 func (v *Variables) Int(name string) (int, bool) {
-	if value, ok := v.Value(name); ok && value != NullValue {
-		if n, err := strconv.ParseInt(value, 10, 32); err == nil {
-			return int(n), true
+	if value, ok := v.Value(name); ok {
+		if n, err := dmvalue.ParseNumber(value); err == nil {
+			return dmvalue.Number(n).Int(), true
 		}
 	}
 	return 0, false
@@ -16,6 +16,21 @@ import (
 	"unsafe"
 )
 
+// Environment is a result of the environment parsing.
+type Environment struct {
+	Tree        ObjectTreeType
+	Diagnostics []Diagnostic
+}
+
+// Diagnostic is an error or a warning found by the parser in the environment code.
+type Diagnostic struct {
+	File        string // Relative to the environment directory. Empty for builtin locations.
+	Line        int
+	Column      int
+	Severity    string // One of: "error", "warning", "info" or "hint".
+	Description string
+}
+
 type ObjectTreeType struct {
 	Path     string
 	Vars     []ObjectTreeVar
@@ -25,9 +40,21 @@ type ObjectTreeType struct {
 type ObjectTreeVar struct {
 	Name  string
 	Value string
+	Decl  *ObjectTreeVarDecl
+}
+
+// ObjectTreeVarDecl is a declaration of the var. It's nil when the parser wasn't able to find it.
+type ObjectTreeVarDecl struct {
+	Owner  string // A type path of the type which declares the var.
+	Type   string // A declared type of the var, e.g. "/list" for "var/list/x". Empty when untyped.
+	Tmp    bool
+	Const  bool
+	Static bool
+	File   string // Relative to the environment directory. Empty for builtin vars.
+	Line   int
 }
 
-func ParseEnvironment(environmentPath string) (*ObjectTreeType, error) {
+func ParseEnvironment(environmentPath string) (*Environment, error) {
 	nativePath := C.CString(environmentPath)
 	defer C.free(unsafe.Pointer(nativePath))
 
@@ -39,7 +66,7 @@ func ParseEnvironment(environmentPath string) (*ObjectTreeType, error) {
 		return nil, fmt.Errorf(str)
 	}
 
-	var data ObjectTreeType
+	var data Environment
 	if err := json.Unmarshal([]byte(str), &data); err != nil {
 		return nil, fmt.Errorf("unable to deserialize environment: %w", err)
 	}
@@ -1,8 +1,23 @@
 use std::panic;
 
 use dm::constants::Constant;
-use dm::Context;
 use dm::objtree::TypeRef;
+use dm::{Context, DMError};
+
+#[derive(Serialize)]
+struct Environment {
+    tree: ObjectTreeType,
+    diagnostics: Vec<Diagnostic>,
+}
+
+#[derive(Serialize)]
+struct Diagnostic {
+    file: String,
+    line: u32,
+    column: u16,
+    severity: String,
+    description: String,
+}
 
 #[derive(Serialize)]
 struct ObjectTreeType {
@@ -15,13 +30,28 @@ struct ObjectTreeType {
 struct ObjectTreeVar {
     name: String,
     value: String,
+    decl: Option<ObjectTreeVarDecl>,
+}
+
+#[derive(Serialize)]
+struct ObjectTreeVarDecl {
+    owner: String,
+    #[serde(rename = "type")]
+    type_path: String,
+    tmp: bool,
+    #[serde(rename = "const")]
+    is_const: bool,
+    #[serde(rename = "static")]
+    is_static: bool,
+    file: String,
+    line: u32,
 }
 
 pub fn parse_environment(path: String) -> String {
     match panic::catch_unwind(|| {
         match parse(&path) {
-            Some(json) => json,
-            None => format!("error: unable to parse environment {}", path)
+            Ok(json) => json,
+            Err(e) => format!("error: unable to parse environment {}: {}", path, e)
         }
     }) {
         Ok(result) => result,
@@ -35,19 +65,41 @@ pub fn parse_environment(path: String) -> String {
     }
 }
 
-fn parse(env_path: &str) -> Option<String> {
-    let objtree = match Context::default().parse_environment(env_path.as_ref()) {
+fn parse(env_path: &str) -> Result<String, String> {
+    let ctx = Context::default();
+    let objtree = match ctx.parse_environment(env_path.as_ref()) {
         Ok(t) => t,
-        Err(_e) => return None,
+        Err(e) => return Err(e.to_string()),
     };
 
-    let root = recurse_objtree(objtree.root());
-    let json = serde_json::to_string(&root).unwrap();
+    let environment = Environment {
+        tree: recurse_objtree(&ctx, objtree.root()),
+        diagnostics: ctx
+            .errors()
+            .iter()
+            .map(|error| diagnostic(&ctx, error))
+            .collect(),
+    };
+
+    serde_json::to_string(&environment).map_err(|e| e.to_string())
+}
 
-    return Some(json);
+fn diagnostic(ctx: &Context, error: &DMError) -> Diagnostic {
+    let location = error.location();
+    Diagnostic {
+        file: if location.is_builtins() {
+            String::new()
+        } else {
+            ctx.file_path(location.file).to_string_lossy().into_owned()
+        },
+        line: location.line,
+        column: location.column,
+        severity: error.severity().to_string(),
+        description: error.description().to_owned(),
+    }
 }
 
-fn recurse_objtree(ty: TypeRef) -> ObjectTreeType {
+fn recurse_objtree(ctx: &Context, ty: TypeRef) -> ObjectTreeType {
     let mut entry = ObjectTreeType {
         path: ty.path.to_owned(),
         vars: Vec::new(),
@@ -63,12 +115,43 @@ fn recurse_objtree(ty: TypeRef) -> ObjectTreeType {
                 .as_ref()
                 .unwrap_or(Constant::null())
                 .to_string(),
+            decl: var_decl(ctx, ty, name),
         });
     }
 
     for child in ty.children() {
-        entry.children.push(recurse_objtree(child));
+        entry.children.push(recurse_objtree(ctx, child));
     }
 
     entry
 }
+
+// Types only override vars of their parents, so the declaration is looked up through the whole type family.
+fn var_decl(ctx: &Context, ty: TypeRef, name: &str) -> Option<ObjectTreeVarDecl> {
+    let mut current = Some(ty);
+    while let Some(owner) = current {
+        if let Some(decl) = owner.vars.get(name).and_then(|var| var.declaration.as_ref()) {
+            let flags = &decl.var_type.flags;
+            return Some(ObjectTreeVarDecl {
+                owner: owner.path.to_owned(),
+                type_path: decl
+                    .var_type
+                    .type_path
+                    .iter()
+                    .map(|part| format!("/{}", part))
+                    .collect(),
+                tmp: flags.is_tmp(),
+                is_const: flags.is_const(),
+                is_static: flags.is_static(),
+                file: if decl.location.is_builtins() {
+                    String::new()
+                } else {
+                    ctx.file_path(decl.location.file).to_string_lossy().into_owned()
+                },
+                line: decl.location.line,
+            });
+        }
+        current = owner.parent_type();
+    }
+    None
+}